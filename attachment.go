@@ -4,13 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
 )
 
 // AttachmentType represents the type of attachment.
@@ -85,7 +94,8 @@ func NewAttachmentFromReader(name string, r io.Reader, mimeType string) (*Attach
 	return NewAttachmentFromBytes(name, data, mimeType), nil
 }
 
-// NewAttachmentFromURL creates an attachment from a URL.
+// NewAttachmentFromURL creates an attachment from a URL. URL attachments
+// have no data, so they are not uploaded when added to a trace or span.
 func NewAttachmentFromURL(name string, url string, attachType AttachmentType) *Attachment {
 	return &Attachment{
 		Name: name,
@@ -186,9 +196,39 @@ func mimeTypeToAttachmentType(mimeType string) AttachmentType {
 	}
 }
 
-// AttachmentUploader handles uploading attachments to storage.
+// AttachmentEntityType identifies the kind of entity an attachment belongs to.
+type AttachmentEntityType string
+
+const (
+	AttachmentEntityTrace AttachmentEntityType = "trace"
+	AttachmentEntitySpan  AttachmentEntityType = "span"
+)
+
+const (
+	// DefaultAttachmentPartSize is the default size of each part of a
+	// multipart attachment upload.
+	DefaultAttachmentPartSize = 8 * 1024 * 1024
+
+	// MinAttachmentPartSize is the smallest part size accepted by S3-compatible
+	// storage for all parts but the last.
+	MinAttachmentPartSize = 5 * 1024 * 1024
+
+	// localUploadID is returned by Opik deployments that store attachments
+	// themselves instead of in S3. The file is then sent in a single request
+	// to the backend.
+	localUploadID = "BEMinIO"
+)
+
+// AttachmentUploader uploads attachments to Opik's attachment storage using
+// the multipart upload API. Files are split into parts that are uploaded
+// concurrently to pre-signed URLs; failed parts are retried, and an upload
+// interrupted by an error can be resumed with Resume.
 type AttachmentUploader struct {
 	client       *Client
+	partSize     int64
+	concurrency  int
+	maxRetries   int
+	retryDelay   time.Duration
 	maxEmbedSize int
 }
 
@@ -196,28 +236,33 @@ type AttachmentUploader struct {
 func NewAttachmentUploader(client *Client) *AttachmentUploader {
 	return &AttachmentUploader{
 		client:       client,
+		partSize:     DefaultAttachmentPartSize,
+		concurrency:  4,
+		maxRetries:   3,
+		retryDelay:   500 * time.Millisecond,
 		maxEmbedSize: 1024 * 1024, // 1MB default
 	}
 }
 
 // SetMaxEmbedSize sets the maximum size for inline embedding.
+//
+// Deprecated: UploadTo uploads attachments to Opik's attachment storage
+// instead of embedding them. The size has no effect.
 func (u *AttachmentUploader) SetMaxEmbedSize(size int) {
 	u.maxEmbedSize = size
 }
 
-// Upload uploads an attachment and returns the URL or embedded data.
+// Upload returns the attachment as a data URL, or its URL if it has one.
+//
+// Deprecated: Use UploadTo, which uploads the attachment to a trace or span.
 func (u *AttachmentUploader) Upload(ctx context.Context, attachment *Attachment) (string, error) {
-	// For small files, embed inline
-	if attachment.IsEmbeddable(u.maxEmbedSize) {
-		return attachment.ToDataURL(), nil
-	}
-
-	// For larger files, would upload to S3 or similar
-	// For now, just embed anyway (server will handle storage)
 	return attachment.ToDataURL(), nil
 }
 
-// UploadMultiple uploads multiple attachments.
+// UploadMultiple returns the attachments as data URLs, like Upload.
+//
+// Deprecated: Use UploadMultipleTo, which uploads the attachments to a trace
+// or span.
 func (u *AttachmentUploader) UploadMultiple(ctx context.Context, attachments []*Attachment) ([]string, error) {
 	urls := make([]string, len(attachments))
 	for i, a := range attachments {
@@ -230,6 +275,464 @@ func (u *AttachmentUploader) UploadMultiple(ctx context.Context, attachments []*
 	return urls, nil
 }
 
+// SetPartSize sets the size of each uploaded part.
+// Sizes below MinAttachmentPartSize are raised to it.
+func (u *AttachmentUploader) SetPartSize(size int64) {
+	u.partSize = max(size, MinAttachmentPartSize)
+}
+
+// SetConcurrency sets how many parts are uploaded at the same time.
+func (u *AttachmentUploader) SetConcurrency(n int) {
+	u.concurrency = max(n, 1)
+}
+
+// SetMaxRetries sets how many times a failed part upload is retried.
+func (u *AttachmentUploader) SetMaxRetries(n int) {
+	u.maxRetries = max(n, 0)
+}
+
+// MultipartUpload is the state of a multipart attachment upload.
+// It is returned in an AttachmentUploadError when an upload fails part way,
+// and can be passed to AttachmentUploader.Resume to upload the missing parts.
+type MultipartUpload struct {
+	EntityType  AttachmentEntityType
+	EntityID    string
+	ProjectName string
+	FileName    string
+	MimeType    string
+	FileSize    int64
+	UploadID    string
+	PartSize    int64
+	// URLs holds the pre-signed upload URL of each part.
+	URLs []string
+	// ETags holds the ETag of each uploaded part, or "" if the part has not
+	// been uploaded yet.
+	ETags []string
+}
+
+// Complete reports whether every part has been uploaded.
+func (m *MultipartUpload) Complete() bool {
+	for _, etag := range m.ETags {
+		if etag == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// AttachmentUploadError is returned when a multipart upload fails after it
+// was started. Upload can be passed to AttachmentUploader.Resume.
+type AttachmentUploadError struct {
+	Upload *MultipartUpload
+	Err    error
+}
+
+func (e *AttachmentUploadError) Error() string {
+	return fmt.Sprintf("opik: upload of attachment %q failed: %v", e.Upload.FileName, e.Err)
+}
+
+func (e *AttachmentUploadError) Unwrap() error {
+	return e.Err
+}
+
+// UploadTo uploads an attachment and attaches it to the trace or span with
+// the given ID. The project defaults to the client's project; set it with
+// WithAttachmentProjectName if the entity belongs to another project.
+// Attachments without data, such as those created by NewAttachmentFromURL,
+// cannot be uploaded.
+func (u *AttachmentUploader) UploadTo(ctx context.Context, entityType AttachmentEntityType, entityID string, attachment *Attachment, opts ...AttachmentQueryOption) error {
+	if attachment.Name == "" || len(attachment.Data) == 0 {
+		return fmt.Errorf("%w: attachment %q has no name or data", ErrInvalidInput, attachment.Name)
+	}
+	entityUUID, err := uuid.Parse(entityID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid entity ID %q", ErrInvalidInput, entityID)
+	}
+
+	upload := &MultipartUpload{
+		EntityType:  entityType,
+		EntityID:    entityID,
+		ProjectName: u.client.attachmentProjectName(opts),
+		FileName:    attachment.Name,
+		MimeType:    attachment.MimeType,
+		FileSize:    int64(len(attachment.Data)),
+		PartSize:    u.partSize,
+	}
+	numParts := (upload.FileSize + upload.PartSize - 1) / upload.PartSize
+
+	res, err := u.client.apiClient.StartMultiPartUpload(ctx, api.NewOptStartMultipartUploadRequest(api.StartMultipartUploadRequest{
+		FileName:       upload.FileName,
+		NumOfFileParts: int32(numParts), //nolint:gosec // G115: part count is bounded by the part size
+		MimeType:       optString(upload.MimeType),
+		ProjectName:    api.NewOptString(upload.ProjectName),
+		EntityType:     api.StartMultipartUploadRequestEntityType(entityType),
+		EntityID:       entityUUID,
+		Path:           u.client.attachmentPath(),
+	}))
+	if err != nil {
+		return err
+	}
+
+	switch r := res.(type) {
+	case *api.StartMultipartUploadResponse:
+		upload.UploadID = r.UploadID
+		upload.URLs = r.PreSignUrls
+	case *api.StartMultiPartUploadUnauthorized:
+		return newAPIError(401, (*api.ErrorMessage)(r))
+	case *api.StartMultiPartUploadForbidden:
+		return newAPIError(403, (*api.ErrorMessage)(r))
+	}
+
+	if upload.UploadID == localUploadID {
+		return u.uploadLocal(ctx, upload, attachment.Data)
+	}
+
+	if len(upload.URLs) != int(numParts) {
+		return fmt.Errorf("opik: got %d upload URLs for %d parts", len(upload.URLs), numParts)
+	}
+	upload.ETags = make([]string, numParts)
+
+	return u.Resume(ctx, upload, attachment)
+}
+
+// Resume uploads the parts of upload that have not been uploaded yet and
+// completes the upload. attachment must be the attachment the upload was
+// started for.
+func (u *AttachmentUploader) Resume(ctx context.Context, upload *MultipartUpload, attachment *Attachment) error {
+	if int64(len(attachment.Data)) != upload.FileSize {
+		return fmt.Errorf("%w: attachment size does not match the upload", ErrInvalidInput)
+	}
+
+	if err := u.uploadParts(ctx, upload, attachment.Data); err != nil {
+		return &AttachmentUploadError{Upload: upload, Err: err}
+	}
+
+	parts := make([]api.MultipartUploadPart, len(upload.ETags))
+	for i, etag := range upload.ETags {
+		parts[i] = api.MultipartUploadPart{
+			ETag:       etag,
+			PartNumber: int32(i + 1), //nolint:gosec // G115: part count is bounded by the part size
+		}
+	}
+
+	entityUUID, err := uuid.Parse(upload.EntityID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid entity ID %q", ErrInvalidInput, upload.EntityID)
+	}
+
+	res, err := u.client.apiClient.CompleteMultiPartUpload(ctx, api.NewOptCompleteMultipartUploadRequest(api.CompleteMultipartUploadRequest{
+		FileName:          upload.FileName,
+		ProjectName:       api.NewOptString(upload.ProjectName),
+		EntityType:        api.CompleteMultipartUploadRequestEntityType(upload.EntityType),
+		EntityID:          entityUUID,
+		FileSize:          upload.FileSize,
+		MimeType:          optString(upload.MimeType),
+		UploadID:          upload.UploadID,
+		UploadedFileParts: parts,
+	}))
+	if err != nil {
+		return &AttachmentUploadError{Upload: upload, Err: err}
+	}
+
+	switch r := res.(type) {
+	case *api.CompleteMultiPartUploadUnauthorized:
+		return newAPIError(401, (*api.ErrorMessage)(r))
+	case *api.CompleteMultiPartUploadForbidden:
+		return newAPIError(403, (*api.ErrorMessage)(r))
+	}
+	return nil
+}
+
+// uploadParts uploads the missing parts concurrently, recording their ETags.
+func (u *AttachmentUploader) uploadParts(ctx context.Context, upload *MultipartUpload, data []byte) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, u.concurrency)
+
+	for i, etag := range upload.ETags {
+		if etag != "" {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		start := int64(i) * upload.PartSize
+		end := min(start+upload.PartSize, upload.FileSize)
+
+		wg.Add(1)
+		go func(i int, part []byte) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			etag, err := u.uploadPart(ctx, upload.URLs[i], part)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("part %d: %w", i+1, err)
+					cancel()
+				}
+				return
+			}
+			upload.ETags[i] = etag
+		}(i, data[start:end])
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// uploadPart uploads one part to its pre-signed URL, retrying on failure,
+// and returns the part's ETag.
+func (u *AttachmentUploader) uploadPart(ctx context.Context, url string, part []byte) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt <= u.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(u.retryDelay * time.Duration(attempt)):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		etag, err := u.put(ctx, u.client.httpClient.client, url, part, "")
+		if err == nil {
+			if etag == "" {
+				return "", errors.New("storage returned no ETag")
+			}
+			return etag, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// uploadLocal sends the whole file to an Opik backend that stores
+// attachments itself.
+func (u *AttachmentUploader) uploadLocal(ctx context.Context, upload *MultipartUpload, data []byte) error {
+	if len(upload.URLs) == 0 {
+		return errors.New("opik: no upload URL returned")
+	}
+	_, err := u.put(ctx, u.client.httpClient, upload.URLs[0], data, upload.MimeType)
+	return err
+}
+
+// put uploads body to url and returns the response ETag header.
+func (u *AttachmentUploader) put(ctx context.Context, client interface {
+	Do(*http.Request) (*http.Response, error)
+}, url string, body []byte, mimeType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	if mimeType != "" {
+		req.Header.Set("Content-Type", mimeType)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("upload failed with status %s", resp.Status)
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+// UploadMultipleTo uploads multiple attachments to the same trace or span.
+func (u *AttachmentUploader) UploadMultipleTo(ctx context.Context, entityType AttachmentEntityType, entityID string, attachments []*Attachment, opts ...AttachmentQueryOption) error {
+	for _, a := range attachments {
+		if err := u.UploadTo(ctx, entityType, entityID, a, opts...); err != nil {
+			return fmt.Errorf("failed to upload attachment %s: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+// AttachmentInfo describes an attachment stored in Opik.
+type AttachmentInfo struct {
+	FileName string
+	FileSize int64
+	MimeType string
+	// Link is the download URL of the attachment.
+	Link string
+}
+
+// AttachmentQueryOption is a functional option for attachment operations.
+type AttachmentQueryOption func(*attachmentQueryOptions)
+
+type attachmentQueryOptions struct {
+	projectName string
+}
+
+// WithAttachmentProjectName sets the project of the trace or span the
+// attachments belong to. Defaults to the client's project.
+func WithAttachmentProjectName(name string) AttachmentQueryOption {
+	return func(o *attachmentQueryOptions) {
+		o.projectName = name
+	}
+}
+
+// attachmentProjectName resolves the project name for attachment options.
+func (c *Client) attachmentProjectName(opts []AttachmentQueryOption) string {
+	options := &attachmentQueryOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.projectName == "" {
+		return c.projectName
+	}
+	return options.projectName
+}
+
+// attachmentPath returns the base64-encoded Opik URL, which the attachment
+// API uses to build download links.
+func (c *Client) attachmentPath() string {
+	return base64.StdEncoding.EncodeToString([]byte(c.config.URL))
+}
+
+// AttachmentList lists the attachments of a trace or span.
+func (c *Client) AttachmentList(ctx context.Context, entityType AttachmentEntityType, entityID string, page, size int, opts ...AttachmentQueryOption) ([]*AttachmentInfo, error) {
+	entityUUID, err := uuid.Parse(entityID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid entity ID %q", ErrInvalidInput, entityID)
+	}
+	projectID, err := c.projectID(ctx, c.attachmentProjectName(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.apiClient.AttachmentList(ctx, api.AttachmentListParams{
+		Page:       api.NewOptInt32(int32(page)), //nolint:gosec // G115: page values are bounded by API limits
+		Size:       api.NewOptInt32(int32(size)), //nolint:gosec // G115: size values are bounded by API limits
+		ProjectID:  projectID,
+		EntityType: api.AttachmentListEntityType(entityType),
+		EntityID:   entityUUID,
+		Path:       c.attachmentPath(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := res.(type) {
+	case *api.AttachmentPage:
+		attachments := make([]*AttachmentInfo, 0, len(r.Content))
+		for _, a := range r.Content {
+			attachments = append(attachments, &AttachmentInfo{
+				FileName: a.FileName,
+				FileSize: a.FileSize,
+				MimeType: a.MimeType,
+				Link:     a.Link.Or(""),
+			})
+		}
+		return attachments, nil
+	case *api.AttachmentListUnauthorized:
+		return nil, newAPIError(401, (*api.ErrorMessage)(r))
+	case *api.AttachmentListForbidden:
+		return nil, newAPIError(403, (*api.ErrorMessage)(r))
+	default:
+		return nil, nil
+	}
+}
+
+// DeleteAttachments deletes attachments of a trace or span by file name.
+func (c *Client) DeleteAttachments(ctx context.Context, entityType AttachmentEntityType, entityID string, fileNames []string, opts ...AttachmentQueryOption) error {
+	entityUUID, err := uuid.Parse(entityID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid entity ID %q", ErrInvalidInput, entityID)
+	}
+	projectID, err := c.projectID(ctx, c.attachmentProjectName(opts))
+	if err != nil {
+		return err
+	}
+
+	// The API spec declares the wrong request schema for this endpoint, so
+	// the generated client cannot be used.
+	_, err = c.doJSON(ctx, http.MethodPost, "/v1/private/attachment/delete", map[string]any{
+		"file_names":   fileNames,
+		"entity_type":  entityType,
+		"entity_id":    entityUUID,
+		"container_id": projectID,
+	}, nil)
+	return err
+}
+
+// projectID looks up the ID of a project by name.
+func (c *Client) projectID(ctx context.Context, name string) (uuid.UUID, error) {
+	res, err := c.apiClient.RetrieveProject(ctx, api.NewOptProjectRetrieveDetailed(api.ProjectRetrieveDetailed{
+		Name: name,
+	}))
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if p, ok := res.(*api.ProjectDetailed); ok && p.ID.Set {
+		return p.ID.Value, nil
+	}
+	return uuid.Nil, fmt.Errorf("%w: %s", ErrProjectNotFound, name)
+}
+
+// extractAttachments replaces inline base64 media in a trace or span input or
+// output with attachment references, using the client's extractor.
+func (c *Client) extractAttachments(field string, value any) (any, []*Attachment) {
+	if c == nil || c.extractor == nil {
+		return value, nil
+	}
+	return c.extractor.ExtractInline(field, value)
+}
+
+// uploadAttachments uploads attachments to a trace or span. Attachments
+// without data, such as URL attachments, are skipped. With a batcher, the
+// uploads are queued and failures are reported through OnError; otherwise
+// the attachments are uploaded in order, and the ones not uploaded are
+// returned with the error.
+func (c *Client) uploadAttachments(ctx context.Context, entityType AttachmentEntityType, entityID, projectName string, attachments []*Attachment) ([]*Attachment, error) {
+	attachments = slices.DeleteFunc(slices.Clone(attachments), func(a *Attachment) bool {
+		return len(a.Data) == 0
+	})
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+
+	if c.batcher != nil {
+		for _, a := range attachments {
+			c.batcher.Add(AttachmentBatchItem{
+				EntityType:  entityType,
+				EntityID:    entityID,
+				ProjectName: projectName,
+				Attachment:  a,
+			})
+		}
+		return nil, nil
+	}
+
+	uploader := NewAttachmentUploader(c)
+	for i, a := range attachments {
+		if err := uploader.UploadTo(ctx, entityType, entityID, a, WithAttachmentProjectName(projectName)); err != nil {
+			return attachments[i:], fmt.Errorf("failed to upload attachment %s: %w", a.Name, err)
+		}
+	}
+	return nil, nil
+}
+
 // AttachmentOption is a functional option for attachments.
 type AttachmentOption func(*attachmentOptions)
 
@@ -267,14 +770,53 @@ func WithImageAttachment(name string, data []byte) AttachmentOption {
 	}
 }
 
+// collectAttachments applies attachment options and returns the attachments.
+func collectAttachments(opts []AttachmentOption) []*Attachment {
+	options := &attachmentOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options.attachments
+}
+
+// WithTraceAttachments attaches files to the trace, e.g.
+// WithTraceAttachments(WithFileAttachment("report.pdf")). The attachments
+// are uploaded after the trace is sent; a failed upload does not fail the
+// trace operation. See Trace.AddAttachment.
+func WithTraceAttachments(opts ...AttachmentOption) TraceOption {
+	attachments := collectAttachments(opts)
+	return func(o *traceOptions) {
+		o.attachments = append(o.attachments, attachments...)
+	}
+}
+
+// WithSpanAttachments attaches files to the span, e.g.
+// WithSpanAttachments(WithImageAttachment("screenshot.png", data)). The
+// attachments are uploaded after the span is sent; a failed upload does not
+// fail the span operation. See Span.AddAttachment.
+func WithSpanAttachments(opts ...AttachmentOption) SpanOption {
+	attachments := collectAttachments(opts)
+	return func(o *spanOptions) {
+		o.attachments = append(o.attachments, attachments...)
+	}
+}
+
+// DefaultExtractionMinSize is the default size, in bytes of base64 text,
+// from which inline media is extracted into attachments.
+const DefaultExtractionMinSize = 64 * 1024
+
 // AttachmentExtractor extracts attachments from LLM responses.
 type AttachmentExtractor struct {
 	enabled bool
+	minSize int
 }
 
 // NewAttachmentExtractor creates a new attachment extractor.
 func NewAttachmentExtractor() *AttachmentExtractor {
-	return &AttachmentExtractor{enabled: true}
+	return &AttachmentExtractor{
+		enabled: true,
+		minSize: DefaultExtractionMinSize,
+	}
 }
 
 // SetEnabled enables or disables attachment extraction.
@@ -282,6 +824,131 @@ func (e *AttachmentExtractor) SetEnabled(enabled bool) {
 	e.enabled = enabled
 }
 
+// SetMinSize sets the size, in bytes of base64 text, from which inline media
+// is extracted by ExtractInline. Smaller media stays inline.
+func (e *AttachmentExtractor) SetMinSize(size int) {
+	e.minSize = size
+}
+
+// ExtractInline replaces inline base64 media in a trace or span input or
+// output with attachment references such as "[input-attachment-1-1700000000000.png]",
+// and returns the new value with the extracted attachments. field names the
+// payload field and prefixes the attachment names.
+//
+// Both data URLs ("data:image/png;base64,...") and base64 sources of the
+// form {"type": "base64", "media_type": "image/png", "data": "..."} are
+// extracted. value itself is never modified; if nothing is extracted it is
+// returned as is.
+func (e *AttachmentExtractor) ExtractInline(field string, value any) (any, []*Attachment) {
+	if !e.enabled || value == nil {
+		return value, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil || !bytes.Contains(data, []byte("base64")) {
+		return value, nil
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return value, nil
+	}
+
+	var attachments []*Attachment
+	tree = e.replaceInline(field, tree, &attachments)
+	if len(attachments) == 0 {
+		return value, nil
+	}
+	return tree, attachments
+}
+
+// replaceInline replaces inline media within a decoded JSON value.
+func (e *AttachmentExtractor) replaceInline(field string, node any, attachments *[]*Attachment) any {
+	switch v := node.(type) {
+	case string:
+		if ref, ok := e.extractDataURL(field, v, attachments); ok {
+			return ref
+		}
+	case map[string]any:
+		if v["type"] == "base64" {
+			mimeType, _ := v["media_type"].(string)
+			data, _ := v["data"].(string)
+			if ref, ok := e.extractBase64(field, mimeType, data, attachments); ok {
+				v["data"] = ref
+				return v
+			}
+		}
+		for k, child := range v {
+			v[k] = e.replaceInline(field, child, attachments)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = e.replaceInline(field, child, attachments)
+		}
+	}
+	return node
+}
+
+// extractDataURL extracts a base64 data URL and returns its reference.
+func (e *AttachmentExtractor) extractDataURL(field, s string, attachments *[]*Attachment) (string, bool) {
+	if len(s) < e.minSize || !strings.HasPrefix(s, "data:") {
+		return "", false
+	}
+	header, data, ok := strings.Cut(s, ",")
+	if !ok || !strings.HasSuffix(header, ";base64") {
+		return "", false
+	}
+	mimeType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	return e.extractBase64(field, mimeType, data, attachments)
+}
+
+// extractBase64 decodes base64 media into an attachment and returns its reference.
+func (e *AttachmentExtractor) extractBase64(field, mimeType, data string, attachments *[]*Attachment) (string, bool) {
+	if len(data) < e.minSize {
+		return "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", false
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(decoded)
+	}
+
+	name := fmt.Sprintf("%s-attachment-%d-%d%s", field, len(*attachments)+1, time.Now().UnixMilli(), extensionForMimeType(mimeType))
+	*attachments = append(*attachments, &Attachment{
+		Name:     name,
+		Type:     mimeTypeToAttachmentType(mimeType),
+		MimeType: mimeType,
+		Data:     decoded,
+		Base64:   data,
+	})
+	return "[" + name + "]", true
+}
+
+// extensionForMimeType returns the file extension for a MIME type.
+func extensionForMimeType(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	case "application/pdf":
+		return ".pdf"
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	case "audio/mpeg":
+		return ".mp3"
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
 // Extract extracts attachments from content.
 func (e *AttachmentExtractor) Extract(content any) []*Attachment {
 	if !e.enabled {
@@ -2,9 +2,18 @@ package opik
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
 )
 
 func TestAttachmentTypes(t *testing.T) {
@@ -261,6 +270,12 @@ func TestNewAttachmentUploader(t *testing.T) {
 	if uploader.maxEmbedSize != 1024*1024 {
 		t.Errorf("default maxEmbedSize = %d, want %d", uploader.maxEmbedSize, 1024*1024)
 	}
+	if uploader.partSize != DefaultAttachmentPartSize {
+		t.Errorf("default partSize = %d, want %d", uploader.partSize, DefaultAttachmentPartSize)
+	}
+	if uploader.concurrency != 4 {
+		t.Errorf("default concurrency = %d, want 4", uploader.concurrency)
+	}
 }
 
 func TestAttachmentUploaderSetMaxEmbedSize(t *testing.T) {
@@ -272,6 +287,38 @@ func TestAttachmentUploaderSetMaxEmbedSize(t *testing.T) {
 	}
 }
 
+func TestAttachmentUploaderUploadReturnsDataURL(t *testing.T) {
+	uploader := NewAttachmentUploader(nil)
+	a := NewTextAttachment("notes.txt", "hello")
+
+	url, err := uploader.Upload(context.Background(), a)
+	if err != nil {
+		t.Fatalf("Upload error: %v", err)
+	}
+	if url != a.ToDataURL() {
+		t.Errorf("Upload = %q, want %q", url, a.ToDataURL())
+	}
+
+	urls, err := uploader.UploadMultiple(context.Background(), []*Attachment{a, a})
+	if err != nil || len(urls) != 2 {
+		t.Errorf("UploadMultiple = %v, %v", urls, err)
+	}
+}
+
+func TestAttachmentUploaderSetPartSize(t *testing.T) {
+	uploader := NewAttachmentUploader(nil)
+
+	uploader.SetPartSize(16 * 1024 * 1024)
+	if uploader.partSize != 16*1024*1024 {
+		t.Errorf("partSize = %d, want %d", uploader.partSize, 16*1024*1024)
+	}
+
+	uploader.SetPartSize(1024)
+	if uploader.partSize != MinAttachmentPartSize {
+		t.Errorf("partSize = %d, want minimum %d", uploader.partSize, MinAttachmentPartSize)
+	}
+}
+
 func TestAttachmentExtractorEnabled(t *testing.T) {
 	extractor := NewAttachmentExtractor()
 
@@ -418,3 +465,523 @@ func TestWithImageAttachmentOption(t *testing.T) {
 		t.Error("attachment type should be image")
 	}
 }
+
+const testEntityID = "01890a5d-ac96-774b-bcce-b302099a8057"
+
+// onUploadStart responds to upload-start requests with the given upload ID
+// and part URLs relative to the mock server.
+func onUploadStart(ms *testutil.MockServer, uploadID string, paths ...string) {
+	urls := make([]string, len(paths))
+	for i, p := range paths {
+		urls[i] = ms.URL() + p
+	}
+	ms.OnPost("/v1/private/attachment/upload-start").RespondJSON(http.StatusOK, map[string]any{
+		"upload_id":     uploadID,
+		"pre_sign_urls": urls,
+	})
+}
+
+func TestAttachmentUploaderMultipart(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	onUploadStart(ms, "upload-1", "/s3/1", "/s3/2", "/s3/3")
+	for i, etag := range []string{`"e1"`, `"e2"`, `"e3"`} {
+		ms.OnPut("/s3/"+string(rune('1'+i))).WithHeaders(map[string]string{"ETag": etag}).Respond(http.StatusOK, nil)
+	}
+	ms.OnPost("/v1/private/attachment/upload-complete").Respond(http.StatusNoContent, nil)
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	uploader := NewAttachmentUploader(client)
+	uploader.partSize = 4
+
+	a := NewAttachmentFromBytes("report.pdf", []byte("0123456789"), "application/pdf")
+	if err := uploader.UploadTo(context.Background(), AttachmentEntitySpan, testEntityID, a); err != nil {
+		t.Fatalf("UploadTo error: %v", err)
+	}
+
+	var start struct {
+		FileName       string `json:"file_name"`
+		NumOfFileParts int    `json:"num_of_file_parts"`
+		ProjectName    string `json:"project_name"`
+		EntityType     string `json:"entity_type"`
+		EntityID       string `json:"entity_id"`
+		Path           string `json:"path"`
+	}
+	if err := json.Unmarshal(ms.RequestsForPath("/v1/private/attachment/upload-start")[0].Body, &start); err != nil {
+		t.Fatalf("unmarshal start: %v", err)
+	}
+	if start.NumOfFileParts != 3 || start.FileName != "report.pdf" || start.EntityType != "span" ||
+		start.EntityID != testEntityID || start.ProjectName != "attachments-project" {
+		t.Errorf("unexpected start request %+v", start)
+	}
+	if path, _ := base64.StdEncoding.DecodeString(start.Path); string(path) != ms.URL() {
+		t.Errorf("path = %q, want base64 of %q", start.Path, ms.URL())
+	}
+
+	var parts []string
+	for _, p := range []string{"/s3/1", "/s3/2", "/s3/3"} {
+		reqs := ms.RequestsForPath(p)
+		if len(reqs) != 1 {
+			t.Fatalf("%s got %d requests, want 1", p, len(reqs))
+		}
+		if reqs[0].Headers.Get("Authorization") != "" {
+			t.Errorf("%s: pre-signed URL request should not carry the API key", p)
+		}
+		parts = append(parts, string(reqs[0].Body))
+	}
+	if strings.Join(parts, "|") != "0123|4567|89" {
+		t.Errorf("parts = %q", parts)
+	}
+
+	var complete struct {
+		UploadID string `json:"upload_id"`
+		FileSize int64  `json:"file_size"`
+		Parts    []struct {
+			ETag       string `json:"e_tag"`
+			PartNumber int    `json:"part_number"`
+		} `json:"uploaded_file_parts"`
+	}
+	if err := json.Unmarshal(ms.RequestsForPath("/v1/private/attachment/upload-complete")[0].Body, &complete); err != nil {
+		t.Fatalf("unmarshal complete: %v", err)
+	}
+	if complete.UploadID != "upload-1" || complete.FileSize != 10 || len(complete.Parts) != 3 {
+		t.Fatalf("unexpected complete request %+v", complete)
+	}
+	for i, p := range complete.Parts {
+		if p.PartNumber != i+1 || p.ETag != `"e`+string(rune('1'+i))+`"` {
+			t.Errorf("part %d = %+v", i, p)
+		}
+	}
+}
+
+func TestAttachmentUploaderResume(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	onUploadStart(ms, "upload-1", "/s3/1", "/s3/2")
+	ms.OnPut("/s3/1").WithHeaders(map[string]string{"ETag": "e1"}).Respond(http.StatusOK, nil)
+	ms.OnPut("/s3/2").Respond(http.StatusInternalServerError, nil)
+	ms.OnPost("/v1/private/attachment/upload-complete").Respond(http.StatusNoContent, nil)
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	uploader := NewAttachmentUploader(client)
+	uploader.partSize = 4
+	uploader.retryDelay = 0
+	uploader.SetMaxRetries(1)
+
+	ctx := context.Background()
+	a := NewAttachmentFromBytes("notes.txt", []byte("abcdefgh"), "text/plain")
+
+	err := uploader.UploadTo(ctx, AttachmentEntityTrace, testEntityID, a)
+	var uploadErr *AttachmentUploadError
+	if !errors.As(err, &uploadErr) {
+		t.Fatalf("UploadTo error = %v, want AttachmentUploadError", err)
+	}
+	if got := ms.RouteCallCount(http.MethodPut, "/s3/2"); got != 2 {
+		t.Errorf("failed part attempts = %d, want 2", got)
+	}
+	if uploadErr.Upload.Complete() || uploadErr.Upload.ETags[0] != "e1" {
+		t.Errorf("ETags = %q, want only the first part", uploadErr.Upload.ETags)
+	}
+	if ms.RouteCallCount(http.MethodPost, "/v1/private/attachment/upload-complete") != 0 {
+		t.Error("incomplete upload should not be completed")
+	}
+
+	ms.OnPut("/s3/2").WithHeaders(map[string]string{"ETag": "e2"}).Respond(http.StatusOK, nil)
+	if err := uploader.Resume(ctx, uploadErr.Upload, a); err != nil {
+		t.Fatalf("Resume error: %v", err)
+	}
+	if got := ms.RouteCallCount(http.MethodPut, "/s3/1"); got != 1 {
+		t.Errorf("uploaded part re-sent: %d requests, want 1", got)
+	}
+	if ms.RouteCallCount(http.MethodPost, "/v1/private/attachment/upload-complete") != 1 {
+		t.Error("resumed upload should be completed")
+	}
+}
+
+func TestAttachmentUploaderLocalBackend(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	onUploadStart(ms, localUploadID, "/v1/private/attachment/upload?file_name=shot.png")
+	ms.OnPut("/v1/private/attachment/upload").Respond(http.StatusNoContent, nil)
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	data := []byte{0x89, 0x50, 0x4E, 0x47}
+	a := NewImageAttachment("shot.png", data, "image/png")
+
+	if err := NewAttachmentUploader(client).UploadTo(context.Background(), AttachmentEntityTrace, testEntityID, a); err != nil {
+		t.Fatalf("UploadTo error: %v", err)
+	}
+
+	reqs := ms.RequestsForPath("/v1/private/attachment/upload")
+	if len(reqs) != 1 {
+		t.Fatalf("got %d upload requests, want 1", len(reqs))
+	}
+	if !bytes.Equal(reqs[0].Body, data) {
+		t.Error("uploaded data doesn't match")
+	}
+	if reqs[0].Headers.Get("Authorization") != "test-key" {
+		t.Error("local upload should be authenticated")
+	}
+	if ms.RouteCallCount(http.MethodPost, "/v1/private/attachment/upload-complete") != 0 {
+		t.Error("local upload should not be completed")
+	}
+}
+
+func TestAttachmentUploaderRequiresData(t *testing.T) {
+	uploader := NewAttachmentUploader(nil)
+	a := NewAttachmentFromURL("remote.png", "https://example.com/remote.png", AttachmentTypeImage)
+
+	err := uploader.UploadTo(context.Background(), AttachmentEntityTrace, testEntityID, a)
+	if !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("UploadTo error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestSpanAttachmentsUploadedOnSend(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	onUploadStart(ms, localUploadID, "/v1/private/attachment/upload")
+	ms.OnPut("/v1/private/attachment/upload").Respond(http.StatusNoContent, nil)
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	ctx := context.Background()
+
+	trace, err := client.Trace(ctx, "trace")
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	span, err := trace.Span(ctx, "render", WithSpanAttachments(WithTextAttachment("page.html", "<html></html>")))
+	if err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+
+	var start struct {
+		FileName   string `json:"file_name"`
+		EntityType string `json:"entity_type"`
+		EntityID   string `json:"entity_id"`
+	}
+	reqs := ms.RequestsForPath("/v1/private/attachment/upload-start")
+	if len(reqs) != 1 {
+		t.Fatalf("got %d upload-start requests, want 1", len(reqs))
+	}
+	if err := json.Unmarshal(reqs[0].Body, &start); err != nil {
+		t.Fatalf("unmarshal start: %v", err)
+	}
+	if start.FileName != "page.html" || start.EntityType != "span" || start.EntityID != span.ID() {
+		t.Errorf("unexpected start request %+v", start)
+	}
+
+	// Sent attachments are not uploaded again
+	if err := span.End(ctx); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	if got := len(ms.RequestsForPath("/v1/private/attachment/upload-start")); got != 1 {
+		t.Errorf("got %d upload-start requests after End, want 1", got)
+	}
+}
+
+func TestFailedUploadDoesNotFailTrace(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/attachment/upload-start").Respond(http.StatusBadRequest, nil)
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	ctx := context.Background()
+
+	trace, err := client.Trace(ctx, "trace",
+		WithTraceAttachments(
+			WithTextAttachment("notes.txt", "hello"),
+			WithAttachment(NewAttachmentFromURL("remote.png", "https://example.com/remote.png", AttachmentTypeImage)),
+		))
+	if err != nil || trace == nil {
+		t.Fatalf("Trace = %v, %v; want the trace despite the failed upload", trace, err)
+	}
+
+	// The failed upload is retried, and reported, with the next update.
+	// The URL attachment is never uploaded.
+	if err := trace.End(ctx); err == nil {
+		t.Error("End error = nil, want the upload error")
+	}
+	reqs := ms.RequestsForPath("/v1/private/attachment/upload-start")
+	if len(reqs) != 2 {
+		t.Fatalf("got %d upload-start requests, want 2", len(reqs))
+	}
+	for _, req := range reqs {
+		if !bytes.Contains(req.Body, []byte(`"notes.txt"`)) {
+			t.Errorf("unexpected upload-start request %s", req.Body)
+		}
+	}
+}
+
+func TestBatchingClientUploadsAttachmentsAsync(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/attachment/upload-start").Respond(http.StatusBadRequest, nil)
+
+	var mu sync.Mutex
+	var failed []BatchItem
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	config.OnError = func(err *BatchError) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, err.Items...)
+	}
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	defer client.Close(time.Second)
+
+	ctx := context.Background()
+	trace, err := client.Trace(ctx, "trace", WithTraceAttachments(WithTextAttachment("notes.txt", "hello")))
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if n := len(ms.RequestsForPath("/v1/private/attachment/upload-start")); n != 0 {
+		t.Errorf("upload started before the flush: %d requests", n)
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 {
+		t.Fatalf("failed items = %v, want the upload", failed)
+	}
+	item, ok := failed[0].(AttachmentBatchItem)
+	if !ok || item.EntityType != AttachmentEntityTrace || item.EntityID != trace.ID() || item.Attachment.Name != "notes.txt" {
+		t.Errorf("failed item = %+v", failed[0])
+	}
+}
+
+func TestAttachmentList(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	projectID := "01890a5d-ac96-774b-bcce-b302099a8000"
+	ms.OnPost("/v1/private/projects/retrieve").RespondJSON(http.StatusOK, map[string]any{
+		"id":   projectID,
+		"name": "attachments-project",
+	})
+	ms.OnGet("/v1/private/attachment/list").RespondJSON(http.StatusOK, map[string]any{
+		"page":  1,
+		"size":  1,
+		"total": 1,
+		"content": []map[string]any{{
+			"file_name": "report.pdf",
+			"file_size": 1024,
+			"mime_type": "application/pdf",
+			"link":      "https://example.com/report.pdf",
+		}},
+	})
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	attachments, err := client.AttachmentList(context.Background(), AttachmentEntityTrace, testEntityID, 1, 10)
+	if err != nil {
+		t.Fatalf("AttachmentList error: %v", err)
+	}
+	if len(attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(attachments))
+	}
+	got := attachments[0]
+	if got.FileName != "report.pdf" || got.FileSize != 1024 || got.MimeType != "application/pdf" || got.Link != "https://example.com/report.pdf" {
+		t.Errorf("unexpected attachment %+v", got)
+	}
+}
+
+func TestDeleteAttachments(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	projectID := "01890a5d-ac96-774b-bcce-b302099a8000"
+	ms.OnPost("/v1/private/projects/retrieve").RespondJSON(http.StatusOK, map[string]any{
+		"id":   projectID,
+		"name": "other-project",
+	})
+	ms.OnPost("/v1/private/attachment/delete").Respond(http.StatusNoContent, nil)
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	err := client.DeleteAttachments(context.Background(), AttachmentEntitySpan, testEntityID,
+		[]string{"a.png", "b.png"}, WithAttachmentProjectName("other-project"))
+	if err != nil {
+		t.Fatalf("DeleteAttachments error: %v", err)
+	}
+
+	var retrieve struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(ms.RequestsForPath("/v1/private/projects/retrieve")[0].Body, &retrieve); err != nil {
+		t.Fatalf("unmarshal retrieve: %v", err)
+	}
+	if retrieve.Name != "other-project" {
+		t.Errorf("project name = %q, want other-project", retrieve.Name)
+	}
+
+	var del struct {
+		FileNames   []string `json:"file_names"`
+		EntityType  string   `json:"entity_type"`
+		EntityID    string   `json:"entity_id"`
+		ContainerID string   `json:"container_id"`
+	}
+	if err := json.Unmarshal(ms.RequestsForPath("/v1/private/attachment/delete")[0].Body, &del); err != nil {
+		t.Fatalf("unmarshal delete: %v", err)
+	}
+	if strings.Join(del.FileNames, ",") != "a.png,b.png" || del.EntityType != "span" ||
+		del.EntityID != testEntityID || del.ContainerID != projectID {
+		t.Errorf("unexpected delete request %+v", del)
+	}
+}
+
+func TestDeleteAttachmentsProjectNotFound(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/projects/retrieve").RespondJSON(http.StatusNotFound, map[string]any{
+		"errors": []string{"Project not found"},
+	})
+
+	client := newTestClient(t, ms, WithProjectName("attachments-project"))
+	err := client.DeleteAttachments(context.Background(), AttachmentEntitySpan, testEntityID, []string{"a.png"})
+	if !IsNotFound(err) {
+		t.Errorf("DeleteAttachments error = %v, want not found", err)
+	}
+}
+
+func TestWithSpanAttachmentsOption(t *testing.T) {
+	opts := defaultSpanOptions()
+
+	WithSpanAttachments(WithTextAttachment("a.txt", "a"), WithTextAttachment("b.txt", "b"))(opts)
+
+	if len(opts.attachments) != 2 || opts.attachments[1].Name != "b.txt" {
+		t.Errorf("attachments = %v, want a.txt and b.txt", opts.attachments)
+	}
+}
+
+func TestAttachmentExtractorExtractInline(t *testing.T) {
+	extractor := NewAttachmentExtractor()
+	extractor.SetMinSize(8)
+
+	image := bytes.Repeat([]byte{0x89, 0x50, 0x4E, 0x47}, 8)
+	b64 := base64.StdEncoding.EncodeToString(image)
+	input := map[string]any{
+		"messages": []any{
+			map[string]any{
+				"role": "user",
+				"content": []any{
+					map[string]any{"type": "text", "text": "What is in this image?"},
+					map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64," + b64}},
+					map[string]any{"type": "image", "source": map[string]any{"type": "base64", "media_type": "image/jpeg", "data": b64}},
+				},
+			},
+		},
+	}
+
+	got, attachments := extractor.ExtractInline("input", input)
+
+	if len(attachments) != 2 {
+		t.Fatalf("got %d attachments, want 2", len(attachments))
+	}
+	if !bytes.Equal(attachments[0].Data, image) || attachments[0].MimeType != "image/png" {
+		t.Errorf("first attachment = %s %s", attachments[0].Name, attachments[0].MimeType)
+	}
+	if !strings.HasPrefix(attachments[0].Name, "input-attachment-1-") || !strings.HasSuffix(attachments[0].Name, ".png") {
+		t.Errorf("first attachment name = %q", attachments[0].Name)
+	}
+	if !strings.HasPrefix(attachments[1].Name, "input-attachment-2-") || !strings.HasSuffix(attachments[1].Name, ".jpg") {
+		t.Errorf("second attachment name = %q", attachments[1].Name)
+	}
+
+	content := got.(map[string]any)["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	if url := content[1].(map[string]any)["image_url"].(map[string]any)["url"]; url != "["+attachments[0].Name+"]" {
+		t.Errorf("data URL replaced with %v", url)
+	}
+	if data := content[2].(map[string]any)["source"].(map[string]any)["data"]; data != "["+attachments[1].Name+"]" {
+		t.Errorf("base64 source replaced with %v", data)
+	}
+
+	original := input["messages"].([]any)[0].(map[string]any)["content"].([]any)[1].(map[string]any)["image_url"].(map[string]any)["url"]
+	if original != "data:image/png;base64,"+b64 {
+		t.Error("input should not be modified")
+	}
+}
+
+func TestAttachmentExtractorExtractInlineBelowMinSize(t *testing.T) {
+	extractor := NewAttachmentExtractor()
+	input := map[string]any{"image": "data:text/plain;base64,SGVsbG8="}
+
+	got, attachments := extractor.ExtractInline("input", input)
+
+	if len(attachments) != 0 {
+		t.Errorf("got %d attachments, want 0", len(attachments))
+	}
+	if got.(map[string]any)["image"] != "data:text/plain;base64,SGVsbG8=" {
+		t.Error("small media should stay inline")
+	}
+}
+
+func TestAttachmentExtractorExtractInlineDisabled(t *testing.T) {
+	extractor := NewAttachmentExtractor()
+	extractor.SetMinSize(0)
+	extractor.SetEnabled(false)
+
+	_, attachments := extractor.ExtractInline("output", "data:text/plain;base64,SGVsbG8=")
+	if attachments != nil {
+		t.Error("disabled extractor should not extract")
+	}
+}
+
+func TestSpanExtractsInlineMedia(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	onUploadStart(ms, localUploadID, "/v1/private/attachment/upload")
+	ms.OnPut("/v1/private/attachment/upload").Respond(http.StatusNoContent, nil)
+
+	extractor := NewAttachmentExtractor()
+	extractor.SetMinSize(4)
+	client := newTestClient(t, ms, WithProjectName("attachments-project"), WithAttachmentExtractor(extractor))
+
+	ctx := context.Background()
+	trace, err := client.Trace(ctx, "vision")
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	image := base64.StdEncoding.EncodeToString([]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A})
+	_, err = trace.Span(ctx, "describe", WithSpanInput(map[string]any{"image": "data:image/png;base64," + image}))
+	if err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+
+	input, _ := lastItem(t, tracetest.SpanWrites(ms))["input"].(map[string]any)
+	ref, _ := input["image"].(string)
+	if !strings.HasPrefix(ref, "[input-attachment-1-") || !strings.HasSuffix(ref, ".png]") {
+		t.Errorf("span input image = %q, want an attachment reference", ref)
+	}
+
+	uploads := ms.RequestsForPath("/v1/private/attachment/upload")
+	if len(uploads) != 1 || len(uploads[0].Body) != 8 {
+		t.Errorf("got %d uploads, want the 8 byte image", len(uploads))
+	}
+}
+
+func TestWithAttachmentExtractorNilDisables(t *testing.T) {
+	client, err := NewClient(WithURL("http://localhost:5173/api"), WithAttachmentExtractor(nil))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	value := "data:text/plain;base64,SGVsbG8="
+	got, attachments := client.extractAttachments("input", value)
+	if got != value || attachments != nil {
+		t.Error("extraction should be disabled")
+	}
+}
@@ -2,8 +2,18 @@ package opik
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/agentplexus/go-opik/internal/api"
 )
 
 // BatcherConfig configures the message batcher.
@@ -12,12 +22,22 @@ type BatcherConfig struct {
 	MaxBatchSize int
 	// FlushInterval is the maximum time to wait before flushing a batch.
 	FlushInterval time.Duration
-	// MaxRetries is the maximum number of retries for failed batches.
+	// MaxRetries is the maximum number of retries for failed requests.
+	// Errors that retrying cannot fix, such as invalid requests, and an
+	// open circuit breaker are not retried. Batched requests bypass the
+	// client's RetryPolicy.
 	MaxRetries int
 	// RetryDelay is the initial delay between retries (doubles each retry).
 	RetryDelay time.Duration
 	// Workers is the number of background workers for processing batches.
 	Workers int
+	// OnError is called with the items that could not be delivered after all
+	// retries. It is called from a background worker and must not block.
+	OnError func(*BatchError)
+	// Spool, if set, persists items that could not be delivered because of
+	// a transient failure to disk and replays them later, instead of
+	// reporting them to OnError. See SpoolConfig.
+	Spool *SpoolConfig
 }
 
 // DefaultBatcherConfig returns the default batcher configuration.
@@ -37,31 +57,72 @@ type BatchItem interface {
 	Type() string
 }
 
-// TraceBatchItem represents a trace to be created.
+// TraceBatchItem represents a trace create or update operation.
+// It carries a snapshot of the trace's state at the time it was queued.
 type TraceBatchItem struct {
 	Trace *Trace
+
+	create bool
+	write  api.TraceWrite
 }
 
 func (t TraceBatchItem) Type() string { return "trace" }
 
-// SpanBatchItem represents a span to be created.
+// SpanBatchItem represents a span create or update operation.
+// It carries a snapshot of the span's state at the time it was queued.
 type SpanBatchItem struct {
 	Span *Span
+
+	create bool
+	write  api.SpanWrite
 }
 
 func (s SpanBatchItem) Type() string { return "span" }
 
 // FeedbackBatchItem represents a feedback score to be logged.
 type FeedbackBatchItem struct {
-	EntityType string // "trace" or "span"
-	EntityID   string
+	EntityType string // "trace", "span" or "thread"
+	EntityID   string // trace ID, span ID or thread ID
 	Name       string
 	Value      float64
 	Reason     string
+	// ProjectName is the project the entity belongs to; defaults to the
+	// client's project. Thread IDs are only unique within a project.
+	ProjectName string
 }
 
 func (f FeedbackBatchItem) Type() string { return "feedback" }
 
+// AttachmentBatchItem represents an attachment upload to a trace or span.
+// Uploads are sent after the trace and span operations of the same flush,
+// and are not spooled.
+type AttachmentBatchItem struct {
+	EntityType  AttachmentEntityType
+	EntityID    string
+	ProjectName string
+	Attachment  *Attachment
+}
+
+func (a AttachmentBatchItem) Type() string { return "attachment" }
+
+// BatchError reports batched items that could not be delivered.
+type BatchError struct {
+	// Items are the items that were dropped. It is empty for spool errors
+	// that do not concern particular items, such as a segment that cannot
+	// be read.
+	Items []BatchItem
+	// Err is the last error encountered while sending them.
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("opik: failed to send %d batched item(s): %v", len(e.Items), e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
 // Batcher batches operations for efficient API calls.
 type Batcher struct {
 	config   BatcherConfig
@@ -73,10 +134,48 @@ type Batcher struct {
 	cancel   context.CancelFunc
 	itemChan chan BatchItem
 	flushCh  chan struct{}
+
+	// flushMu serializes flushes so that creates reach the API before
+	// updates for the same entity queued in a later flush.
+	flushMu sync.Mutex
+	// pending counts items added but not yet processed.
+	pending atomic.Int64
+	// closeMu guards closed. Add holds it for reading while queuing an
+	// item, so that Close cannot stop the workers before the item is in
+	// the channel they drain.
+	closeMu sync.RWMutex
+	closed  bool
+
+	// spool holds undelivered items; nil disables spooling
+	spool *spool
+	// spooling sends items straight to the spool, because it holds items
+	// that must be delivered first or the server is unreachable. It is
+	// guarded by flushMu.
+	spooling bool
+	// replaying is set while spooled items are sent, so that delivered
+	// ones are checkpointed. It is guarded by flushMu.
+	replaying bool
+
+	metrics *sdkMetrics
+	// gauges reports the queue and spool depth until the batcher is closed
+	gauges metric.Registration
 }
 
 // NewBatcher creates a new batcher with the given configuration.
+// If config.Spool is set but the spool cannot be opened, the batcher runs
+// without it and the error is reported through config.OnError.
 func NewBatcher(client *Client, config BatcherConfig) *Batcher {
+	var s *spool
+	if config.Spool != nil {
+		var err error
+		if s, err = openSpool(*config.Spool); err != nil && config.OnError != nil {
+			config.OnError(&BatchError{Err: err})
+		}
+	}
+	return newBatcher(client, config, s)
+}
+
+func newBatcher(client *Client, config BatcherConfig, s *spool) *Batcher {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	b := &Batcher{
@@ -87,8 +186,16 @@ func NewBatcher(client *Client, config BatcherConfig) *Batcher {
 		cancel:   cancel,
 		itemChan: make(chan BatchItem, config.MaxBatchSize*2),
 		flushCh:  make(chan struct{}, 1),
+		spool:    s,
+		metrics:  client.metrics,
 	}
 
+	b.gauges, _ = b.metrics.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(b.metrics.queueDepth, b.pending.Load())
+		o.ObserveInt64(b.metrics.spoolDepth, int64(b.Spooled()))
+		return nil
+	}, b.metrics.queueDepth, b.metrics.spoolDepth)
+
 	// Start background workers
 	for i := 0; i < config.Workers; i++ {
 		b.wg.Add(1)
@@ -99,26 +206,31 @@ func NewBatcher(client *Client, config BatcherConfig) *Batcher {
 	b.wg.Add(1)
 	go b.flushTimer()
 
+	if b.spool != nil {
+		b.wg.Add(1)
+		go b.replayLoop()
+	}
+
 	return b
 }
 
-// Add adds an item to the batch.
+// Add adds an item to the batch. Items added after the batcher is closed
+// are reported to OnError with ErrClientClosed.
 func (b *Batcher) Add(item BatchItem) {
-	select {
-	case b.itemChan <- item:
-	case <-b.ctx.Done():
+	b.metrics.enqueued.Add(context.Background(), 1, metric.WithAttributes(attrItemType.String(item.Type())))
+
+	b.closeMu.RLock()
+	defer b.closeMu.RUnlock()
+	if b.closed {
+		b.fail([]BatchItem{item}, ErrClientClosed)
+		return
 	}
+	b.pending.Add(1)
+	b.itemChan <- item
 }
 
-// Flush forces a flush of all pending items.
+// Flush forces a flush of all pending items and waits until they have been sent.
 func (b *Batcher) Flush(timeout time.Duration) error {
-	// Signal flush
-	select {
-	case b.flushCh <- struct{}{}:
-	default:
-	}
-
-	// Wait for items to drain
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
@@ -126,32 +238,55 @@ func (b *Batcher) Flush(timeout time.Duration) error {
 	defer ticker.Stop()
 
 	for {
+		if b.pending.Load() == 0 {
+			return nil
+		}
+
+		// Signal flush; repeated so items still in the channel are picked up
+		select {
+		case b.flushCh <- struct{}{}:
+		default:
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			b.mu.Lock()
-			empty := len(b.items) == 0
-			b.mu.Unlock()
-			if empty && len(b.itemChan) == 0 {
-				return nil
-			}
 		}
 	}
 }
 
-// Close stops the batcher and flushes remaining items.
+// Close stops the batcher and flushes remaining items. Items still in the
+// spool stay there and are replayed by the next batcher using it.
 func (b *Batcher) Close(timeout time.Duration) error {
 	// Flush remaining items
 	err := b.Flush(timeout)
 
-	// Stop workers
+	// Stop accepting items, then stop the workers, which drain the items
+	// already queued
+	b.closeMu.Lock()
+	b.closed = true
+	b.closeMu.Unlock()
 	b.cancel()
 	b.wg.Wait()
 
+	if b.spool != nil {
+		err = errors.Join(err, b.spool.close())
+	}
+	if b.gauges != nil {
+		err = errors.Join(err, b.gauges.Unregister())
+	}
 	return err
 }
 
+// Spooled returns the number of items waiting in the spool.
+func (b *Batcher) Spooled() int {
+	if b.spool == nil {
+		return 0
+	}
+	return b.spool.len()
+}
+
 func (b *Batcher) worker() {
 	defer b.wg.Done()
 
@@ -213,7 +348,20 @@ func (b *Batcher) drainAndFlush() {
 }
 
 func (b *Batcher) doFlush() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
 	b.mu.Lock()
+	// Pick up items still queued in the channel so that operations for the
+	// same entity end up in the same batch and can be merged
+	for drained := false; !drained; {
+		select {
+		case item := <-b.itemChan:
+			b.items = append(b.items, item)
+		default:
+			drained = true
+		}
+	}
 	if len(b.items) == 0 {
 		b.mu.Unlock()
 		return
@@ -224,10 +372,24 @@ func (b *Batcher) doFlush() {
 	b.items = make([]BatchItem, 0, b.config.MaxBatchSize)
 	b.mu.Unlock()
 
+	defer b.pending.Add(-int64(len(items)))
+
+	b.spooling = b.spool != nil && b.spool.len() > 0
+	start := time.Now()
+	b.process(context.Background(), items)
+	b.metrics.flushDuration.Record(context.Background(), time.Since(start).Seconds())
+}
+
+// process sends items, grouped by type. The caller must hold flushMu.
+func (b *Batcher) process(ctx context.Context, items []BatchItem) {
+	// The batcher retries failed batches itself
+	ctx = contextWithoutRetries(ctx)
+
 	// Group items by type
 	traceItems := make([]TraceBatchItem, 0)
 	spanItems := make([]SpanBatchItem, 0)
 	feedbackItems := make([]FeedbackBatchItem, 0)
+	attachmentItems := make([]AttachmentBatchItem, 0)
 
 	for _, item := range items {
 		switch v := item.(type) {
@@ -237,89 +399,470 @@ func (b *Batcher) doFlush() {
 			spanItems = append(spanItems, v)
 		case FeedbackBatchItem:
 			feedbackItems = append(feedbackItems, v)
+		case AttachmentBatchItem:
+			attachmentItems = append(attachmentItems, v)
 		}
 	}
 
-	// Process each type with retries
-	ctx := context.Background()
-
+	// Traces are sent before spans so span creates reference existing traces
 	if len(traceItems) > 0 {
-		b.processWithRetry(func() error {
-			return b.flushTraces(ctx, traceItems)
-		})
+		_ = b.flushTraces(ctx, traceItems)
 	}
 
 	if len(spanItems) > 0 {
-		b.processWithRetry(func() error {
-			return b.flushSpans(ctx, spanItems)
-		})
+		_ = b.flushSpans(ctx, spanItems)
 	}
 
 	if len(feedbackItems) > 0 {
-		b.processWithRetry(func() error {
-			return b.flushFeedback(ctx, feedbackItems)
-		})
+		_ = b.flushFeedback(ctx, feedbackItems)
+	}
+
+	if len(attachmentItems) > 0 {
+		_ = b.flushAttachments(ctx, attachmentItems)
 	}
 }
 
-func (b *Batcher) processWithRetry(fn func() error) {
+// processWithRetry calls fn until it succeeds or MaxRetries retries have been
+// made, returning the last error. Errors that retrying cannot fix, and an
+// open circuit breaker, are returned right away, and so is the last error
+// once ctx is done, for example because the batcher is being closed.
+func (b *Batcher) processWithRetry(ctx context.Context, fn func() error) error {
 	delay := b.config.RetryDelay
-	for i := 0; i < b.config.MaxRetries; i++ {
-		err := fn()
-		if err == nil {
+	var err error
+	for i := 0; i <= b.config.MaxRetries; i++ {
+		if i > 0 {
+			wait := delay
+			// Back off longer when rate limited, or as long as the server asks
+			if IsRateLimited(err) {
+				wait = delay * 2
+			}
+			if d := RetryAfter(err); d > wait && d <= b.client.httpClient.retry.MaxRetryAfter {
+				wait = d
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+
+		err = fn()
+		if err == nil || !IsRetryable(err) || IsCircuitOpen(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// send calls fn with retries and reports items to OnError if it still fails.
+// With a spool, items that failed for a transient reason are spooled
+// instead, and so are all items sent after them in the same flush.
+func (b *Batcher) send(items []BatchItem, fn func() error) error {
+	if b.spooling {
+		return b.spill(items)
+	}
+	err := b.processWithRetry(b.ctx, fn)
+	if err != nil {
+		if b.spool != nil && IsRetryable(err) {
+			b.spooling = true
+			return b.spill(items)
+		}
+		b.fail(items, err)
+		return err
+	}
+	countItems(b.metrics.sent, items)
+	if b.replaying {
+		if err := b.spool.checkpoint(items); err != nil {
+			b.fail(nil, err)
+		}
+	}
+	return nil
+}
+
+// spill writes items to the spool, reporting them to OnError if they cannot
+// be written.
+func (b *Batcher) spill(items []BatchItem) error {
+	err := b.spool.append(items)
+	if err != nil {
+		b.fail(items, err)
+		return err
+	}
+	countItems(b.metrics.spooled, items)
+	return nil
+}
+
+// replayLoop replays the spool when the batcher starts and then every
+// ReplayInterval.
+func (b *Batcher) replayLoop() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.spool.config.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		if b.spool.len() > 0 {
+			b.replay()
+		}
+		select {
+		case <-b.ctx.Done():
 			return
+		case <-ticker.C:
 		}
+	}
+}
 
-		// Check if rate limited
-		if IsRateLimited(err) {
-			time.Sleep(delay * 2)
-		} else {
-			time.Sleep(delay)
+// replay sends the spooled items. Items that still cannot be delivered are
+// spooled again to a new segment before the replayed segments are removed,
+// so a crash during replay does not lose them. Delivered items are
+// checkpointed, so the replay after a crash skips them instead of sending
+// them again. The replayed segments do not count toward MaxBytes meanwhile,
+// so there is always room to spool them again.
+// Repeated operations on an entity are merged into one, like in a flush,
+// so each trace, span or score is written once per replay.
+func (b *Batcher) replay() {
+	b.flushMu.Lock()
+	defer b.flushMu.Unlock()
+
+	segments, err := b.spool.seal()
+	if err != nil {
+		b.fail(nil, err)
+		return
+	}
+	items, err := b.spool.read(segments)
+	if err != nil {
+		b.spool.unseal()
+		b.fail(nil, err)
+		return
+	}
+
+	items = slices.DeleteFunc(items, b.spool.isDelivered)
+
+	b.spooling = false
+	b.replaying = true
+	b.process(context.Background(), items)
+	b.replaying = false
+
+	if err := b.spool.remove(segments); err != nil {
+		b.fail(nil, err)
+	}
+}
+
+// fail reports undeliverable items to the OnError callback, if configured.
+// Spool failures that affect no particular items are reported with none.
+func (b *Batcher) fail(items []BatchItem, err error) {
+	countItems(b.metrics.dropped, items, attrErrorType.String(errorType(err)))
+	if b.config.OnError != nil && err != nil {
+		b.config.OnError(&BatchError{Items: items, Err: err})
+	}
+}
+
+// flushTraces sends queued trace operations. Operations for the same trace are
+// merged so that a create followed by updates is sent as a single create;
+// updates for traces created in an earlier flush use the batch update
+// endpoint, with one request for all traces getting the same update.
+func (b *Batcher) flushTraces(ctx context.Context, items []TraceBatchItem) error {
+	creates, updates := mergeOperations(items, func(item TraceBatchItem) (api.OptUUID, bool) {
+		return item.write.ID, item.create
+	})
+
+	var errs []error
+	for _, chunk := range chunkSlice(creates, b.config.MaxBatchSize) {
+		writes := make([]api.TraceWrite, len(chunk))
+		failed := make([]BatchItem, len(chunk))
+		for i, item := range chunk {
+			writes[i] = item.write
+			failed[i] = item
 		}
-		delay *= 2
+		errs = append(errs, b.send(failed, func() error {
+			return b.client.createTraces(ctx, writes)
+		}))
 	}
+
+	groups := groupUpdates(updates, func(item TraceBatchItem) any {
+		u := traceUpdateFromWrite(item.write)
+		return &u
+	})
+	for _, group := range groups {
+		for _, chunk := range chunkSlice(group, b.config.MaxBatchSize) {
+			ids := make([]uuid.UUID, len(chunk))
+			failed := make([]BatchItem, len(chunk))
+			for i, item := range chunk {
+				ids[i] = item.write.ID.Value
+				failed[i] = item
+			}
+			update := traceUpdateFromWrite(chunk[0].write)
+			errs = append(errs, b.send(failed, func() error {
+				return b.client.updateTraces(ctx, ids, update)
+			}))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flushSpans sends queued span operations, merging operations for the same
+// span and grouping updates in the same way as flushTraces.
+func (b *Batcher) flushSpans(ctx context.Context, items []SpanBatchItem) error {
+	creates, updates := mergeOperations(items, func(item SpanBatchItem) (api.OptUUID, bool) {
+		return item.write.ID, item.create
+	})
+
+	var errs []error
+	for _, chunk := range chunkSlice(creates, b.config.MaxBatchSize) {
+		writes := make([]api.SpanWrite, len(chunk))
+		failed := make([]BatchItem, len(chunk))
+		for i, item := range chunk {
+			writes[i] = item.write
+			failed[i] = item
+		}
+		errs = append(errs, b.send(failed, func() error {
+			return b.client.createSpans(ctx, writes)
+		}))
+	}
+
+	groups := groupUpdates(updates, func(item SpanBatchItem) any {
+		u := spanUpdateFromWrite(item.write)
+		return &u
+	})
+	for _, group := range groups {
+		for _, chunk := range chunkSlice(group, b.config.MaxBatchSize) {
+			ids := make([]uuid.UUID, len(chunk))
+			failed := make([]BatchItem, len(chunk))
+			for i, item := range chunk {
+				ids[i] = item.write.ID.Value
+				failed[i] = item
+			}
+			update := spanUpdateFromWrite(chunk[0].write)
+			errs = append(errs, b.send(failed, func() error {
+				return b.client.updateSpans(ctx, ids, update)
+			}))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (b *Batcher) flushTraces(_ context.Context, items []TraceBatchItem) error {
-	// For now, traces are created individually
-	// The API supports batch creation but we'd need to restructure
+// mergeOperations merges the operations queued for each entity, in the order
+// the entities were first seen. Each operation carries the entity's full
+// state, so the latest one wins; entities with a create among their
+// operations are returned in creates, the others in updates.
+func mergeOperations[T any](items []T, op func(T) (id api.OptUUID, create bool)) (creates, updates []T) {
+	type merged struct {
+		item   T
+		create bool
+	}
+
+	byID := make(map[uuid.UUID]*merged, len(items))
+	order := make([]uuid.UUID, 0, len(items))
 	for _, item := range items {
-		if item.Trace != nil {
-			// Trace already created via API, this is for deferred creation
+		id, create := op(item)
+		if !id.Set {
+			continue
 		}
+		m, ok := byID[id.Value]
+		if !ok {
+			m = &merged{}
+			byID[id.Value] = m
+			order = append(order, id.Value)
+		}
+		m.item = item
+		m.create = m.create || create
 	}
-	return nil
+
+	for _, id := range order {
+		if m := byID[id]; m.create {
+			creates = append(creates, m.item)
+		} else {
+			updates = append(updates, m.item)
+		}
+	}
+	return creates, updates
 }
 
-func (b *Batcher) flushSpans(_ context.Context, items []SpanBatchItem) error {
-	// For now, spans are created individually
+// groupUpdates groups items whose updates encode to the same JSON, such as
+// spans ending with the same output, so that each group can be sent with
+// one batch update request. Groups are in the order they were first seen.
+func groupUpdates[T any](items []T, update func(T) any) [][]T {
+	var groups [][]T
+	index := make(map[string]int, len(items))
 	for _, item := range items {
-		if item.Span != nil {
-			// Span already created via API
+		key, err := json.Marshal(update(item))
+		if err != nil {
+			// Send it on its own; the request reports the error
+			groups = append(groups, []T{item})
+			continue
+		}
+		if i, ok := index[string(key)]; ok {
+			groups[i] = append(groups[i], item)
+			continue
 		}
+		index[string(key)] = len(groups)
+		groups = append(groups, []T{item})
 	}
-	return nil
+	return groups
+}
+
+// flushFeedback sends queued feedback scores using the batch scoring
+// endpoints for traces, spans and threads. Scores with the same name for the
+// same entity are merged; the latest one wins.
+func (b *Batcher) flushFeedback(ctx context.Context, items []FeedbackBatchItem) error {
+	type scoreKey struct{ entityType, entityID, projectName, name string }
+	latest := make(map[scoreKey]int, len(items))
+	for i, item := range items {
+		latest[scoreKey{item.EntityType, item.EntityID, item.ProjectName, item.Name}] = i
+	}
+
+	var (
+		traceScores  []api.FeedbackScoreBatchItem
+		traceItems   []BatchItem
+		spanScores   []api.FeedbackScoreBatchItem
+		spanItems    []BatchItem
+		threadScores []api.FeedbackScoreBatchItemThread
+		threadItems  []BatchItem
+		errs         []error
+	)
+
+	for i, item := range items {
+		if latest[scoreKey{item.EntityType, item.EntityID, item.ProjectName, item.Name}] != i {
+			continue
+		}
+
+		projectName := item.ProjectName
+		if projectName == "" {
+			projectName = b.client.ProjectName()
+		}
+
+		category, err := b.client.checkFeedbackScore(item.Name, item.Value)
+		if err != nil {
+			b.fail([]BatchItem{item}, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		if item.EntityType == "thread" {
+			threadScores = append(threadScores, api.FeedbackScoreBatchItemThread{
+				ProjectName:  api.NewOptString(projectName),
+				Name:         item.Name,
+				CategoryName: optString(category),
+				Value:        item.Value,
+				Reason:       optString(b.client.redactReason(item.Reason)),
+				Source:       api.FeedbackScoreBatchItemThreadSourceSdk,
+				ThreadID:     item.EntityID,
+			})
+			threadItems = append(threadItems, item)
+			continue
+		}
+
+		entityUUID, err := uuid.Parse(item.EntityID)
+		if err != nil {
+			// Retrying cannot fix an invalid ID, so report it right away
+			err = fmt.Errorf("invalid %s ID %q: %w", item.EntityType, item.EntityID, err)
+			b.fail([]BatchItem{item}, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		score := api.FeedbackScoreBatchItem{
+			ProjectName:  api.NewOptString(projectName),
+			Name:         item.Name,
+			CategoryName: optString(category),
+			Value:        item.Value,
+			Reason:       optString(b.client.redactReason(item.Reason)),
+			Source:       api.FeedbackScoreBatchItemSourceSdk,
+			ID:           entityUUID,
+		}
+
+		switch item.EntityType {
+		case "trace":
+			traceScores = append(traceScores, score)
+			traceItems = append(traceItems, item)
+		case "span":
+			spanScores = append(spanScores, score)
+			spanItems = append(spanItems, item)
+		default:
+			err := fmt.Errorf("%w: unknown feedback entity type %q", ErrInvalidInput, item.EntityType)
+			b.fail([]BatchItem{item}, err)
+			errs = append(errs, err)
+		}
+	}
+
+	if len(traceScores) > 0 {
+		errs = append(errs, b.send(traceItems, func() error {
+			return b.client.apiClient.ScoreBatchOfTraces(ctx, api.NewOptFeedbackScoreBatch(api.FeedbackScoreBatch{
+				Scores: traceScores,
+			}))
+		}))
+	}
+
+	if len(spanScores) > 0 {
+		errs = append(errs, b.send(spanItems, func() error {
+			return b.client.apiClient.ScoreBatchOfSpans(ctx, api.NewOptFeedbackScoreBatch(api.FeedbackScoreBatch{
+				Scores: spanScores,
+			}))
+		}))
+	}
+
+	if len(threadScores) > 0 {
+		errs = append(errs, b.send(threadItems, func() error {
+			return b.client.apiClient.ScoreBatchOfThreads(ctx, api.NewOptFeedbackScoreBatchThread(api.FeedbackScoreBatchThread{
+				Scores: threadScores,
+			}))
+		}))
+	}
+
+	return errors.Join(errs...)
 }
 
-func (b *Batcher) flushFeedback(_ context.Context, items []FeedbackBatchItem) error {
-	// Group by entity type
-	traceFeedback := make(map[string][]FeedbackBatchItem)
-	spanFeedback := make(map[string][]FeedbackBatchItem)
+// flushAttachments uploads queued attachments. Failed uploads are reported
+// to OnError rather than spooled, since the spool does not hold file data.
+func (b *Batcher) flushAttachments(ctx context.Context, items []AttachmentBatchItem) error {
+	uploader := NewAttachmentUploader(b.client)
 
+	var errs []error
 	for _, item := range items {
-		if item.EntityType == "trace" {
-			traceFeedback[item.EntityID] = append(traceFeedback[item.EntityID], item)
-		} else {
-			spanFeedback[item.EntityID] = append(spanFeedback[item.EntityID], item)
+		err := b.processWithRetry(b.ctx, func() error {
+			return uploader.UploadTo(ctx, item.EntityType, item.EntityID, item.Attachment,
+				WithAttachmentProjectName(item.ProjectName))
+		})
+		if err != nil {
+			b.fail([]BatchItem{item}, err)
+			errs = append(errs, err)
+			continue
 		}
+		countItems(b.metrics.sent, []BatchItem{item})
 	}
+	return errors.Join(errs...)
+}
 
-	// Process trace feedback - would use batch API
-	// Process span feedback - would use batch API
-	return nil
+// optString returns an unset OptString for empty values.
+func optString(s string) api.OptString {
+	if s == "" {
+		return api.OptString{}
+	}
+	return api.NewOptString(s)
+}
+
+// chunkSlice splits s into consecutive chunks of at most size elements.
+// A non-positive size returns s as a single chunk.
+func chunkSlice[T any](s []T, size int) [][]T {
+	if len(s) == 0 {
+		return nil
+	}
+	if size <= 0 || len(s) <= size {
+		return [][]T{s}
+	}
+	chunks := make([][]T, 0, (len(s)+size-1)/size)
+	for size < len(s) {
+		chunks = append(chunks, s[:size:size])
+		s = s[size:]
+	}
+	return append(chunks, s)
 }
 
 // BatchingClient wraps a Client with batching support.
+// Trace and span creates, updates and ends made through the client are queued
+// and sent asynchronously, so they no longer block on the API.
 type BatchingClient struct {
 	*Client
 	batcher *Batcher
@@ -333,6 +876,7 @@ func NewBatchingClient(opts ...Option) (*BatchingClient, error) {
 	}
 
 	batcher := NewBatcher(client, DefaultBatcherConfig())
+	client.batcher = batcher
 
 	return &BatchingClient{
 		Client:  client,
@@ -341,13 +885,22 @@ func NewBatchingClient(opts ...Option) (*BatchingClient, error) {
 }
 
 // NewBatchingClientWithConfig creates a new client with custom batching config.
+// It fails if config.Spool is set and the spool cannot be opened.
 func NewBatchingClientWithConfig(config BatcherConfig, opts ...Option) (*BatchingClient, error) {
 	client, err := NewClient(opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	batcher := NewBatcher(client, config)
+	var s *spool
+	if config.Spool != nil {
+		if s, err = openSpool(*config.Spool); err != nil {
+			return nil, err
+		}
+	}
+
+	batcher := newBatcher(client, config, s)
+	client.batcher = batcher
 
 	return &BatchingClient{
 		Client:  client,
@@ -365,7 +918,16 @@ func (c *BatchingClient) Close(timeout time.Duration) error {
 	return c.batcher.Close(timeout)
 }
 
+// Spooled returns the number of operations waiting in the spool.
+func (c *BatchingClient) Spooled() int {
+	return c.batcher.Spooled()
+}
+
 // AddFeedbackAsync adds a feedback score asynchronously via batching.
+// entityType is "trace", "span" or "thread"; for threads, entityID is the
+// thread ID within the client's default project. Scores that cannot be
+// delivered, or that do not match the client's feedback definitions, are
+// reported through BatcherConfig.OnError.
 func (c *BatchingClient) AddFeedbackAsync(entityType, entityID, name string, value float64, reason string) {
 	c.batcher.Add(FeedbackBatchItem{
 		EntityType: entityType,
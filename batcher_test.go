@@ -1,8 +1,19 @@
 package opik
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
 )
 
 func TestDefaultBatcherConfig(t *testing.T) {
@@ -133,3 +144,414 @@ func TestBatcherConfigZeroValues(t *testing.T) {
 		t.Errorf("zero Workers = %d, want 0", config.Workers)
 	}
 }
+
+func newTestBatchingClient(t *testing.T, ms *testutil.MockServer) *BatchingClient {
+	t.Helper()
+
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	// A single worker keeps batch boundaries deterministic
+	config.Workers = 1
+
+	client, err := NewBatchingClientWithConfig(config,
+		WithURL(ms.URL()),
+		WithAPIKey("test-key"),
+		WithProjectName("batch-project"),
+	)
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	return client
+}
+
+func TestBatchingClientMergesCreateAndEnd(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestBatchingClient(t, ms)
+	defer client.Close(time.Second)
+
+	ctx := context.Background()
+	trace, err := client.Trace(ctx, "batched-trace", WithTraceInput(map[string]any{"q": "hi"}))
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	span, err := trace.Span(ctx, "batched-span")
+	if err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+
+	// Nothing is sent until the batch is flushed
+	if n := ms.RequestCount(); n != 0 {
+		t.Errorf("RequestCount before flush = %d, want 0", n)
+	}
+
+	if err := span.End(ctx, WithSpanOutput("done")); err != nil {
+		t.Fatalf("span.End error: %v", err)
+	}
+	if err := trace.End(ctx, WithTraceOutput("done")); err != nil {
+		t.Fatalf("trace.End error: %v", err)
+	}
+
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 1 {
+		t.Errorf("trace create calls = %d, want 1", n)
+	}
+	if n := ms.RouteCallCount("POST", "/v1/private/spans/batch"); n != 1 {
+		t.Errorf("span create calls = %d, want 1", n)
+	}
+	if n := ms.RouteCallCount("PATCH", "/v1/private/traces/batch"); n != 0 {
+		t.Errorf("trace update calls = %d, want 0", n)
+	}
+	if n := ms.RouteCallCount("PATCH", "/v1/private/spans/batch"); n != 0 {
+		t.Errorf("span update calls = %d, want 0", n)
+	}
+
+	traces := tracetest.TraceWrites(ms)
+	if len(traces) != 1 {
+		t.Fatalf("len(traces) = %d, want 1", len(traces))
+	}
+	if _, ok := traces[0]["end_time"]; !ok {
+		t.Error("merged trace create should include end_time")
+	}
+	if traces[0]["output"] != "done" {
+		t.Errorf("output = %v, want %q", traces[0]["output"], "done")
+	}
+}
+
+func TestBatchingClientUpdatesAfterFlush(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestBatchingClient(t, ms)
+	defer client.Close(time.Second)
+
+	ctx := context.Background()
+	traces := make([]*Trace, 3)
+	for i := range traces {
+		trace, err := client.Trace(ctx, "batched-trace")
+		if err != nil {
+			t.Fatalf("Trace error: %v", err)
+		}
+		traces[i] = trace
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	for _, trace := range traces {
+		if err := trace.End(ctx, WithTraceOutput("done")); err != nil {
+			t.Fatalf("trace.End error: %v", err)
+		}
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	// The ended traces are updated, with one request per distinct update
+	if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 1 {
+		t.Errorf("trace create calls = %d, want 1", n)
+	}
+	updated := make(map[string]bool)
+	endTimes := make(map[any]bool)
+	for _, req := range ms.RequestsForPath("/v1/private/traces/batch") {
+		if req.Method != http.MethodPatch {
+			continue
+		}
+		var body struct {
+			IDs    []string       `json:"ids"`
+			Update map[string]any `json:"update"`
+		}
+		if err := json.Unmarshal(req.Body, &body); err != nil {
+			t.Fatalf("unmarshal update: %v", err)
+		}
+		if body.Update["output"] != "done" || body.Update["end_time"] == nil {
+			t.Errorf("update = %v, want the output and end_time", body.Update)
+		}
+		if endTimes[body.Update["end_time"]] {
+			t.Errorf("identical updates sent in separate requests: %v", body.Update)
+		}
+		endTimes[body.Update["end_time"]] = true
+		for _, id := range body.IDs {
+			updated[id] = true
+		}
+	}
+	for i, trace := range traces {
+		if !updated[trace.ID()] {
+			t.Errorf("traces[%d] was not updated", i)
+		}
+	}
+}
+
+func TestBatcherGroupsIdenticalUpdates(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestBatchingClient(t, ms)
+	defer client.Close(time.Second)
+
+	endTime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	update := func(output string) SpanBatchItem {
+		return SpanBatchItem{write: api.SpanWrite{
+			ID:       api.NewOptUUID(uuid.Must(uuid.NewV7())),
+			EndTime:  api.NewOptDateTime(endTime),
+			Input:    api.JsonListStringWrite("null"),
+			Output:   api.JsonListStringWrite(`"` + output + `"`),
+			Metadata: api.JsonListStringWrite("null"),
+		}}
+	}
+	items := []SpanBatchItem{update("done"), update("done"), update("failed"), update("done")}
+	if err := client.batcher.flushSpans(context.Background(), items); err != nil {
+		t.Fatalf("flushSpans error: %v", err)
+	}
+
+	var ids [][]string
+	for _, req := range ms.RequestsForPath("/v1/private/spans/batch") {
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.Unmarshal(req.Body, &body); err != nil {
+			t.Fatalf("unmarshal update: %v", err)
+		}
+		ids = append(ids, body.IDs)
+	}
+	if len(ids) != 2 || len(ids[0]) != 3 || len(ids[1]) != 1 {
+		t.Fatalf("update requests = %v, want 3 IDs then 1", ids)
+	}
+	if ids[0][2] != items[3].write.ID.Value.String() || ids[1][0] != items[2].write.ID.Value.String() {
+		t.Errorf("update requests = %v, want the spans grouped by update", ids)
+	}
+}
+
+func TestBatcherReportsItemsAddedAfterClose(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	var mu sync.Mutex
+	var errs []*BatchError
+	config := DefaultBatcherConfig()
+	config.OnError = func(err *BatchError) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+
+	trace, err := client.Trace(context.Background(), "late-trace")
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if err := client.Close(time.Second); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if err := trace.End(context.Background()); err != nil {
+		t.Fatalf("trace.End error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 1 {
+		t.Fatalf("OnError calls = %d, want 1", len(errs))
+	}
+	if !errors.Is(errs[0], ErrClientClosed) || len(errs[0].Items) != 1 {
+		t.Errorf("OnError(%v) with %d items, want ErrClientClosed with 1", errs[0], len(errs[0].Items))
+	}
+}
+
+func TestBatcherRetriesFailedRequests(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/batch").Respond(http.StatusInternalServerError, nil)
+
+	client := newTestBatchingClient(t, ms)
+	defer client.Close(time.Second)
+
+	if _, err := client.Trace(context.Background(), "failing-trace"); err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	want := DefaultBatcherConfig().MaxRetries + 1
+	if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != want {
+		t.Errorf("trace create attempts = %d, want %d", n, want)
+	}
+}
+
+func TestBatcherCloseStopsRetryDelay(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost(tracetest.TracesBatchPath).Respond(http.StatusInternalServerError, nil)
+
+	errc := make(chan *BatchError, 1)
+	config := DefaultBatcherConfig()
+	config.RetryDelay = time.Hour
+	config.OnError = func(err *BatchError) { errc <- err }
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+
+	if _, err := client.Trace(context.Background(), "failing-trace"); err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	start := time.Now()
+	_ = client.Close(50 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Close took %v, want it to stop the retry delay", elapsed)
+	}
+
+	select {
+	case err := <-errc:
+		if len(err.Items) != 1 {
+			t.Errorf("OnError items = %d, want 1", len(err.Items))
+		}
+	default:
+		t.Error("OnError not called for the undelivered trace")
+	}
+}
+
+func TestChunkSlice(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		size int
+		want []int
+	}{
+		{"empty", 0, 10, nil},
+		{"single chunk", 3, 10, []int{3}},
+		{"exact", 4, 2, []int{2, 2}},
+		{"remainder", 5, 2, []int{2, 2, 1}},
+		{"no limit", 5, 0, []int{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkSlice(make([]int, tt.n), tt.size)
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("len(chunks) = %d, want %d", len(chunks), len(tt.want))
+			}
+			for i, c := range chunks {
+				if len(c) != tt.want[i] {
+					t.Errorf("len(chunks[%d]) = %d, want %d", i, len(c), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBatchingClientFlushesFeedback(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPut("/v1/private/traces/feedback-scores").Respond(http.StatusNoContent, nil)
+	ms.OnPut("/v1/private/spans/feedback-scores").Respond(http.StatusNoContent, nil)
+	ms.OnPut("/v1/private/traces/threads/feedback-scores").Respond(http.StatusNoContent, nil)
+
+	client := newTestBatchingClient(t, ms)
+	defer client.Close(time.Second)
+
+	traceID := "01900000-0000-7000-8000-000000000001"
+	spanID := "01900000-0000-7000-8000-000000000002"
+
+	client.AddFeedbackAsync("trace", traceID, "accuracy", 0.9, "good")
+	client.AddFeedbackAsync("trace", traceID, "relevance", 0.8, "")
+	client.AddFeedbackAsync("span", spanID, "quality", 0.7, "ok")
+	client.AddFeedbackAsync("thread", "conversation-1", "satisfaction", 1, "")
+
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	reqs := ms.RequestsForPath("/v1/private/traces/feedback-scores")
+	if len(reqs) != 1 {
+		t.Fatalf("trace score requests = %d, want 1", len(reqs))
+	}
+	var body struct {
+		Scores []map[string]any `json:"scores"`
+	}
+	if err := json.Unmarshal(reqs[0].Body, &body); err != nil {
+		t.Fatalf("unmarshal scores: %v", err)
+	}
+	if len(body.Scores) != 2 {
+		t.Errorf("len(scores) = %d, want 2", len(body.Scores))
+	}
+	if body.Scores[0]["id"] != traceID {
+		t.Errorf("scores[0].id = %v, want %s", body.Scores[0]["id"], traceID)
+	}
+	if body.Scores[0]["project_name"] != "batch-project" {
+		t.Errorf("scores[0].project_name = %v, want batch-project", body.Scores[0]["project_name"])
+	}
+
+	if n := ms.RouteCallCount("PUT", "/v1/private/spans/feedback-scores"); n != 1 {
+		t.Errorf("span score requests = %d, want 1", n)
+	}
+	if n := ms.RouteCallCount("PUT", "/v1/private/traces/threads/feedback-scores"); n != 1 {
+		t.Errorf("thread score requests = %d, want 1", n)
+	}
+}
+
+func TestBatcherReportsFailedFeedback(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPut("/v1/private/traces/feedback-scores").Respond(http.StatusInternalServerError, nil)
+
+	var mu sync.Mutex
+	var failed []BatchItem
+
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	config.OnError = func(err *BatchError) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed = append(failed, err.Items...)
+	}
+
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	defer client.Close(time.Second)
+
+	client.AddFeedbackAsync("trace", "01900000-0000-7000-8000-000000000001", "accuracy", 0.9, "")
+	client.AddFeedbackAsync("trace", "not-a-uuid", "accuracy", 0.9, "")
+
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 2 {
+		t.Fatalf("failed items = %d, want 2", len(failed))
+	}
+	if n := ms.RouteCallCount("PUT", "/v1/private/traces/feedback-scores"); n != config.MaxRetries+1 {
+		t.Errorf("score attempts = %d, want %d", n, config.MaxRetries+1)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	cause := &APIError{StatusCode: 500, Message: "boom"}
+	err := &BatchError{Items: []BatchItem{FeedbackBatchItem{}}, Err: cause}
+
+	if err.Error() == "" {
+		t.Error("Error() should not be empty")
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Error("BatchError should unwrap to the underlying error")
+	}
+}
@@ -1,13 +1,19 @@
 package opik
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"iter"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	oteltrace "go.opentelemetry.io/otel/trace"
 
 	"github.com/agentplexus/go-opik/internal/api"
 )
@@ -20,8 +26,41 @@ type Client struct {
 	config    *Config
 	apiClient *api.Client
 
+	// httpClient sends requests the generated client cannot make
+	httpClient *authHTTPClient
+
 	// Default project name for new traces
 	projectName string
+
+	// projectNames caches the names of projects looked up by ID
+	projectNames sync.Map
+
+	// batcher queues trace and span operations when set; nil sends synchronously
+	batcher *Batcher
+
+	// pricing estimates span costs from usage when set
+	pricing *PricingRegistry
+
+	// sampler decides which traces are exported; nil exports all
+	sampler Sampler
+	// deferLimits bounds the traces the sampler defers
+	deferLimits deferLimits
+
+	// redactor scrubs payloads before they are sent; nil sends them as is
+	redactor Redactor
+
+	// extractor moves inline media into attachments; nil disables extraction
+	extractor *AttachmentExtractor
+
+	// feedbackDefs checks feedback scores before they are sent; nil skips checks
+	feedbackMu   sync.Mutex
+	feedbackDefs *feedbackDefinitions
+
+	// metrics records the SDK's own metrics
+	metrics *sdkMetrics
+
+	// tracer mirrors traces and spans as OpenTelemetry spans; nil disables it
+	tracer oteltrace.Tracer
 }
 
 // NewClient creates a new Opik client with the given options.
@@ -43,11 +82,19 @@ func NewClient(opts ...Option) (*Client, error) {
 		}
 	}
 
+	metrics, err := newSDKMetrics(options.meters)
+	if err != nil {
+		return nil, err
+	}
+
 	// Wrap with auth transport
 	authClient := &authHTTPClient{
+		metrics:   metrics,
 		client:    httpClient,
 		apiKey:    options.config.APIKey,
 		workspace: options.config.Workspace,
+		retry:     options.retry,
+		breaker:   options.breaker,
 	}
 
 	// Create the ogen client
@@ -59,18 +106,36 @@ func NewClient(opts ...Option) (*Client, error) {
 		return nil, err
 	}
 
+	var tracer oteltrace.Tracer
+	if options.tracers != nil {
+		tracer = options.tracers.Tracer(tracerName, oteltrace.WithInstrumentationVersion(Version))
+	}
+
 	return &Client{
-		config:      options.config,
-		apiClient:   apiClient,
-		projectName: options.config.ProjectName,
+		config:       options.config,
+		apiClient:    apiClient,
+		httpClient:   authClient,
+		projectName:  options.config.ProjectName,
+		pricing:      options.pricing,
+		sampler:      options.sampler,
+		deferLimits:  options.deferLimits,
+		redactor:     options.redactor,
+		extractor:    options.extractor,
+		feedbackDefs: options.feedbackDefs,
+		metrics:      metrics,
+		tracer:       tracer,
 	}, nil
 }
 
-// authHTTPClient wraps an http.Client to add authentication headers.
+// authHTTPClient wraps an http.Client to add authentication headers,
+// retries and a circuit breaker.
 type authHTTPClient struct {
 	client    *http.Client
 	apiKey    string
 	workspace string
+	retry     RetryPolicy
+	breaker   *CircuitBreaker
+	metrics   *sdkMetrics
 }
 
 // Do implements ht.Client interface.
@@ -88,7 +153,50 @@ func (c *authHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	req.Header.Set("X-OPIK-DEBUG-SDK-LANG", "go")
 	// Note: Not requesting gzip as the ogen client doesn't auto-decompress
 
-	return c.client.Do(req)
+	return c.send(req)
+}
+
+// doJSON sends a JSON request to an API path with the authenticated client
+// and decodes the response into out, if not nil. It is used for endpoints
+// the generated client cannot call because their spec is incomplete.
+func (c *Client) doJSON(ctx context.Context, method, path string, body, out any) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := strings.TrimSuffix(c.config.URL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var msg api.ErrorMessage
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return nil, &APIError{StatusCode: resp.StatusCode, Message: resp.Status}
+		}
+		return nil, newAPIError(resp.StatusCode, &msg)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return nil, err
+		}
+	}
+	return resp.Header, nil
 }
 
 // Config returns the client configuration.
@@ -128,69 +236,62 @@ func (c *Client) Trace(ctx context.Context, name string, opts ...TraceOption) (*
 		projectName = c.projectName
 	}
 
-	// Generate trace ID (must be UUID v7 for Opik API)
-	traceUUID, err := uuid.NewV7()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate trace UUID: %w", err)
-	}
-	traceID := traceUUID.String()
-
-	// Prepare input/output/metadata as JSON
-	// Note: JsonListStringWrite is raw JSON bytes - use null for empty values
-	nullJSON := api.JsonListStringWrite([]byte("null"))
-	inputJSON := nullJSON
-	outputJSON := nullJSON
-	metadataJSON := nullJSON
-
-	if options.input != nil {
-		data, _ := json.Marshal(options.input)
-		inputJSON = api.JsonListStringWrite(data)
-	}
-	if options.output != nil {
-		data, _ := json.Marshal(options.output)
-		outputJSON = api.JsonListStringWrite(data)
-	}
-	if len(options.metadata) > 0 {
-		data, _ := json.Marshal(options.metadata)
-		metadataJSON = api.JsonListStringWrite(data)
+	// Generate trace ID (must be UUID v7 for Opik API). A trace continuing
+	// a W3C trace takes its ID from the W3C trace ID instead.
+	metadata := options.metadata
+	remote, continued := remoteW3CTrace(ctx)
+	var traceUUID uuid.UUID
+	if continued {
+		traceUUID = uuidFromTraceID(remote.TraceID())
+		metadata = withW3CParent(metadata, remote)
+	} else {
+		var err error
+		if traceUUID, err = uuid.NewV7(); err != nil {
+			return nil, fmt.Errorf("failed to generate trace UUID: %w", err)
+		}
 	}
 
 	startTime := time.Now()
+	otelSpan, metadata := c.startOTelSpan(ctx, name, startTime, oteltrace.SpanKindInternal, metadata)
 
-	// Create trace request
-	req := api.TraceBatchWrite{
-		Traces: []api.TraceWrite{{
-			ID:          api.NewOptUUID(traceUUID),
-			ProjectName: api.NewOptString(projectName),
-			Name:        api.NewOptString(name),
-			StartTime:   startTime,
-			Input:       inputJSON,
-			Output:      outputJSON,
-			Metadata:    metadataJSON,
+	trace := &Trace{
+		client:      c,
+		id:          traceUUID.String(),
+		name:        name,
+		projectName: projectName,
+		threadID:    options.threadID,
+		startTime:   startTime,
+		metadata:    metadata,
+		tags:        options.tags,
+		attachments: options.attachments,
+		otelSpan:    otelSpan,
+	}
+	trace.input = trace.extractAttachments("input", options.input)
+	trace.output = trace.extractAttachments("output", options.output)
+	if continued && !remote.IsSampled() {
+		// The calling service does not record the W3C trace
+		trace.sampling = &samplingState{decision: SamplingDrop}
+	} else {
+		trace.sampling = newSamplingState(trace, c.sampler, c.deferLimits, SamplingParameters{
+			TraceID:     trace.id,
+			Name:        name,
+			ProjectName: projectName,
 			Tags:        options.tags,
-		}},
+			ThreadID:    options.threadID,
+		})
 	}
 
-	// Send to API
-	err = c.apiClient.CreateTraces(ctx, api.NewOptTraceBatchWrite(req))
-	if err != nil {
+	if err := c.sendTrace(ctx, trace, true); err != nil {
+		abortOTelSpan(otelSpan, err)
 		return nil, err
 	}
 
-	return &Trace{
-		client:      c,
-		id:          traceID,
-		name:        name,
-		projectName: projectName,
-		startTime:   startTime,
-		input:       options.input,
-		output:      options.output,
-		metadata:    options.metadata,
-		tags:        options.tags,
-	}, nil
+	return trace, nil
 }
 
-// GetTrace retrieves a trace by ID.
+// GetTrace retrieves a trace by ID. The returned trace holds the stored
+// input, output, metadata, tags, feedback scores and comments, and can be
+// updated like a trace created with Trace.
 func (c *Client) GetTrace(ctx context.Context, traceID string) (*Trace, error) {
 	traceUUID, err := uuid.Parse(traceID)
 	if err != nil {
@@ -206,20 +307,63 @@ func (c *Client) GetTrace(ctx context.Context, traceID string) (*Trace, error) {
 		return nil, ErrTraceNotFound
 	}
 
-	var id, name string
-	if resp.ID.Set {
-		id = resp.ID.Value.String()
+	info := traceInfoFromAPI(resp)
+	projectName, err := c.projectNameByID(ctx, info.ProjectID)
+	if err != nil {
+		return nil, err
 	}
-	if resp.Name.Set {
-		name = resp.Name.Value
+	return c.traceFromInfo(info, projectName), nil
+}
+
+// traceFromInfo creates a trace handle for a stored trace in the named
+// project.
+func (c *Client) traceFromInfo(info *TraceInfo, projectName string) *Trace {
+	trace := &Trace{
+		client:         c,
+		id:             info.ID,
+		name:           info.Name,
+		projectName:    projectName,
+		threadID:       info.ThreadID,
+		startTime:      info.StartTime,
+		input:          info.Input,
+		output:         info.Output,
+		metadata:       info.Metadata,
+		tags:           info.Tags,
+		errorInfo:      info.ErrorInfo,
+		usage:          info.Usage,
+		totalCost:      info.TotalCost,
+		feedbackScores: info.FeedbackScores,
+		comments:       info.Comments,
+	}
+	if !info.EndTime.IsZero() {
+		endTime := info.EndTime
+		trace.endTime = &endTime
+		trace.ended = true
+	}
+	return trace
+}
+
+// projectNameByID looks up the name of a project by ID. An empty ID is the
+// client's default project.
+func (c *Client) projectNameByID(ctx context.Context, projectID string) (string, error) {
+	if projectID == "" {
+		return c.projectName, nil
+	}
+	if name, ok := c.projectNames.Load(projectID); ok {
+		return name.(string), nil
 	}
 
-	return &Trace{
-		client:    c,
-		id:        id,
-		name:      name,
-		startTime: resp.StartTime,
-	}, nil
+	projectUUID, err := uuid.Parse(projectID)
+	if err != nil {
+		return "", err
+	}
+	project, err := c.apiClient.GetProjectById(ctx, api.GetProjectByIdParams{ID: projectUUID})
+	if err != nil {
+		return "", err
+	}
+
+	c.projectNames.Store(projectID, project.Name)
+	return project.Name, nil
 }
 
 // API returns the underlying ogen-generated API client for advanced usage.
@@ -236,6 +380,11 @@ type Project struct {
 	LastUpdated time.Time
 }
 
+// AllProjects iterates over all projects, fetching them page by page.
+func (c *Client) AllProjects(ctx context.Context, opts ...PageOption) iter.Seq2[*Project, error] {
+	return paginate(ctx, c.ListProjects, opts)
+}
+
 // ListProjects lists all projects.
 func (c *Client) ListProjects(ctx context.Context, page, size int) ([]*Project, error) {
 	resp, err := c.apiClient.FindProjects(ctx, api.FindProjectsParams{
@@ -311,106 +460,256 @@ func WithProjectDescription(desc string) ProjectOption {
 	}
 }
 
-// TraceInfo represents basic trace information.
+// TraceInfo is a trace as stored in Opik.
 type TraceInfo struct {
-	ID        string
-	Name      string
-	StartTime time.Time
-	EndTime   time.Time
+	ID             string
+	ProjectID      string
+	Name           string
+	StartTime      time.Time
+	EndTime        time.Time
+	Duration       time.Duration
+	Input          any
+	Output         any
+	Metadata       map[string]any
+	Tags           []string
+	ThreadID       string
+	ErrorInfo      *ErrorInfo
+	Usage          map[string]int
+	TotalCost      float64
+	SpanCount      int
+	FeedbackScores []FeedbackScore
+	Comments       []Comment
+	CreatedAt      time.Time
+	LastUpdatedAt  time.Time
 }
 
-// SpanInfo represents basic span information.
+// SpanInfo is a span as stored in Opik.
 type SpanInfo struct {
-	ID           string
-	TraceID      string
-	ParentSpanID string
-	Name         string
-	Type         string
-	StartTime    time.Time
-	EndTime      time.Time
-	Model        string
-	Provider     string
-}
-
-// ListTraces lists recent traces.
-func (c *Client) ListTraces(ctx context.Context, page, size int) ([]*TraceInfo, error) {
-	resp, err := c.apiClient.GetTracesByProject(ctx, api.GetTracesByProjectParams{
-		ProjectName: api.NewOptString(c.projectName),
-		Page:        api.NewOptInt32(int32(page)), //nolint:gosec // G115: page values are bounded by API limits
-		Size:        api.NewOptInt32(int32(size)), //nolint:gosec // G115: size values are bounded by API limits
-	})
-	if err != nil {
-		return nil, err
-	}
+	ID             string
+	TraceID        string
+	ParentSpanID   string
+	ProjectID      string
+	ProjectName    string
+	Name           string
+	Type           string
+	StartTime      time.Time
+	EndTime        time.Time
+	Duration       time.Duration
+	Input          any
+	Output         any
+	Metadata       map[string]any
+	Tags           []string
+	Model          string
+	Provider       string
+	ErrorInfo      *ErrorInfo
+	Usage          map[string]int
+	TotalCost      float64
+	FeedbackScores []FeedbackScore
+	Comments       []Comment
+	CreatedAt      time.Time
+	LastUpdatedAt  time.Time
+}
 
-	traces := make([]*TraceInfo, 0, len(resp.Content))
-	for _, t := range resp.Content {
-		trace := &TraceInfo{
-			StartTime: t.StartTime,
-		}
-		if t.ID.Set {
-			trace.ID = t.ID.Value.String()
-		}
-		if t.Name.Set {
-			trace.Name = t.Name.Value
-		}
-		if t.EndTime.Set {
-			trace.EndTime = t.EndTime.Value
-		}
-		traces = append(traces, trace)
-	}
+// AllTraces iterates over the traces matching q, fetching them page by
+// page. A nil query iterates over the traces of the client's project.
+func (c *Client) AllTraces(ctx context.Context, q *Query, opts ...PageOption) iter.Seq2[*TraceInfo, error] {
+	return paginate(ctx, func(ctx context.Context, page, size int) ([]*TraceInfo, error) {
+		return c.QueryTraces(ctx, q, page, size)
+	}, opts)
+}
 
-	return traces, nil
+// AllSpans iterates over the spans matching q, fetching them page by page.
+// Use NewQuery().TraceID(id) to iterate over the spans of a trace.
+func (c *Client) AllSpans(ctx context.Context, q *Query, opts ...PageOption) iter.Seq2[*SpanInfo, error] {
+	return paginate(ctx, func(ctx context.Context, page, size int) ([]*SpanInfo, error) {
+		return c.QuerySpans(ctx, q, page, size)
+	}, opts)
+}
+
+// ListTraces lists recent traces of the client's project.
+// Use QueryTraces to filter and sort them.
+func (c *Client) ListTraces(ctx context.Context, page, size int) ([]*TraceInfo, error) {
+	return c.QueryTraces(ctx, nil, page, size)
 }
 
 // ListSpans lists spans for a specific trace.
 func (c *Client) ListSpans(ctx context.Context, traceID string, page, size int) ([]*SpanInfo, error) {
-	traceUUID, err := uuid.Parse(traceID)
+	return c.QuerySpans(ctx, NewQuery().TraceID(traceID), page, size)
+}
+
+// GetSpan retrieves a span by ID.
+func (c *Client) GetSpan(ctx context.Context, spanID string) (*SpanInfo, error) {
+	spanUUID, err := uuid.Parse(spanID)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := c.apiClient.GetSpansByProject(ctx, api.GetSpansByProjectParams{
-		ProjectName: api.NewOptString(c.projectName),
-		TraceID:     api.NewOptUUID(traceUUID),
-		Page:        api.NewOptInt32(int32(page)), //nolint:gosec // G115: page values are bounded by API limits
-		Size:        api.NewOptInt32(int32(size)), //nolint:gosec // G115: size values are bounded by API limits
-	})
+	resp, err := c.apiClient.GetSpanById(ctx, api.GetSpanByIdParams{ID: spanUUID})
 	if err != nil {
 		return nil, err
 	}
 
-	spans := make([]*SpanInfo, 0, len(resp.Content))
-	for _, s := range resp.Content {
-		span := &SpanInfo{
-			StartTime: s.StartTime,
+	switch r := resp.(type) {
+	case *api.GetSpanByIdOK:
+		info := spanInfoFromAPI((*api.SpanPublic)(r))
+		if info.ProjectName == "" && info.ProjectID != "" {
+			if info.ProjectName, err = c.projectNameByID(ctx, info.ProjectID); err != nil {
+				return nil, err
+			}
 		}
-		if s.ID.Set {
-			span.ID = s.ID.Value.String()
-		}
-		if s.TraceID.Set {
-			span.TraceID = s.TraceID.Value.String()
-		}
-		if s.ParentSpanID.Set {
-			span.ParentSpanID = s.ParentSpanID.Value.String()
-		}
-		if s.Name.Set {
-			span.Name = s.Name.Value
-		}
-		if s.Type.Set {
-			span.Type = string(s.Type.Value)
-		}
-		if s.EndTime.Set {
-			span.EndTime = s.EndTime.Value
-		}
-		if s.Model.Set {
-			span.Model = s.Model.Value
+		return info, nil
+	default:
+		return nil, ErrSpanNotFound
+	}
+}
+
+// traceInfoFromAPI converts an API trace.
+func traceInfoFromAPI(t *api.TracePublic) *TraceInfo {
+	info := &TraceInfo{
+		StartTime:      t.StartTime,
+		Input:          decodeJSONValue(t.Input),
+		Output:         decodeJSONValue(t.Output),
+		Metadata:       decodeMetadata(t.Metadata),
+		Tags:           t.Tags,
+		FeedbackScores: feedbackScoresFromPublic(t.FeedbackScores),
+		Comments:       commentsFromPublic(t.Comments),
+	}
+	if t.ID.Set {
+		info.ID = t.ID.Value.String()
+	}
+	if t.ProjectID.Set {
+		info.ProjectID = t.ProjectID.Value.String()
+	}
+	if t.Name.Set {
+		info.Name = t.Name.Value
+	}
+	if t.EndTime.Set {
+		info.EndTime = t.EndTime.Value
+	}
+	if t.Duration.Set {
+		info.Duration = durationFromMillis(t.Duration.Value)
+	}
+	if t.ThreadID.Set {
+		info.ThreadID = t.ThreadID.Value
+	}
+	if t.ErrorInfo.Set {
+		info.ErrorInfo = errorInfoFromAPI(t.ErrorInfo.Value)
+	}
+	if t.Usage.Set {
+		info.Usage = make(map[string]int, len(t.Usage.Value))
+		for k, v := range t.Usage.Value {
+			info.Usage[k] = int(v)
 		}
-		if s.Provider.Set {
-			span.Provider = s.Provider.Value
+	}
+	if t.TotalEstimatedCost.Set {
+		info.TotalCost = t.TotalEstimatedCost.Value
+	}
+	if t.SpanCount.Set {
+		info.SpanCount = int(t.SpanCount.Value)
+	}
+	if t.CreatedAt.Set {
+		info.CreatedAt = t.CreatedAt.Value
+	}
+	if t.LastUpdatedAt.Set {
+		info.LastUpdatedAt = t.LastUpdatedAt.Value
+	}
+	return info
+}
+
+// spanInfoFromAPI converts an API span.
+func spanInfoFromAPI(s *api.SpanPublic) *SpanInfo {
+	info := &SpanInfo{
+		StartTime:      s.StartTime,
+		Input:          decodeJSONValue(s.Input),
+		Output:         decodeJSONValue(s.Output),
+		Metadata:       decodeMetadata(s.Metadata),
+		Tags:           s.Tags,
+		FeedbackScores: feedbackScoresFromPublic(s.FeedbackScores),
+		Comments:       commentsFromPublic(s.Comments),
+	}
+	if s.ID.Set {
+		info.ID = s.ID.Value.String()
+	}
+	if s.TraceID.Set {
+		info.TraceID = s.TraceID.Value.String()
+	}
+	if s.ParentSpanID.Set {
+		info.ParentSpanID = s.ParentSpanID.Value.String()
+	}
+	if s.ProjectID.Set {
+		info.ProjectID = s.ProjectID.Value.String()
+	}
+	if s.ProjectName.Set {
+		info.ProjectName = s.ProjectName.Value
+	}
+	if s.Name.Set {
+		info.Name = s.Name.Value
+	}
+	if s.Type.Set {
+		info.Type = string(s.Type.Value)
+	}
+	if s.EndTime.Set {
+		info.EndTime = s.EndTime.Value
+	}
+	if s.Duration.Set {
+		info.Duration = durationFromMillis(s.Duration.Value)
+	}
+	if s.Model.Set {
+		info.Model = s.Model.Value
+	}
+	if s.Provider.Set {
+		info.Provider = s.Provider.Value
+	}
+	if s.ErrorInfo.Set {
+		info.ErrorInfo = errorInfoFromAPI(s.ErrorInfo.Value)
+	}
+	if s.Usage.Set {
+		info.Usage = make(map[string]int, len(s.Usage.Value))
+		for k, v := range s.Usage.Value {
+			info.Usage[k] = int(v)
 		}
-		spans = append(spans, span)
 	}
+	if s.TotalEstimatedCost.Set {
+		info.TotalCost = s.TotalEstimatedCost.Value
+	}
+	if s.CreatedAt.Set {
+		info.CreatedAt = s.CreatedAt.Value
+	}
+	if s.LastUpdatedAt.Set {
+		info.LastUpdatedAt = s.LastUpdatedAt.Value
+	}
+	return info
+}
+
+// decodeMetadata decodes raw JSON metadata, returning nil unless it is an object.
+func decodeMetadata(raw []byte) map[string]any {
+	metadata, _ := decodeJSONValue(raw).(map[string]any)
+	return metadata
+}
 
-	return spans, nil
+// durationFromMillis converts a duration in fractional milliseconds.
+func durationFromMillis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// jsonOrNull marshals v to raw JSON, returning "null" for nil or unmarshalable values.
+// JsonListString fields must always hold valid JSON: an empty value produces
+// malformed JSON in the generated encoder.
+func jsonOrNull(v any) []byte {
+	if v == nil {
+		return []byte("null")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return []byte("null")
+	}
+	return data
+}
+
+// metadataJSON marshals metadata to raw JSON, returning "null" when empty.
+func metadataJSON(metadata map[string]any) []byte {
+	if len(metadata) == 0 {
+		return []byte("null")
+	}
+	return jsonOrNull(metadata)
 }
@@ -10,6 +10,33 @@ import (
 	"github.com/agentplexus/go-opik/testutil"
 )
 
+// newTestClient creates a client that logs to ms, which is usually a
+// tracetest.NewServer.
+func newTestClient(t *testing.T, ms *testutil.MockServer, opts ...Option) *Client {
+	t.Helper()
+
+	client, err := NewClient(append([]Option{
+		WithURL(ms.URL()),
+		WithAPIKey("test-key"),
+		WithProjectName("test-project"),
+	}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	return client
+}
+
+// lastItem returns the last of the items recorded by a tracing server,
+// failing the test if there are none.
+func lastItem(t *testing.T, items []map[string]any) map[string]any {
+	t.Helper()
+
+	if len(items) == 0 {
+		t.Fatal("nothing was sent")
+	}
+	return items[len(items)-1]
+}
+
 func TestNewClient(t *testing.T) {
 	t.Run("with defaults", func(t *testing.T) {
 		// Create mock server
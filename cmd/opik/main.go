@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	opik "github.com/agentplexus/go-opik"
 )
@@ -30,6 +31,8 @@ func main() {
 		runDatasets(args)
 	case "experiments":
 		runExperiments(args)
+	case "comments":
+		runComments(args)
 	case "help":
 		printUsage()
 	default:
@@ -51,6 +54,7 @@ Commands:
   traces       View and manage traces
   datasets     Manage datasets
   experiments  Manage experiments
+  comments     Manage comments on traces, spans and threads
   help         Show this help message
 
 Use "opik <command> -h" for more information about a command.
@@ -326,3 +330,152 @@ func runExperiments(args []string) {
 
 	fs.Usage()
 }
+
+func runComments(args []string) {
+	fs := flag.NewFlagSet("comments", flag.ExitOnError)
+	traceID := fs.String("trace", "", "Trace ID to manage comments on")
+	spanID := fs.String("span", "", "Span ID to manage comments on")
+	threadID := fs.String("thread", "", "Thread ID to manage comments on")
+	project := fs.String("project", "", "Project of the thread")
+	list := fs.Bool("list", false, "List comments")
+	add := fs.String("add", "", "Add a comment with the given text")
+	get := fs.String("get", "", "Get a comment by ID")
+	update := fs.String("update", "", "Update the comment with the given ID (requires -text)")
+	text := fs.String("text", "", "New comment text for -update")
+	deleteFlag := fs.String("delete", "", "Delete the comment with the given ID")
+	format := fs.String("format", "text", "Output format (text, json)")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing arguments: %v\n", err)
+		os.Exit(1)
+	}
+
+	targets := 0
+	for _, id := range []string{*traceID, *spanID, *threadID} {
+		if id != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		fmt.Fprintf(os.Stderr, "Error: exactly one of -trace, -span or -thread is required\n")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	opts := []opik.Option{}
+	if *project != "" {
+		opts = append(opts, opik.WithProjectName(*project))
+	}
+
+	client, err := opik.NewClient(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating client: %v\n", err)
+		os.Exit(1)
+	}
+
+	var thread *opik.Thread
+	if *threadID != "" {
+		thread, err = client.GetThread(ctx, *threadID)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error finding thread: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	printComments := func(comments []opik.Comment) {
+		if *format == "json" {
+			_ = json.NewEncoder(os.Stdout).Encode(comments)
+			return
+		}
+		fmt.Println("Comments:")
+		for _, c := range comments {
+			fmt.Printf("  - %s [%s, %s]: %s\n", c.ID, c.CreatedBy, c.CreatedAt.Format(time.RFC3339), c.Text)
+		}
+	}
+
+	switch {
+	case *list:
+		var comments []opik.Comment
+		switch {
+		case *traceID != "":
+			comments, err = client.ListTraceComments(ctx, *traceID)
+		case *spanID != "":
+			comments, err = client.ListSpanComments(ctx, *spanID)
+		default:
+			comments = thread.Comments()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing comments: %v\n", err)
+			os.Exit(1)
+		}
+		printComments(comments)
+
+	case *add != "":
+		var comment *opik.Comment
+		switch {
+		case *traceID != "":
+			comment, err = client.AddTraceComment(ctx, *traceID, *add)
+		case *spanID != "":
+			comment, err = client.AddSpanComment(ctx, *spanID, *add)
+		default:
+			comment, err = thread.AddComment(ctx, *add)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error adding comment: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Added comment: %s\n", comment.ID)
+
+	case *get != "":
+		var comment *opik.Comment
+		switch {
+		case *traceID != "":
+			comment, err = client.GetTraceComment(ctx, *traceID, *get)
+		case *spanID != "":
+			comment, err = client.GetSpanComment(ctx, *spanID, *get)
+		default:
+			comment, err = thread.GetComment(ctx, *get)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error getting comment: %v\n", err)
+			os.Exit(1)
+		}
+		printComments([]opik.Comment{*comment})
+
+	case *update != "":
+		if *text == "" {
+			fmt.Fprintf(os.Stderr, "Error: -text is required for -update\n")
+			os.Exit(1)
+		}
+		switch {
+		case *traceID != "":
+			err = client.UpdateTraceComment(ctx, *update, *text)
+		case *spanID != "":
+			err = client.UpdateSpanComment(ctx, *update, *text)
+		default:
+			err = thread.UpdateComment(ctx, *update, *text)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating comment: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Updated comment: %s\n", *update)
+
+	case *deleteFlag != "":
+		switch {
+		case *traceID != "":
+			err = client.DeleteTraceComments(ctx, *deleteFlag)
+		case *spanID != "":
+			err = client.DeleteSpanComments(ctx, *deleteFlag)
+		default:
+			err = thread.DeleteComments(ctx, *deleteFlag)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting comment: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted comment: %s\n", *deleteFlag)
+
+	default:
+		fs.Usage()
+	}
+}
@@ -0,0 +1,288 @@
+package opik
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// Comment represents a comment attached to a trace, span, or thread.
+type Comment struct {
+	ID            string
+	Text          string
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+	CreatedBy     string
+	LastUpdatedBy string
+}
+
+// commentFromAPI converts an API comment.
+func commentFromAPI(c api.Comment) Comment {
+	comment := Comment{
+		Text: c.Text,
+	}
+	if c.ID.Set {
+		comment.ID = c.ID.Value.String()
+	}
+	if c.CreatedAt.Set {
+		comment.CreatedAt = c.CreatedAt.Value
+	}
+	if c.LastUpdatedAt.Set {
+		comment.LastUpdatedAt = c.LastUpdatedAt.Value
+	}
+	if c.CreatedBy.Set {
+		comment.CreatedBy = c.CreatedBy.Value
+	}
+	if c.LastUpdatedBy.Set {
+		comment.LastUpdatedBy = c.LastUpdatedBy.Value
+	}
+	return comment
+}
+
+// commentsFromAPI converts a list of API comments.
+func commentsFromAPI(comments []api.Comment) []Comment {
+	if len(comments) == 0 {
+		return nil
+	}
+	result := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		result = append(result, commentFromAPI(c))
+	}
+	return result
+}
+
+// commentsFromPublic converts a list of API comments as returned with
+// traces and spans.
+func commentsFromPublic(comments []api.CommentPublic) []Comment {
+	if len(comments) == 0 {
+		return nil
+	}
+	result := make([]Comment, 0, len(comments))
+	for _, c := range comments {
+		result = append(result, commentFromAPI(api.Comment(c)))
+	}
+	return result
+}
+
+// newComment builds the request for a new comment, with a client-generated ID.
+func newComment(text string) (api.Comment, error) {
+	commentUUID, err := uuid.NewV7()
+	if err != nil {
+		return api.Comment{}, fmt.Errorf("failed to generate comment UUID: %w", err)
+	}
+	return api.Comment{
+		ID:   api.NewOptUUID(commentUUID),
+		Text: text,
+	}, nil
+}
+
+// addedComment returns the comment created by a request.
+func addedComment(req api.Comment) *Comment {
+	return &Comment{
+		ID:        req.ID.Value.String(),
+		Text:      req.Text,
+		CreatedAt: time.Now(),
+	}
+}
+
+// newBatchDelete builds a batch delete request from IDs.
+func newBatchDelete(ids []string) (api.BatchDelete, error) {
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		u, err := uuid.Parse(id)
+		if err != nil {
+			return api.BatchDelete{}, err
+		}
+		parsed = append(parsed, u)
+	}
+	return api.BatchDelete{Ids: parsed}, nil
+}
+
+// removeComments returns comments without the ones with the given IDs.
+func removeComments(comments []Comment, commentIDs []string) []Comment {
+	deleted := make(map[string]bool, len(commentIDs))
+	for _, id := range commentIDs {
+		deleted[id] = true
+	}
+	kept := comments[:0]
+	for _, c := range comments {
+		if !deleted[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// AddTraceComment adds a comment to a trace.
+func (c *Client) AddTraceComment(ctx context.Context, traceID, text string) (*Comment, error) {
+	traceUUID, err := uuid.Parse(traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newComment(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.apiClient.AddTraceComment(ctx, api.NewOptComment(req), api.AddTraceCommentParams{ID: traceUUID}); err != nil {
+		return nil, err
+	}
+	return addedComment(req), nil
+}
+
+// GetTraceComment retrieves a comment on a trace.
+func (c *Client) GetTraceComment(ctx context.Context, traceID, commentID string) (*Comment, error) {
+	traceUUID, err := uuid.Parse(traceID)
+	if err != nil {
+		return nil, err
+	}
+	commentUUID, err := uuid.Parse(commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.apiClient.GetTraceComment(ctx, api.GetTraceCommentParams{
+		TraceId:   traceUUID,
+		CommentId: commentUUID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := resp.(type) {
+	case *api.Comment:
+		comment := commentFromAPI(*r)
+		return &comment, nil
+	case *api.ErrorMessage:
+		return nil, newAPIError(404, r)
+	default:
+		return nil, ErrCommentNotFound
+	}
+}
+
+// ListTraceComments returns the comments on a trace.
+func (c *Client) ListTraceComments(ctx context.Context, traceID string) ([]Comment, error) {
+	trace, err := c.GetTrace(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	return trace.Comments(), nil
+}
+
+// UpdateTraceComment replaces the text of a comment on a trace.
+func (c *Client) UpdateTraceComment(ctx context.Context, commentID, text string) error {
+	commentUUID, err := uuid.Parse(commentID)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.apiClient.UpdateTraceComment(ctx, api.NewOptComment(api.Comment{
+		Text: text,
+	}), api.UpdateTraceCommentParams{CommentId: commentUUID})
+	if err != nil {
+		return err
+	}
+	if _, ok := res.(*api.UpdateTraceCommentNotFound); ok {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// DeleteTraceComments deletes comments from traces.
+func (c *Client) DeleteTraceComments(ctx context.Context, commentIDs ...string) error {
+	req, err := newBatchDelete(commentIDs)
+	if err != nil {
+		return err
+	}
+	return c.apiClient.DeleteTraceComments(ctx, api.NewOptBatchDelete(req))
+}
+
+// AddSpanComment adds a comment to a span.
+func (c *Client) AddSpanComment(ctx context.Context, spanID, text string) (*Comment, error) {
+	spanUUID, err := uuid.Parse(spanID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newComment(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.apiClient.AddSpanComment(ctx, api.NewOptComment(req), api.AddSpanCommentParams{ID: spanUUID}); err != nil {
+		return nil, err
+	}
+	return addedComment(req), nil
+}
+
+// GetSpanComment retrieves a comment on a span.
+func (c *Client) GetSpanComment(ctx context.Context, spanID, commentID string) (*Comment, error) {
+	spanUUID, err := uuid.Parse(spanID)
+	if err != nil {
+		return nil, err
+	}
+	commentUUID, err := uuid.Parse(commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.apiClient.GetSpanComment(ctx, api.GetSpanCommentParams{
+		SpanId:    spanUUID,
+		CommentId: commentUUID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := resp.(type) {
+	case *api.Comment:
+		comment := commentFromAPI(*r)
+		return &comment, nil
+	case *api.ErrorMessage:
+		return nil, newAPIError(404, r)
+	default:
+		return nil, ErrCommentNotFound
+	}
+}
+
+// ListSpanComments returns the comments on a span.
+func (c *Client) ListSpanComments(ctx context.Context, spanID string) ([]Comment, error) {
+	span, err := c.GetSpan(ctx, spanID)
+	if err != nil {
+		return nil, err
+	}
+	return span.Comments, nil
+}
+
+// UpdateSpanComment replaces the text of a comment on a span.
+func (c *Client) UpdateSpanComment(ctx context.Context, commentID, text string) error {
+	commentUUID, err := uuid.Parse(commentID)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.apiClient.UpdateSpanComment(ctx, api.NewOptComment(api.Comment{
+		Text: text,
+	}), api.UpdateSpanCommentParams{CommentId: commentUUID})
+	if err != nil {
+		return err
+	}
+	if _, ok := res.(*api.UpdateSpanCommentNotFound); ok {
+		return ErrCommentNotFound
+	}
+	return nil
+}
+
+// DeleteSpanComments deletes comments from spans.
+func (c *Client) DeleteSpanComments(ctx context.Context, commentIDs ...string) error {
+	req, err := newBatchDelete(commentIDs)
+	if err != nil {
+		return err
+	}
+	return c.apiClient.DeleteSpanComments(ctx, api.NewOptBatchDelete(req))
+}
@@ -0,0 +1,146 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+const testCommentID = "01890a5d-ac96-774b-bcce-b302099a8201"
+
+func TestTraceComments(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/"+testTraceID+"/comments").
+		WithHeaders(map[string]string{"Location": "/v1/private/traces/" + testTraceID + "/comments/1"}).
+		Respond(http.StatusCreated, nil)
+	ms.OnPatch("/v1/private/traces/comments/"+testCommentID).Respond(http.StatusNoContent, nil)
+	ms.OnPost("/v1/private/traces/comments/delete").Respond(http.StatusNoContent, nil)
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	trace := &Trace{client: client, id: testTraceID}
+	ctx := context.Background()
+
+	comment, err := trace.AddComment(ctx, "hallucinated the refund policy")
+	if err != nil {
+		t.Fatalf("AddComment error: %v", err)
+	}
+	var sent struct {
+		ID   string `json:"id"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(ms.LastRequest().Body, &sent); err != nil {
+		t.Fatalf("unmarshal comment: %v", err)
+	}
+	if sent.ID != comment.ID || sent.Text != "hallucinated the refund policy" {
+		t.Errorf("sent %+v, returned %+v", sent, comment)
+	}
+	if len(trace.Comments()) != 1 {
+		t.Fatalf("Comments() = %+v", trace.Comments())
+	}
+
+	// Update a comment loaded from the server
+	trace.comments[0].ID = testCommentID
+	if err := trace.UpdateComment(ctx, testCommentID, "fixed in v2"); err != nil {
+		t.Fatalf("UpdateComment error: %v", err)
+	}
+	if trace.Comments()[0].Text != "fixed in v2" {
+		t.Errorf("Comments() after update = %+v", trace.Comments())
+	}
+
+	if err := trace.DeleteComments(ctx, testCommentID); err != nil {
+		t.Fatalf("DeleteComments error: %v", err)
+	}
+	var deleted struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.Unmarshal(ms.LastRequest().Body, &deleted); err != nil {
+		t.Fatalf("unmarshal delete: %v", err)
+	}
+	if len(deleted.IDs) != 1 || deleted.IDs[0] != testCommentID || len(trace.Comments()) != 0 {
+		t.Errorf("deleted %v, Comments() = %+v", deleted.IDs, trace.Comments())
+	}
+}
+
+func TestSpanComments(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	spanID := "01890a5d-ac96-774b-bcce-b302099a8101"
+	ms.OnPost("/v1/private/spans/"+spanID+"/comments").
+		WithHeaders(map[string]string{"Location": "/v1/private/spans/" + spanID + "/comments/1"}).
+		Respond(http.StatusCreated, nil)
+	ms.OnGet("/v1/private/spans/"+spanID+"/comments/"+testCommentID).RespondJSON(http.StatusOK, map[string]any{
+		"id":         testCommentID,
+		"text":       "wrong tool",
+		"created_by": "reviewer",
+		"created_at": time.Now().Format(time.RFC3339Nano),
+	})
+	ms.OnPatch("/v1/private/spans/comments/"+testCommentID).Respond(http.StatusNotFound, nil)
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	span := &Span{client: client, id: spanID, traceID: testTraceID}
+	ctx := context.Background()
+
+	if _, err := span.AddComment(ctx, "wrong tool"); err != nil {
+		t.Fatalf("AddComment error: %v", err)
+	}
+	if len(span.Comments()) != 1 || span.Comments()[0].Text != "wrong tool" {
+		t.Errorf("Comments() = %+v", span.Comments())
+	}
+
+	comment, err := client.GetSpanComment(ctx, spanID, testCommentID)
+	if err != nil {
+		t.Fatalf("GetSpanComment error: %v", err)
+	}
+	if comment.ID != testCommentID || comment.CreatedBy != "reviewer" {
+		t.Errorf("GetSpanComment = %+v", comment)
+	}
+
+	if err := span.UpdateComment(ctx, testCommentID, "x"); !errors.Is(err, ErrCommentNotFound) {
+		t.Errorf("UpdateComment error = %v, want ErrCommentNotFound", err)
+	}
+}
+
+func TestListTraceComments(t *testing.T) {
+	ms, client := newTestTreeServer(t)
+	defer ms.Close()
+
+	comments, err := client.ListTraceComments(context.Background(), testTraceID)
+	if err != nil {
+		t.Fatalf("ListTraceComments error: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Text != "looks good" {
+		t.Errorf("comments = %+v", comments)
+	}
+}
+
+func TestCommentInvalidIDs(t *testing.T) {
+	client, err := NewClient(WithURL("http://localhost:0"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.AddTraceComment(ctx, "not-a-uuid", "x"); err == nil {
+		t.Error("AddTraceComment should reject an invalid trace ID")
+	}
+	if _, err := client.GetSpanComment(ctx, testTraceID, "not-a-uuid"); err == nil {
+		t.Error("GetSpanComment should reject an invalid comment ID")
+	}
+	if err := client.DeleteTraceComments(ctx, testCommentID, "not-a-uuid"); err == nil {
+		t.Error("DeleteTraceComments should reject an invalid comment ID")
+	}
+}
@@ -11,10 +11,17 @@ const (
 	traceContextKey contextKey = iota
 	spanContextKey
 	clientContextKey
+	noRetryContextKey
+	remoteContextKey
 )
 
-// ContextWithTrace returns a new context with the trace attached.
+// ContextWithTrace returns a new context with the trace attached. If the
+// trace is mirrored as an OpenTelemetry span, that span becomes the current
+// OpenTelemetry span as well.
 func ContextWithTrace(ctx context.Context, trace *Trace) context.Context {
+	if trace != nil {
+		ctx = withOTelSpan(ctx, trace.otelSpan)
+	}
 	return context.WithValue(ctx, traceContextKey, trace)
 }
 
@@ -26,8 +33,13 @@ func TraceFromContext(ctx context.Context) *Trace {
 	return nil
 }
 
-// ContextWithSpan returns a new context with the span attached.
+// ContextWithSpan returns a new context with the span attached. If the span
+// is mirrored as an OpenTelemetry span, that span becomes the current
+// OpenTelemetry span as well.
 func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span != nil {
+		ctx = withOTelSpan(ctx, span.otelSpan)
+	}
 	return context.WithValue(ctx, spanContextKey, span)
 }
 
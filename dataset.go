@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 
 	"github.com/go-faster/jx"
 	"github.com/google/uuid"
@@ -175,6 +176,11 @@ func (c *Client) GetDatasetByName(ctx context.Context, name string) (*Dataset, e
 	}, nil
 }
 
+// AllDatasets iterates over all datasets, fetching them page by page.
+func (c *Client) AllDatasets(ctx context.Context, opts ...PageOption) iter.Seq2[*Dataset, error] {
+	return paginate(ctx, c.ListDatasets, opts)
+}
+
 // ListDatasets lists all datasets.
 //
 //nolint:dupl // Similar structure to ListPrompts is intentional for consistency
@@ -307,6 +313,11 @@ func (d *Dataset) InsertItems(ctx context.Context, items []map[string]any, opts
 	return d.client.apiClient.CreateOrUpdateDatasetItems(ctx, api.NewOptDatasetItemBatchWrite(req))
 }
 
+// AllItems iterates over all items of the dataset, fetching them page by page.
+func (d *Dataset) AllItems(ctx context.Context, opts ...PageOption) iter.Seq2[DatasetItem, error] {
+	return paginate(ctx, d.GetItems, opts)
+}
+
 // GetItems retrieves items from the dataset.
 func (d *Dataset) GetItems(ctx context.Context, page, size int) ([]DatasetItem, error) {
 	datasetUUID, err := uuid.Parse(d.id)
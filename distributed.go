@@ -2,41 +2,195 @@ package opik
 
 import (
 	"context"
+	"encoding/hex"
+	"maps"
 	"net/http"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DistributedTraceHeaders contains trace context for cross-service propagation.
+//
+// Besides the Opik trace and parent span IDs, it carries the W3C Trace
+// Context and Baggage headers, so that a trace can pass through services
+// that are only instrumented with OpenTelemetry. When TraceID is empty, the
+// Opik IDs are derived from TraceParent.
 type DistributedTraceHeaders struct {
 	TraceID      string `json:"opik_trace_id"`
 	ParentSpanID string `json:"opik_parent_span_id"`
+	// TraceParent is the W3C traceparent header value.
+	TraceParent string `json:"traceparent,omitempty"`
+	// TraceState is the W3C tracestate header value.
+	TraceState string `json:"tracestate,omitempty"`
+	// Baggage is the W3C baggage header value.
+	Baggage string `json:"baggage,omitempty"`
 }
 
 // Header names for distributed tracing.
 const (
 	HeaderTraceID      = "X-Opik-Trace-ID"
 	HeaderParentSpanID = "X-Opik-Parent-Span-ID"
+	HeaderTraceParent  = "traceparent"
+	HeaderTraceState   = "tracestate"
+	HeaderBaggage      = "baggage"
 )
 
+// traceStateKey is the tracestate entry holding the IDs of the Opik trace
+// and span that made a request: 32 hex digits for the trace, followed by 32
+// for the span if there is one. It lets the receiving service use the exact
+// IDs, since W3C span IDs are shorter than Opik span IDs, and the W3C trace
+// ID is not derived from the Opik one when it comes from OpenTelemetry.
+const traceStateKey = "opik"
+
 // GetDistributedTraceHeaders returns the current trace context from the context.
 // This can be used to propagate trace context across service boundaries.
+//
+// The W3C headers are set when the trace and span IDs are UUIDs. If ctx holds
+// an OpenTelemetry span, such as the one mirroring the current span when the
+// client has a tracer provider, traceparent names that span. Otherwise it is
+// derived from the Opik IDs; a trace continued from a W3C traceparent keeps
+// its original W3C trace ID, and the tracestate received with it is passed
+// on. The received baggage and baggage set with the OpenTelemetry baggage API
+// are included as well.
+//
+// For traces dropped by a sampler, the sampled flag of traceparent is
+// cleared and the Opik trace and parent span IDs are left out, so that
+// downstream services do not record spans of a trace that is not exported.
 func GetDistributedTraceHeaders(ctx context.Context) DistributedTraceHeaders {
 	headers := DistributedTraceHeaders{}
+	sampled := true
 
 	if trace := TraceFromContext(ctx); trace != nil {
 		headers.TraceID = trace.ID()
+		sampled = trace.sampling.sampled()
 	}
 
 	if span := SpanFromContext(ctx); span != nil {
 		headers.ParentSpanID = span.ID()
+		sampled = span.sampling.sampled()
 		if headers.TraceID == "" {
 			headers.TraceID = span.TraceID()
 		}
 	}
 
+	remote, _ := ctx.Value(remoteContextKey).(DistributedTraceHeaders)
+	headers.Baggage = remote.Baggage
+	if b := baggage.FromContext(ctx); b.Len() > 0 {
+		headers.Baggage = b.String()
+	}
+
+	if sc, ok := w3cSpanContext(ctx, headers, remote, sampled); ok {
+		carrier := propagation.MapCarrier{}
+		propagation.TraceContext{}.Inject(trace.ContextWithSpanContext(ctx, sc), carrier)
+		headers.TraceParent = carrier.Get(HeaderTraceParent)
+		headers.TraceState = carrier.Get(HeaderTraceState)
+	}
+
+	if !sampled {
+		headers.TraceID = ""
+		headers.ParentSpanID = ""
+	}
 	return headers
 }
 
+// w3cSpanContext returns the W3C span context of the Opik trace and span in
+// headers. remote holds the headers the trace was continued from, if any.
+func w3cSpanContext(ctx context.Context, headers, remote DistributedTraceHeaders, sampled bool) (trace.SpanContext, bool) {
+	traceUUID, err := uuid.Parse(headers.TraceID)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	opikState := hex.EncodeToString(traceUUID[:])
+	// Without a span, the parent is the trace itself, which the receiving
+	// service maps back to no parent span
+	parentUUID := traceUUID
+	if headers.ParentSpanID != "" {
+		if parentUUID, err = uuid.Parse(headers.ParentSpanID); err != nil {
+			return trace.SpanContext{}, false
+		}
+		opikState += hex.EncodeToString(parentUUID[:])
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && !sc.IsRemote() {
+		if state, err := sc.TraceState().Insert(traceStateKey, opikState); err == nil {
+			sc = sc.WithTraceState(state)
+		}
+		if !sampled {
+			sc = sc.WithTraceFlags(sc.TraceFlags().WithSampled(false))
+		}
+		return sc, true
+	}
+
+	traceID := trace.TraceID(traceUUID)
+	var state trace.TraceState
+	if remoteSC, ok := remote.spanContext(); ok && remote.TraceID == headers.TraceID {
+		traceID = remoteSC.TraceID()
+		state = remoteSC.TraceState()
+	}
+	if s, err := state.Insert(traceStateKey, opikState); err == nil {
+		state = s
+	}
+
+	var flags trace.TraceFlags
+	if sampled {
+		flags = trace.FlagsSampled
+	}
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanIDFromUUID(parentUUID),
+		TraceFlags: flags,
+		TraceState: state,
+		Remote:     true,
+	})
+	return sc, sc.IsValid()
+}
+
+// spanContext parses the W3C traceparent and tracestate headers.
+func (h DistributedTraceHeaders) spanContext() (trace.SpanContext, bool) {
+	if h.TraceParent == "" {
+		return trace.SpanContext{}, false
+	}
+	carrier := propagation.MapCarrier{
+		HeaderTraceParent: h.TraceParent,
+		HeaderTraceState:  h.TraceState,
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	sc := trace.SpanContextFromContext(ctx)
+	return sc, sc.IsValid()
+}
+
+// opikIDs derives the Opik trace and parent span IDs from the W3C headers.
+// The IDs in the opik tracestate entry take precedence, so that spans of
+// services between two Opik services are skipped.
+func (h DistributedTraceHeaders) opikIDs() (traceID, parentSpanID string) {
+	sc, ok := h.spanContext()
+	if !ok {
+		return "", ""
+	}
+	traceUUID := uuidFromTraceID(sc.TraceID())
+	parentUUID := uuidFromSpanID(sc.TraceID(), sc.SpanID())
+	if state := sc.TraceState().Get(traceStateKey); len(state) == 32 || len(state) == 64 {
+		if id, err := uuid.Parse(state[:32]); err == nil {
+			traceUUID, parentUUID = id, id
+		}
+		if len(state) == 64 {
+			if id, err := uuid.Parse(state[32:]); err == nil {
+				parentUUID = id
+			}
+		}
+	}
+	if parentUUID == traceUUID {
+		return traceUUID.String(), ""
+	}
+	return traceUUID.String(), parentUUID.String()
+}
+
 // InjectDistributedTraceHeaders adds trace context headers to an HTTP request.
+// W3C headers already present on the request, such as those set by
+// OpenTelemetry instrumentation, are left unchanged.
 func InjectDistributedTraceHeaders(ctx context.Context, req *http.Request) {
 	headers := GetDistributedTraceHeaders(ctx)
 
@@ -46,21 +200,121 @@ func InjectDistributedTraceHeaders(ctx context.Context, req *http.Request) {
 	if headers.ParentSpanID != "" {
 		req.Header.Set(HeaderParentSpanID, headers.ParentSpanID)
 	}
+
+	if headers.TraceParent != "" && req.Header.Get(HeaderTraceParent) == "" {
+		req.Header.Set(HeaderTraceParent, headers.TraceParent)
+		if headers.TraceState != "" {
+			req.Header.Set(HeaderTraceState, headers.TraceState)
+		}
+	}
+	if headers.Baggage != "" && req.Header.Get(HeaderBaggage) == "" {
+		req.Header.Set(HeaderBaggage, headers.Baggage)
+	}
 }
 
 // ExtractDistributedTraceHeaders extracts trace context from HTTP request headers.
+// Without Opik headers, the Opik trace and parent span IDs are derived from
+// the W3C traceparent header.
 func ExtractDistributedTraceHeaders(req *http.Request) DistributedTraceHeaders {
-	return DistributedTraceHeaders{
+	headers := DistributedTraceHeaders{
 		TraceID:      req.Header.Get(HeaderTraceID),
 		ParentSpanID: req.Header.Get(HeaderParentSpanID),
+		TraceParent:  req.Header.Get(HeaderTraceParent),
+		TraceState:   req.Header.Get(HeaderTraceState),
+		Baggage:      req.Header.Get(HeaderBaggage),
+	}
+	if headers.TraceID == "" {
+		headers.TraceID, headers.ParentSpanID = headers.opikIDs()
+	}
+	return headers
+}
+
+// HasOpikTrace reports whether h identifies a trace recorded in Opik, with
+// the Opik headers or the opik tracestate entry. Headers holding only a W3C
+// traceparent, such as those forwarded by a proxy or a service instrumented
+// with OpenTelemetry alone, do not.
+func (h DistributedTraceHeaders) HasOpikTrace() bool {
+	sc, ok := h.spanContext()
+	if !ok {
+		return h.TraceID != ""
+	}
+	if sc.TraceState().Get(traceStateKey) != "" {
+		return true
+	}
+	// ExtractDistributedTraceHeaders derives the trace ID from traceparent
+	return h.TraceID != "" && h.TraceID != uuidFromTraceID(sc.TraceID()).String()
+}
+
+// ContextWithDistributedTraceHeaders returns a copy of ctx holding the trace
+// context in headers. Requests made with the context continue the W3C trace,
+// and the received baggage can be read with the OpenTelemetry baggage API.
+//
+// If headers only hold a W3C trace (see HasOpikTrace), a trace started with
+// the context records it in Opik: the trace ID is derived from traceparent,
+// the W3C parent span ID is kept in the "w3c.parent_span_id" metadata, and
+// the trace is dropped if the sampled flag of traceparent is cleared.
+func ContextWithDistributedTraceHeaders(ctx context.Context, headers DistributedTraceHeaders) context.Context {
+	if headers.TraceID == "" {
+		headers.TraceID, headers.ParentSpanID = headers.opikIDs()
+	}
+	ctx = context.WithValue(ctx, remoteContextKey, headers)
+	if sc, ok := headers.spanContext(); ok && !trace.SpanContextFromContext(ctx).IsValid() {
+		// Spans mirrored with the client's tracer continue the W3C trace
+		ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	if b, err := baggage.Parse(headers.Baggage); err == nil && b.Len() > 0 {
+		ctx = baggage.ContextWithBaggage(ctx, b)
 	}
+	return ctx
+}
+
+// remoteW3CTrace returns the W3C span context a trace started with ctx
+// continues, if ctx holds headers that only hold a W3C trace and no Opik
+// trace or span has been started with it yet.
+func remoteW3CTrace(ctx context.Context) (trace.SpanContext, bool) {
+	if TraceFromContext(ctx) != nil || SpanFromContext(ctx) != nil {
+		return trace.SpanContext{}, false
+	}
+	remote, ok := ctx.Value(remoteContextKey).(DistributedTraceHeaders)
+	if !ok || remote.HasOpikTrace() {
+		return trace.SpanContext{}, false
+	}
+	return remote.spanContext()
+}
+
+// withW3CParent returns a copy of metadata recording the W3C parent span of
+// a trace continuing sc.
+func withW3CParent(metadata map[string]any, sc trace.SpanContext) map[string]any {
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = make(map[string]any, 1)
+	}
+	metadata["w3c.parent_span_id"] = sc.SpanID().String()
+	return metadata
 }
 
 // ContinueTrace creates a new span that continues from distributed trace headers.
 // This is used when receiving a request from another service that has trace context.
+//
+// The returned context keeps the W3C headers, so that requests made with it
+// continue the same W3C trace, and holds the received baggage, which can be
+// read with the OpenTelemetry baggage API.
+//
+// Headers that only hold a W3C trace (see HasOpikTrace) have no Opik trace
+// to continue. A trace is then created for the W3C trace, as described for
+// ContextWithDistributedTraceHeaders, and the span is its root span.
+//
+// If traceparent has the sampled flag cleared, the calling service does not
+// record the trace. The span and its children are then dropped as well, and
+// the flag is passed on to downstream services.
 func (c *Client) ContinueTrace(ctx context.Context, headers DistributedTraceHeaders, spanName string, opts ...SpanOption) (context.Context, *Span, error) {
 	if headers.TraceID == "" {
-		// No trace context, start a new trace and return the first span
+		headers.TraceID, headers.ParentSpanID = headers.opikIDs()
+	}
+	ctx = ContextWithDistributedTraceHeaders(ctx, headers)
+
+	if !headers.HasOpikTrace() {
+		// No Opik trace to continue, start a new trace and return the first span
 		ctx, trace, err := StartTrace(ctx, c, spanName)
 		if err != nil {
 			return ctx, nil, err
@@ -73,8 +327,13 @@ func (c *Client) ContinueTrace(ctx context.Context, headers DistributedTraceHead
 		return newCtx, span, nil
 	}
 
+	var sampling *samplingState
+	if sc, ok := headers.spanContext(); ok && !sc.IsSampled() {
+		sampling = &samplingState{decision: SamplingDrop}
+	}
+
 	// Create a span that continues the distributed trace
-	span, err := c.createSpanWithParent(ctx, headers.TraceID, headers.ParentSpanID, spanName, opts...)
+	span, err := c.createSpanWithParent(ctx, headers.TraceID, headers.ParentSpanID, sampling, spanName, opts...)
 	if err != nil {
 		return ctx, nil, err
 	}
@@ -84,8 +343,37 @@ func (c *Client) ContinueTrace(ctx context.Context, headers DistributedTraceHead
 }
 
 // createSpanWithParent creates a span with explicit trace and parent span IDs.
-func (c *Client) createSpanWithParent(ctx context.Context, traceID, parentSpanID, name string, opts ...SpanOption) (*Span, error) {
-	return c.createSpan(ctx, traceID, parentSpanID, name, opts...)
+// sampling is the sampling state of the remote trace; nil records the span.
+func (c *Client) createSpanWithParent(ctx context.Context, traceID, parentSpanID string, sampling *samplingState, name string, opts ...SpanOption) (*Span, error) {
+	return c.createSpan(ctx, traceID, parentSpanID, c.ProjectName(), sampling, name, opts...)
+}
+
+// uuidFromTraceID maps a W3C trace ID to an Opik trace ID by setting the
+// UUID version 7 and variant bits. Trace IDs that are Opik trace IDs map to
+// themselves.
+func uuidFromTraceID(id trace.TraceID) uuid.UUID {
+	u := uuid.UUID(id)
+	u[6] = u[6]&0x0f | 0x70
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// uuidFromSpanID maps a W3C span ID to an Opik span ID. The first half of
+// the UUID is that of the trace, so that span IDs sort with their trace, and
+// the second half is the span ID with the variant bits set.
+func uuidFromSpanID(traceID trace.TraceID, spanID trace.SpanID) uuid.UUID {
+	u := uuidFromTraceID(traceID)
+	copy(u[8:], spanID[:])
+	u[8] = u[8]&0x3f | 0x80
+	return u
+}
+
+// spanIDFromUUID maps an Opik span ID to a W3C span ID, the inverse of
+// uuidFromSpanID for span IDs it created.
+func spanIDFromUUID(id uuid.UUID) trace.SpanID {
+	var spanID trace.SpanID
+	copy(spanID[:], id[8:])
+	return spanID
 }
 
 // PropagatingRoundTripper wraps an http.RoundTripper to automatically inject
@@ -2,9 +2,16 @@ package opik
 
 import (
 	"context"
+	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/baggage"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
 )
 
 func TestDistributedTraceHeaders(t *testing.T) {
@@ -233,3 +240,222 @@ func TestDistributedTraceHeadersJSON(t *testing.T) {
 		t.Error("struct fields not set correctly")
 	}
 }
+
+func TestInjectW3CHeaders(t *testing.T) {
+	traceID := uuid.Must(uuid.NewV7())
+	spanID := uuid.Must(uuid.NewV7())
+	ctx := ContextWithTrace(context.Background(), &Trace{id: traceID.String()})
+	ctx = ContextWithSpan(ctx, &Span{id: spanID.String(), traceID: traceID.String()})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	InjectDistributedTraceHeaders(ctx, req)
+
+	traceHex, spanHex := hex.EncodeToString(traceID[:]), hex.EncodeToString(spanID[:])
+	want := "00-" + traceHex + "-" + spanHex[16:] + "-01"
+	if got := req.Header.Get(HeaderTraceParent); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	if got := req.Header.Get(HeaderTraceState); got != "opik="+traceHex+spanHex {
+		t.Errorf("tracestate = %q, want %q", got, "opik="+traceHex+spanHex)
+	}
+
+	// The receiving service maps the W3C headers back to the exact Opik IDs
+	req.Header.Del(HeaderTraceID)
+	req.Header.Del(HeaderParentSpanID)
+	headers := ExtractDistributedTraceHeaders(req)
+	if headers.TraceID != traceID.String() || headers.ParentSpanID != spanID.String() {
+		t.Errorf("extracted %s/%s, want %s/%s", headers.TraceID, headers.ParentSpanID, traceID, spanID)
+	}
+}
+
+func TestInjectW3CHeadersTraceOnly(t *testing.T) {
+	traceID := uuid.Must(uuid.NewV7())
+	ctx := ContextWithTrace(context.Background(), &Trace{id: traceID.String()})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	InjectDistributedTraceHeaders(ctx, req)
+	if req.Header.Get(HeaderTraceParent) == "" || req.Header.Get(HeaderTraceState) != "opik="+hex.EncodeToString(traceID[:]) {
+		t.Fatalf("traceparent = %q, tracestate = %q", req.Header.Get(HeaderTraceParent), req.Header.Get(HeaderTraceState))
+	}
+
+	headers := ExtractDistributedTraceHeaders(&http.Request{Header: http.Header{
+		"Traceparent": {req.Header.Get(HeaderTraceParent)},
+	}})
+	if headers.TraceID != traceID.String() || headers.ParentSpanID != "" {
+		t.Errorf("extracted %q/%q, want %q with no parent", headers.TraceID, headers.ParentSpanID, traceID)
+	}
+}
+
+func TestInjectKeepsExistingW3CHeaders(t *testing.T) {
+	traceID := uuid.Must(uuid.NewV7())
+	ctx := ContextWithTrace(context.Background(), &Trace{id: traceID.String()})
+
+	existing := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.Header.Set(HeaderTraceParent, existing)
+	InjectDistributedTraceHeaders(ctx, req)
+
+	if got := req.Header.Get(HeaderTraceParent); got != existing {
+		t.Errorf("traceparent = %q, want %q", got, existing)
+	}
+	if got := req.Header.Get(HeaderTraceID); got != traceID.String() {
+		t.Errorf("Header %s = %q, want %q", HeaderTraceID, got, traceID)
+	}
+}
+
+func TestExtractW3CHeaders(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	req.Header.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(HeaderTraceState, "vendor=abc")
+
+	headers := ExtractDistributedTraceHeaders(req)
+
+	if headers.TraceID != "4bf92f35-77b3-7da6-a3ce-929d0e0e4736" {
+		t.Errorf("TraceID = %q", headers.TraceID)
+	}
+	if headers.ParentSpanID != "4bf92f35-77b3-7da6-80f0-67aa0ba902b7" {
+		t.Errorf("ParentSpanID = %q", headers.ParentSpanID)
+	}
+	if headers.TraceState != "vendor=abc" {
+		t.Errorf("TraceState = %q", headers.TraceState)
+	}
+
+	// Opik headers take precedence
+	req.Header.Set(HeaderTraceID, "trace-123")
+	if headers := ExtractDistributedTraceHeaders(req); headers.TraceID != "trace-123" || headers.ParentSpanID != "" {
+		t.Errorf("extracted %q/%q, want the Opik headers", headers.TraceID, headers.ParentSpanID)
+	}
+}
+
+func TestContinueTraceFromW3C(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+
+	w3cTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx, span, err := client.ContinueTrace(context.Background(), DistributedTraceHeaders{
+		TraceParent: "00-" + w3cTraceID + "-00f067aa0ba902b7-01",
+		TraceState:  "vendor=abc",
+		Baggage:     "tenant=acme",
+	}, "handler")
+	if err != nil {
+		t.Fatalf("ContinueTrace error: %v", err)
+	}
+	if span.TraceID() != "4bf92f35-77b3-7da6-a3ce-929d0e0e4736" || span.ParentSpanID() != "" {
+		t.Errorf("span trace = %s, parent = %s", span.TraceID(), span.ParentSpanID())
+	}
+
+	// No Opik service recorded the W3C trace, so the trace is created here
+	traces := tracetest.TraceWrites(ms)
+	if len(traces) != 1 || traces[0]["id"] != span.TraceID() {
+		t.Fatalf("traces = %v, want one with ID %s", traces, span.TraceID())
+	}
+	if metadata, _ := traces[0]["metadata"].(map[string]any); metadata["w3c.parent_span_id"] != "00f067aa0ba902b7" {
+		t.Errorf("trace metadata = %v, want the W3C parent span", traces[0]["metadata"])
+	}
+	spans := tracetest.SpanWrites(ms)
+	if len(spans) != 1 || spans[0]["trace_id"] != span.TraceID() || spans[0]["parent_span_id"] != nil {
+		t.Errorf("spans = %v, want a root span of the trace", spans)
+	}
+	if got := baggage.FromContext(ctx).Member("tenant").Value(); got != "acme" {
+		t.Errorf("baggage tenant = %q, want acme", got)
+	}
+
+	// Outgoing requests continue the original W3C trace
+	headers := GetDistributedTraceHeaders(ctx)
+	spanUUID := uuid.MustParse(span.ID())
+	spanHex := hex.EncodeToString(spanUUID[:])
+	if want := "00-" + w3cTraceID + "-" + spanHex[16:] + "-01"; headers.TraceParent != want {
+		t.Errorf("TraceParent = %q, want %q", headers.TraceParent, want)
+	}
+	if want := "opik=4bf92f3577b37da6a3ce929d0e0e4736" + spanHex + ",vendor=abc"; headers.TraceState != want {
+		t.Errorf("TraceState = %q, want %q", headers.TraceState, want)
+	}
+	if headers.Baggage != "tenant=acme" {
+		t.Errorf("Baggage = %q, want tenant=acme", headers.Baggage)
+	}
+}
+
+func TestContinueTraceUnsampled(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+
+	w3cTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx, span, err := client.ContinueTrace(context.Background(), DistributedTraceHeaders{
+		TraceParent: "00-" + w3cTraceID + "-00f067aa0ba902b7-00",
+	}, "handler")
+	if err != nil {
+		t.Fatalf("ContinueTrace error: %v", err)
+	}
+	child, err := span.Span(ctx, "child")
+	if err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+	_ = child.End(ctx)
+	_ = span.End(ctx)
+	if n := ms.RequestCount(); n != 0 {
+		t.Errorf("sent %d requests for an unsampled trace, want 0", n)
+	}
+
+	// Downstream services are told not to record the trace either
+	headers := GetDistributedTraceHeaders(ctx)
+	if !strings.HasPrefix(headers.TraceParent, "00-"+w3cTraceID+"-") || !strings.HasSuffix(headers.TraceParent, "-00") {
+		t.Errorf("TraceParent = %q, want the unsampled W3C trace", headers.TraceParent)
+	}
+	if headers.TraceID != "" || headers.ParentSpanID != "" {
+		t.Errorf("Opik IDs = %q/%q, want none", headers.TraceID, headers.ParentSpanID)
+	}
+}
+
+func TestInjectUnsampledTrace(t *testing.T) {
+	traceID := uuid.Must(uuid.NewV7())
+	ctx := ContextWithTrace(context.Background(), &Trace{
+		id:       traceID.String(),
+		sampling: &samplingState{decision: SamplingDrop},
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	InjectDistributedTraceHeaders(ctx, req)
+
+	if got := req.Header.Get(HeaderTraceID); got != "" {
+		t.Errorf("Header %s = %q, want none", HeaderTraceID, got)
+	}
+	if got := req.Header.Get(HeaderTraceParent); !strings.HasSuffix(got, "-00") {
+		t.Errorf("traceparent = %q, want the sampled flag cleared", got)
+	}
+
+	// The receiving service drops the trace as well
+	headers := ExtractDistributedTraceHeaders(req)
+	if sc, ok := headers.spanContext(); !ok || sc.IsSampled() {
+		t.Errorf("extracted span context = %v, %v; want unsampled", sc, ok)
+	}
+}
+
+func TestHasOpikTrace(t *testing.T) {
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	tests := []struct {
+		name    string
+		headers DistributedTraceHeaders
+		want    bool
+	}{
+		{"empty", DistributedTraceHeaders{}, false},
+		{"Opik headers", DistributedTraceHeaders{TraceID: "trace-123"}, true},
+		{"traceparent only", DistributedTraceHeaders{TraceParent: traceParent}, false},
+		{"derived trace ID", DistributedTraceHeaders{
+			TraceID:     "4bf92f35-77b3-7da6-a3ce-929d0e0e4736",
+			TraceParent: traceParent,
+		}, false},
+		{"opik tracestate", DistributedTraceHeaders{
+			TraceParent: traceParent,
+			TraceState:  "opik=0192f0c1a2b37c4d8e5f6a7b8c9d0e1f,vendor=abc",
+		}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.headers.HasOpikTrace(); got != tt.want {
+			t.Errorf("%s: HasOpikTrace() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package opik
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// ErrorInfo describes an error recorded on a trace or span.
+// Traces and spans with error info are shown as failed in the Opik UI.
+type ErrorInfo struct {
+	// ExceptionType is the Go type of the error, e.g. "*os.PathError",
+	// or "panic" for recovered panics.
+	ExceptionType string
+	// Message is the error message.
+	Message string
+	// Traceback lists the wrapped error chain followed by a goroutine stack trace.
+	Traceback string
+}
+
+// PanicError wraps a value recovered from a panic together with the stack
+// of the panicking goroutine.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+// NewPanicError creates a PanicError for a recovered value. It must be called
+// from the deferred function that recovered, so that the stack includes the
+// frames that panicked.
+func NewPanicError(recovered any) *PanicError {
+	return &PanicError{
+		Value: recovered,
+		Stack: debug.Stack(),
+	}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the recovered value if it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// NewErrorInfo builds error info for err. The exception type is the outermost
+// error type that is not a plain fmt.Errorf or errors.Join wrapper, and the
+// traceback contains every error in the chain. The stack trace is the one
+// captured by a PanicError in the chain, or else the current goroutine's stack.
+// Returns nil if err is nil.
+func NewErrorInfo(err error) *ErrorInfo {
+	if err == nil {
+		return nil
+	}
+
+	chain := errorChain(err)
+
+	info := &ErrorInfo{
+		ExceptionType: fmt.Sprintf("%T", chain[0]),
+		Message:       err.Error(),
+	}
+	for _, e := range chain {
+		if !isWrapperError(e) {
+			info.ExceptionType = fmt.Sprintf("%T", e)
+			break
+		}
+	}
+
+	var stack []byte
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		info.ExceptionType = "panic"
+		stack = panicErr.Stack
+	} else {
+		stack = debug.Stack()
+	}
+
+	var tb strings.Builder
+	for i, e := range chain {
+		if i > 0 {
+			tb.WriteString("caused by: ")
+		}
+		fmt.Fprintf(&tb, "%T: %s\n", e, e.Error())
+	}
+	tb.WriteString("\n")
+	tb.Write(stack)
+	info.Traceback = tb.String()
+
+	return info
+}
+
+// errorChain flattens the tree of wrapped errors depth-first, following both
+// Unwrap() error and Unwrap() []error.
+func errorChain(err error) []error {
+	var chain []error
+	var walk func(error)
+	walk = func(e error) {
+		if e == nil {
+			return
+		}
+		chain = append(chain, e)
+		switch u := e.(type) {
+		case interface{ Unwrap() error }:
+			walk(u.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, inner := range u.Unwrap() {
+				walk(inner)
+			}
+		}
+	}
+	walk(err)
+	return chain
+}
+
+// isWrapperError reports whether err is one of the standard library's
+// anonymous wrapper types, which say nothing about the kind of failure.
+func isWrapperError(err error) bool {
+	switch fmt.Sprintf("%T", err) {
+	case "*fmt.wrapError", "*fmt.wrapErrors", "*errors.joinError":
+		return true
+	}
+	return false
+}
+
+// write converts the error info to its create payload.
+func (e *ErrorInfo) write() api.ErrorInfoWrite {
+	return api.ErrorInfoWrite{
+		ExceptionType: e.ExceptionType,
+		Message:       api.NewOptString(e.Message),
+		Traceback:     e.Traceback,
+	}
+}
+
+// errorInfoFromAPI converts error info returned by the API.
+func errorInfoFromAPI(e api.ErrorInfoPublic) *ErrorInfo {
+	return &ErrorInfo{
+		ExceptionType: e.ExceptionType,
+		Message:       e.Message.Or(""),
+		Traceback:     e.Traceback,
+	}
+}
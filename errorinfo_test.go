@@ -0,0 +1,124 @@
+package opik
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNewErrorInfoNil(t *testing.T) {
+	if info := NewErrorInfo(nil); info != nil {
+		t.Errorf("NewErrorInfo(nil) = %+v, want nil", info)
+	}
+}
+
+func TestNewErrorInfoChain(t *testing.T) {
+	_, openErr := os.Open("/definitely/missing/file")
+	err := fmt.Errorf("loading config: %w", openErr)
+
+	info := NewErrorInfo(err)
+
+	if info.ExceptionType != "*fs.PathError" {
+		t.Errorf("ExceptionType = %q, want %q", info.ExceptionType, "*fs.PathError")
+	}
+	if info.Message != err.Error() {
+		t.Errorf("Message = %q, want %q", info.Message, err.Error())
+	}
+	if !strings.Contains(info.Traceback, "caused by: *fs.PathError") {
+		t.Errorf("Traceback missing wrapped error:\n%s", info.Traceback)
+	}
+	if !strings.Contains(info.Traceback, "caused by: syscall.Errno") {
+		t.Errorf("Traceback missing root cause:\n%s", info.Traceback)
+	}
+	if !strings.Contains(info.Traceback, "TestNewErrorInfoChain") {
+		t.Errorf("Traceback missing caller stack:\n%s", info.Traceback)
+	}
+}
+
+func TestNewErrorInfoJoined(t *testing.T) {
+	err := errors.Join(fs.ErrNotExist, fs.ErrPermission)
+
+	info := NewErrorInfo(err)
+
+	if info.ExceptionType != "*errors.errorString" {
+		t.Errorf("ExceptionType = %q, want %q", info.ExceptionType, "*errors.errorString")
+	}
+	if strings.Count(info.Traceback, "caused by:") != 2 {
+		t.Errorf("Traceback should list both joined errors:\n%s", info.Traceback)
+	}
+}
+
+func TestNewErrorInfoPanic(t *testing.T) {
+	var err error
+	func() {
+		defer func() {
+			err = NewPanicError(recover())
+		}()
+		panicker()
+	}()
+
+	info := NewErrorInfo(err)
+
+	if info.ExceptionType != "panic" {
+		t.Errorf("ExceptionType = %q, want %q", info.ExceptionType, "panic")
+	}
+	if info.Message != "panic: boom" {
+		t.Errorf("Message = %q, want %q", info.Message, "panic: boom")
+	}
+	if !strings.Contains(info.Traceback, "panicker") {
+		t.Errorf("Traceback should contain the panicking frame:\n%s", info.Traceback)
+	}
+}
+
+func panicker() {
+	panic("boom")
+}
+
+func TestPanicErrorUnwrap(t *testing.T) {
+	panicErr := &PanicError{Value: fs.ErrClosed}
+	if !errors.Is(panicErr, fs.ErrClosed) {
+		t.Error("PanicError should unwrap to the recovered error")
+	}
+
+	panicErr = &PanicError{Value: "not an error"}
+	if panicErr.Unwrap() != nil {
+		t.Error("PanicError with non-error value should unwrap to nil")
+	}
+}
+
+func TestRecordError(t *testing.T) {
+	span := &Span{
+		id:      "01900000-0000-7000-8000-000000000002",
+		traceID: "01900000-0000-7000-8000-000000000001",
+	}
+	span.RecordError(nil)
+	if span.ErrorInfo() != nil {
+		t.Error("RecordError(nil) should not record anything")
+	}
+
+	span.RecordError(fs.ErrNotExist)
+	w, err := span.write()
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if !w.ErrorInfo.Set || w.ErrorInfo.Value.Message.Value != fs.ErrNotExist.Error() {
+		t.Errorf("ErrorInfo = %+v, want message %q", w.ErrorInfo, fs.ErrNotExist.Error())
+	}
+	u := spanUpdateFromWrite(w)
+	if !u.ErrorInfo.Set || u.ErrorInfo.Value.ExceptionType != w.ErrorInfo.Value.ExceptionType {
+		t.Errorf("update ErrorInfo = %+v, want it copied from write", u.ErrorInfo)
+	}
+
+	trace := &Trace{id: "01900000-0000-7000-8000-000000000001"}
+	trace.apply(&traceOptions{errorInfo: NewErrorInfo(fs.ErrPermission)})
+	tw, err := trace.write()
+	if err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	if !tw.ErrorInfo.Set || !traceUpdateFromWrite(tw).ErrorInfo.Set {
+		t.Error("trace ErrorInfo should be set on write and update")
+	}
+}
@@ -1,6 +1,19 @@
 package opik
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/ogen-go/ogen/validate"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
 
 // Sentinel errors for the Opik SDK.
 var (
@@ -22,15 +35,41 @@ var (
 	// ErrSpanNotFound is returned when a span cannot be found.
 	ErrSpanNotFound = errors.New("opik: span not found")
 
+	// ErrThreadNotFound is returned when a thread cannot be found.
+	ErrThreadNotFound = errors.New("opik: thread not found")
+
+	// ErrCommentNotFound is returned when a comment cannot be found.
+	ErrCommentNotFound = errors.New("opik: comment not found")
+
 	// ErrDatasetNotFound is returned when a dataset cannot be found.
 	ErrDatasetNotFound = errors.New("opik: dataset not found")
 
 	// ErrExperimentNotFound is returned when an experiment cannot be found.
 	ErrExperimentNotFound = errors.New("opik: experiment not found")
 
+	// ErrProjectNotFound is returned when a project cannot be found.
+	ErrProjectNotFound = errors.New("opik: project not found")
+
 	// ErrPromptNotFound is returned when a prompt cannot be found.
 	ErrPromptNotFound = errors.New("opik: prompt not found")
 
+	// ErrFeedbackDefinitionNotFound is returned when a feedback definition cannot be found.
+	ErrFeedbackDefinitionNotFound = errors.New("opik: feedback definition not found")
+
+	// ErrInvalidFeedbackScore is returned when a feedback score does not match its definition.
+	ErrInvalidFeedbackScore = errors.New("opik: invalid feedback score")
+
+	// ErrCircuitOpen is returned when a request is not sent because the
+	// circuit breaker is open.
+	ErrCircuitOpen = errors.New("opik: circuit breaker is open")
+
+	// ErrSpoolFull is returned when batched items do not fit in the spool.
+	ErrSpoolFull = errors.New("opik: spool is full")
+
+	// ErrClientClosed is reported for batched items added after the client
+	// was closed.
+	ErrClientClosed = errors.New("opik: client is closed")
+
 	// ErrInvalidInput is returned when input validation fails.
 	ErrInvalidInput = errors.New("opik: invalid input")
 
@@ -55,39 +94,126 @@ func (e *APIError) Error() string {
 	return "opik: API error: " + e.Message
 }
 
+// newAPIError converts an error message returned by the API into an APIError.
+func newAPIError(statusCode int, msg *api.ErrorMessage) *APIError {
+	apiErr := &APIError{StatusCode: statusCode}
+	if msg.Code.Set {
+		apiErr.StatusCode = int(msg.Code.Value)
+	}
+	if msg.Message.Set {
+		apiErr.Message = msg.Message.Value
+	}
+	if msg.Details.Set {
+		apiErr.Details = msg.Details.Value
+	}
+	return apiErr
+}
+
+// statusCode returns the HTTP status code carried by err, if any.
+func statusCode(err error) (int, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode, true
+	}
+	var statusErr *validate.UnexpectedStatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode, true
+	}
+	return 0, false
+}
+
 // IsNotFound returns true if the error indicates a resource was not found.
 func IsNotFound(err error) bool {
 	if err == nil {
 		return false
 	}
-	if apiErr, ok := err.(*APIError); ok {
-		return apiErr.StatusCode == 404
+	if code, ok := statusCode(err); ok {
+		return code == 404
 	}
 	return errors.Is(err, ErrTraceNotFound) ||
 		errors.Is(err, ErrSpanNotFound) ||
+		errors.Is(err, ErrThreadNotFound) ||
+		errors.Is(err, ErrCommentNotFound) ||
 		errors.Is(err, ErrDatasetNotFound) ||
 		errors.Is(err, ErrExperimentNotFound) ||
-		errors.Is(err, ErrPromptNotFound)
+		errors.Is(err, ErrPromptNotFound) ||
+		errors.Is(err, ErrFeedbackDefinitionNotFound) ||
+		errors.Is(err, ErrProjectNotFound)
 }
 
 // IsUnauthorized returns true if the error indicates an authentication failure.
 func IsUnauthorized(err error) bool {
-	if err == nil {
-		return false
-	}
-	if apiErr, ok := err.(*APIError); ok {
-		return apiErr.StatusCode == 401
-	}
-	return false
+	code, ok := statusCode(err)
+	return ok && code == 401
 }
 
 // IsRateLimited returns true if the error indicates rate limiting.
 func IsRateLimited(err error) bool {
-	if err == nil {
+	code, ok := statusCode(err)
+	return ok && code == 429
+}
+
+// IsBadRequest returns true if the error indicates an invalid request.
+func IsBadRequest(err error) bool {
+	code, ok := statusCode(err)
+	return ok && code == 400
+}
+
+// IsServerError returns true if the error is a 5xx response from the server.
+func IsServerError(err error) bool {
+	code, ok := statusCode(err)
+	return ok && code >= 500
+}
+
+// IsCircuitOpen returns true if the request was not sent because the
+// client's circuit breaker is open.
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// IsRetryable returns true if a request that failed with err may succeed
+// if repeated later: network failures, timeouts, truncated responses, rate
+// limiting, server errors and an open circuit breaker. Client errors such as
+// invalid requests are not retryable, and neither are canceled requests or
+// requests and responses that cannot be encoded or decoded.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
 		return false
 	}
-	if apiErr, ok := err.(*APIError); ok {
-		return apiErr.StatusCode == 429
+	if code, ok := statusCode(err); ok {
+		return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+	}
+	if errors.Is(err, ErrCircuitOpen) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	// http.Client wraps every error in a *url.Error, which is a net.Error
+	// even for permanent failures such as an unsupported scheme or an
+	// invalid certificate, so only its cause is classified
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && (opErr.Op == "dial" || opErr.Op == "read")
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of the
+// response that caused err, or zero if there is none.
+func RetryAfter(err error) time.Duration {
+	var statusErr *validate.UnexpectedStatusCodeError
+	if errors.As(err, &statusErr) && statusErr.Payload != nil {
+		if d, ok := parseRetryAfter(statusErr.Payload.Header.Get("Retry-After")); ok {
+			return d
+		}
 	}
-	return false
+	return 0
 }
@@ -1,7 +1,19 @@
 package opik
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
 	"testing"
 )
 
@@ -155,3 +167,53 @@ func TestAPIErrorStatusCodes(t *testing.T) {
 		}
 	}
 }
+
+func TestIsRetryable(t *testing.T) {
+	_, encodeErr := json.Marshal(math.NaN())
+	decodeErr := json.Unmarshal([]byte(`{"id": trace}`), new(map[string]any))
+	_, schemeErr := http.Get("ftp://localhost/traces")
+	_, parseErr := http.Get("http://local host/traces")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"APIError 400", &APIError{StatusCode: 400}, false},
+		{"APIError 404", &APIError{StatusCode: 404}, false},
+		{"APIError 408", &APIError{StatusCode: 408}, true},
+		{"APIError 429", &APIError{StatusCode: 429}, true},
+		{"APIError 503", &APIError{StatusCode: 503}, true},
+		{"circuit open", fmt.Errorf("do request: %w", ErrCircuitOpen), true},
+		{"canceled", context.Canceled, false},
+		{"deadline exceeded", context.DeadlineExceeded, true},
+		{"connection refused", &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"connection reset", fmt.Errorf("read response: %w", syscall.ECONNRESET), true},
+		{"truncated response", fmt.Errorf("decode response: %w", io.ErrUnexpectedEOF), true},
+		{"encode error", fmt.Errorf("encode request: %w", encodeErr), false},
+		{"decode error", fmt.Errorf("decode response: %w", decodeErr), false},
+		{"read timeout", &url.Error{Op: "Post", URL: "http://localhost", Err: &net.OpError{Op: "read", Err: os.ErrDeadlineExceeded}}, true},
+		{"unsupported scheme", schemeErr, false},
+		{"invalid URL", parseErr, false},
+		{"invalid certificate", &url.Error{Op: "Post", URL: "https://localhost", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !IsBadRequest(&APIError{StatusCode: 400}) || IsBadRequest(&APIError{StatusCode: 404}) {
+		t.Error("IsBadRequest should only match 400 errors")
+	}
+	if !IsServerError(&APIError{StatusCode: 502}) || IsServerError(&APIError{StatusCode: 429}) {
+		t.Error("IsServerError should only match 5xx errors")
+	}
+	if !IsCircuitOpen(fmt.Errorf("do request: %w", ErrCircuitOpen)) {
+		t.Error("IsCircuitOpen should match wrapped ErrCircuitOpen")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 
 	"github.com/google/uuid"
 
@@ -192,6 +193,14 @@ func (c *Client) GetExperiment(ctx context.Context, experimentID string) (*Exper
 	}
 }
 
+// AllExperiments iterates over the experiments of a dataset, fetching them
+// page by page.
+func (c *Client) AllExperiments(ctx context.Context, datasetID string, opts ...PageOption) iter.Seq2[*Experiment, error] {
+	return paginate(ctx, func(ctx context.Context, page, size int) ([]*Experiment, error) {
+		return c.ListExperiments(ctx, datasetID, page, size)
+	}, opts)
+}
+
 // ListExperiments lists experiments for a dataset.
 func (c *Client) ListExperiments(ctx context.Context, datasetID string, page, size int) ([]*Experiment, error) {
 	datasetUUID, err := uuid.Parse(datasetID)
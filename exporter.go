@@ -0,0 +1,476 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// OpenTelemetry GenAI semantic convention attributes and events read by the
+// SpanExporter.
+const (
+	attrGenAIOperation        = "gen_ai.operation.name"
+	attrGenAIRequestModel     = "gen_ai.request.model"
+	attrGenAIResponseModel    = "gen_ai.response.model"
+	attrGenAIProvider         = "gen_ai.provider.name"
+	attrGenAISystem           = "gen_ai.system"
+	attrGenAIConversationID   = "gen_ai.conversation.id"
+	attrGenAIUsagePrefix      = "gen_ai.usage."
+	attrGenAIInputMessages    = "gen_ai.input.messages"
+	attrGenAIOutputMessages   = "gen_ai.output.messages"
+	attrGenAIInstructions     = "gen_ai.system_instructions"
+	attrGenAIPromptPrefix     = "gen_ai.prompt."
+	attrGenAICompletionPrefix = "gen_ai.completion."
+	attrGenAIToolName         = "gen_ai.tool.name"
+	attrGenAIToolArguments    = "gen_ai.tool.call.arguments"
+	attrGenAIToolResult       = "gen_ai.tool.call.result"
+
+	eventGenAIChoice     = "gen_ai.choice"
+	eventGenAIPrompt     = "gen_ai.content.prompt"
+	eventGenAICompletion = "gen_ai.content.completion"
+	eventException       = "exception"
+	attrExceptionType    = "exception.type"
+	attrExceptionMsg     = "exception.message"
+	attrExceptionStack   = "exception.stacktrace"
+)
+
+// SpanExporter is an OpenTelemetry span exporter that writes spans to Opik,
+// so that code instrumented with OpenTelemetry shows up in Opik without
+// using the Opik tracing API.
+//
+// Every OpenTelemetry span becomes an Opik span, and every root span also
+// becomes an Opik trace with the same name, input and output. Spans whose
+// parent is remote join the trace created by the service that started it.
+// Opik IDs are derived from the OpenTelemetry trace and span IDs, so spans
+// exported in separate batches, or by different services, end up in the
+// same trace.
+//
+// Attributes following the GenAI semantic conventions set the model,
+// provider, usage, input and output of the span:
+//
+//   - gen_ai.request.model (or gen_ai.response.model) sets the model.
+//   - gen_ai.provider.name (or gen_ai.system) sets the provider.
+//   - gen_ai.usage.input_tokens and gen_ai.usage.output_tokens set the
+//     prompt and completion token counts; other gen_ai.usage.* attributes
+//     are kept under their own names.
+//   - gen_ai.input.messages and gen_ai.output.messages, the indexed
+//     gen_ai.prompt.N.* and gen_ai.completion.N.* attributes, and the
+//     gen_ai.*.message and gen_ai.choice events set the input and output.
+//   - gen_ai.tool.call.arguments and gen_ai.tool.call.result set the input
+//     and output of tool calls.
+//   - gen_ai.conversation.id on a root span sets the trace's thread ID.
+//
+// Tool executions become SpanTypeTool spans, inference operations and
+// client spans with a model become SpanTypeLLM spans, and other spans are
+// SpanTypeGeneral. Spans with an error status are marked as failed, with the
+// details of the recorded exception event if there is one. Remaining
+// attributes, the span kind and events are kept as metadata.
+//
+// Spans are written to the client's project, with its redactor applied.
+// When the client has a batcher, they are queued; otherwise each export sends
+// a single batch request for traces and one for spans. The client's sampler
+// is not consulted, since OpenTelemetry samples spans itself.
+type SpanExporter struct {
+	client *Client
+
+	mu      sync.Mutex
+	stopped bool
+}
+
+var _ sdktrace.SpanExporter = (*SpanExporter)(nil)
+
+// NewSpanExporter creates a span exporter writing to client.
+func NewSpanExporter(client *Client) *SpanExporter {
+	return &SpanExporter{client: client}
+}
+
+// ExportSpans writes spans to Opik. Spans exported after Shutdown are
+// dropped.
+func (e *SpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	stopped := e.stopped
+	e.mu.Unlock()
+	if stopped || e.client.config.TracingDisabled || len(spans) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var (
+		traces []api.TraceWrite
+		writes []api.SpanWrite
+	)
+	for _, ros := range spans {
+		t, s := e.convert(ros)
+		if t != nil {
+			w, err := t.write()
+			if err != nil {
+				return err
+			}
+			traces = append(traces, w)
+		}
+		w, err := s.write()
+		if err != nil {
+			return err
+		}
+		writes = append(writes, w)
+	}
+
+	c := e.client
+	if c.batcher != nil {
+		for _, w := range traces {
+			c.batcher.Add(TraceBatchItem{create: true, write: w})
+		}
+		for _, w := range writes {
+			c.batcher.Add(SpanBatchItem{create: true, write: w})
+		}
+		return nil
+	}
+	if len(traces) > 0 {
+		if err := c.createTraces(ctx, traces); err != nil {
+			return err
+		}
+	}
+	return c.createSpans(ctx, writes)
+}
+
+// Shutdown stops the exporter. If the client has a batcher, it waits until
+// queued spans have been sent or ctx is done.
+func (e *SpanExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.stopped = true
+	e.mu.Unlock()
+	return e.flush(ctx)
+}
+
+// ForceFlush waits until spans queued by the client's batcher have been sent
+// or ctx is done.
+func (e *SpanExporter) ForceFlush(ctx context.Context) error {
+	return e.flush(ctx)
+}
+
+func (e *SpanExporter) flush(ctx context.Context) error {
+	if e.client.batcher == nil {
+		return ctx.Err()
+	}
+	timeout := 30 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	return e.client.batcher.Flush(timeout)
+}
+
+// convert builds the Opik span for ros, and the Opik trace if ros is a root
+// span.
+func (e *SpanExporter) convert(ros sdktrace.ReadOnlySpan) (*Trace, *Span) {
+	sc := ros.SpanContext()
+	traceID := uuidFromTraceID(sc.TraceID()).String()
+
+	attrs := make(map[string]attribute.Value, len(ros.Attributes()))
+	for _, kv := range ros.Attributes() {
+		attrs[string(kv.Key)] = kv.Value
+	}
+	take := func(key string) (attribute.Value, bool) {
+		v, ok := attrs[key]
+		delete(attrs, key)
+		return v, ok
+	}
+	takeString := func(keys ...string) string {
+		var s string
+		for _, key := range keys {
+			if v, ok := take(key); ok && s == "" {
+				s = v.Emit()
+			}
+		}
+		return s
+	}
+
+	endTime := ros.EndTime()
+	s := &Span{
+		client:      e.client,
+		id:          uuidFromSpanID(sc.TraceID(), sc.SpanID()).String(),
+		traceID:     traceID,
+		name:        ros.Name(),
+		projectName: e.client.ProjectName(),
+		spanType:    SpanTypeGeneral,
+		startTime:   ros.StartTime(),
+		endTime:     &endTime,
+		metadata:    make(map[string]any),
+		ended:       true,
+	}
+	if parent := ros.Parent(); parent.IsValid() {
+		s.parentSpanID = uuidFromSpanID(parent.TraceID(), parent.SpanID()).String()
+	}
+
+	operation := takeString(attrGenAIOperation)
+	s.model = takeString(attrGenAIRequestModel, attrGenAIResponseModel)
+	s.provider = takeString(attrGenAIProvider, attrGenAISystem)
+	threadID := takeString(attrGenAIConversationID)
+	_, isTool := attrs[attrGenAIToolName]
+
+	switch {
+	case operation == "execute_tool", operation == "" && isTool:
+		s.spanType = SpanTypeTool
+	case operation == "chat", operation == "text_completion", operation == "generate_content", operation == "embeddings":
+		s.spanType = SpanTypeLLM
+	case operation == "" && s.model != "" && ros.SpanKind() != trace.SpanKindServer && ros.SpanKind() != trace.SpanKindConsumer:
+		s.spanType = SpanTypeLLM
+	}
+	if operation != "" {
+		s.metadata[attrGenAIOperation] = operation
+	}
+
+	s.usage = genAIUsage(attrs)
+
+	input, output := genAIMessages(attrs, ros.Events())
+	if v, ok := take(attrGenAIToolArguments); ok {
+		input = jsonValue(v.Emit())
+	}
+	if v, ok := take(attrGenAIToolResult); ok {
+		output = jsonValue(v.Emit())
+	}
+	s.input, s.output = input, output
+
+	var events []map[string]any
+	for _, event := range ros.Events() {
+		switch {
+		case event.Name == eventException:
+			if s.errorInfo == nil {
+				s.errorInfo = exceptionErrorInfo(event.Attributes)
+			}
+		case isGenAIMessageEvent(event.Name):
+		default:
+			events = append(events, map[string]any{
+				"name":       event.Name,
+				"time":       event.Time,
+				"attributes": attributeMap(event.Attributes),
+			})
+		}
+	}
+	if status := ros.Status(); status.Code == codes.Error {
+		if s.errorInfo == nil {
+			s.errorInfo = &ErrorInfo{ExceptionType: "error"}
+		}
+		if s.errorInfo.Message == "" {
+			s.errorInfo.Message = status.Description
+		}
+	} else {
+		// Exceptions recorded on spans that did not fail were handled
+		s.errorInfo = nil
+	}
+
+	for key, v := range attrs {
+		s.metadata[key] = v.AsInterface()
+	}
+	s.metadata["otel.span_kind"] = ros.SpanKind().String()
+	if len(events) > 0 {
+		s.metadata["otel.events"] = events
+	}
+	if v, ok := ros.Resource().Set().Value("service.name"); ok {
+		s.metadata["service.name"] = v.Emit()
+	}
+
+	if ros.Parent().IsValid() {
+		return nil, s
+	}
+	t := &Trace{
+		client:      e.client,
+		id:          traceID,
+		name:        s.name,
+		projectName: s.projectName,
+		threadID:    threadID,
+		startTime:   s.startTime,
+		endTime:     s.endTime,
+		input:       s.input,
+		output:      s.output,
+		metadata:    s.metadata,
+		errorInfo:   s.errorInfo,
+		ended:       true,
+	}
+	return t, s
+}
+
+// genAIUsage removes the gen_ai.usage.* attributes from attrs and returns
+// them as Opik usage.
+func genAIUsage(attrs map[string]attribute.Value) map[string]int {
+	usage := make(map[string]int)
+	for key, v := range attrs {
+		name, ok := strings.CutPrefix(key, attrGenAIUsagePrefix)
+		if !ok || v.Type() != attribute.INT64 {
+			continue
+		}
+		delete(attrs, key)
+		switch name {
+		case "input_tokens", "prompt_tokens":
+			name = UsagePromptTokens
+		case "output_tokens", "completion_tokens":
+			name = UsageCompletionTokens
+		case "cache_read_input_tokens", "cached_input_tokens":
+			name = UsageCachedTokens
+		case "reasoning_output_tokens":
+			name = UsageReasoningTokens
+		}
+		usage[name] = int(v.AsInt64())
+	}
+	if len(usage) == 0 {
+		return nil
+	}
+	if _, ok := usage[UsageTotalTokens]; !ok {
+		usage[UsageTotalTokens] = usage[UsagePromptTokens] + usage[UsageCompletionTokens]
+	}
+	return usage
+}
+
+// genAIMessages removes the prompt and completion attributes from attrs and
+// returns them, together with those of message events, as span input and
+// output.
+func genAIMessages(attrs map[string]attribute.Value, events []sdktrace.Event) (input, output any) {
+	var in, out []any
+
+	if v, ok := attrs[attrGenAIInstructions]; ok {
+		delete(attrs, attrGenAIInstructions)
+		in = append(in, map[string]any{"role": "system", "content": jsonValue(v.Emit())})
+	}
+	if v, ok := attrs[attrGenAIInputMessages]; ok {
+		delete(attrs, attrGenAIInputMessages)
+		in = appendMessages(in, jsonValue(v.Emit()))
+	}
+	if v, ok := attrs[attrGenAIOutputMessages]; ok {
+		delete(attrs, attrGenAIOutputMessages)
+		out = appendMessages(out, jsonValue(v.Emit()))
+	}
+	in = append(in, indexedMessages(attrs, attrGenAIPromptPrefix)...)
+	out = append(out, indexedMessages(attrs, attrGenAICompletionPrefix)...)
+
+	for _, event := range events {
+		fields := attributeMap(event.Attributes)
+		switch {
+		case event.Name == eventGenAIChoice:
+			out = append(out, fields)
+		case event.Name == eventGenAIPrompt:
+			if prompt, ok := fields["gen_ai.prompt"].(string); ok {
+				in = appendMessages(in, jsonValue(prompt))
+			}
+		case event.Name == eventGenAICompletion:
+			if completion, ok := fields["gen_ai.completion"].(string); ok {
+				out = appendMessages(out, jsonValue(completion))
+			}
+		case isGenAIMessageEvent(event.Name):
+			if _, ok := fields["role"]; !ok {
+				role := strings.TrimSuffix(strings.TrimPrefix(event.Name, "gen_ai."), ".message")
+				fields["role"] = role
+			}
+			in = append(in, fields)
+		}
+	}
+
+	if len(in) > 0 {
+		input = map[string]any{"messages": in}
+	}
+	if len(out) > 0 {
+		output = map[string]any{"messages": out}
+	}
+	return input, output
+}
+
+// isGenAIMessageEvent reports whether name is a GenAI message event, such
+// as gen_ai.user.message or gen_ai.choice.
+func isGenAIMessageEvent(name string) bool {
+	switch name {
+	case eventGenAIChoice, eventGenAIPrompt, eventGenAICompletion:
+		return true
+	}
+	return strings.HasPrefix(name, "gen_ai.") && strings.HasSuffix(name, ".message")
+}
+
+// indexedMessages removes attributes of the form prefix.N.field from attrs
+// and returns them as messages ordered by N.
+func indexedMessages(attrs map[string]attribute.Value, prefix string) []any {
+	byIndex := make(map[int]map[string]any)
+	for key, v := range attrs {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok {
+			continue
+		}
+		index, field, ok := strings.Cut(rest, ".")
+		n, err := strconv.Atoi(index)
+		if !ok || err != nil {
+			continue
+		}
+		delete(attrs, key)
+		if byIndex[n] == nil {
+			byIndex[n] = make(map[string]any)
+		}
+		byIndex[n][field] = v.AsInterface()
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for n := range byIndex {
+		indexes = append(indexes, n)
+	}
+	sort.Ints(indexes)
+	messages := make([]any, 0, len(indexes))
+	for _, n := range indexes {
+		messages = append(messages, byIndex[n])
+	}
+	return messages
+}
+
+// appendMessages appends v to messages, flattening it if it is a list.
+func appendMessages(messages []any, v any) []any {
+	if list, ok := v.([]any); ok {
+		return append(messages, list...)
+	}
+	return append(messages, v)
+}
+
+// exceptionErrorInfo builds error info from the attributes of an exception
+// event.
+func exceptionErrorInfo(attrs []attribute.KeyValue) *ErrorInfo {
+	info := &ErrorInfo{ExceptionType: "error"}
+	for _, kv := range attrs {
+		switch kv.Key {
+		case attrExceptionType:
+			info.ExceptionType = kv.Value.Emit()
+		case attrExceptionMsg:
+			info.Message = kv.Value.Emit()
+		case attrExceptionStack:
+			info.Traceback = kv.Value.Emit()
+		}
+	}
+	return info
+}
+
+// attributeMap converts attributes to a map of their Go values.
+func attributeMap(attrs []attribute.KeyValue) map[string]any {
+	m := make(map[string]any, len(attrs))
+	for _, kv := range attrs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
+
+// jsonValue decodes s if it holds a JSON object or array, and returns it
+// unchanged otherwise.
+func jsonValue(s string) any {
+	trimmed := strings.TrimSpace(s)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var v any
+		if err := json.Unmarshal([]byte(trimmed), &v); err == nil {
+			return v
+		}
+	}
+	return s
+}
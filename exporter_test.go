@@ -0,0 +1,162 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+)
+
+// byName keys the traces or spans recorded by a tracing server by name.
+func byName(items []map[string]any) map[string]map[string]any {
+	named := make(map[string]map[string]any, len(items))
+	for _, item := range items {
+		named[item["name"].(string)] = item
+	}
+	return named
+}
+
+func TestSpanExporter(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	exporter := NewSpanExporter(newTestClient(t, ms, WithProjectName("otel-project")))
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	tracer := provider.Tracer("test")
+
+	ctx, root := tracer.Start(context.Background(), "agent",
+		trace.WithAttributes(
+			attribute.String("gen_ai.conversation.id", "conversation-1"),
+			attribute.String("app.version", "1.2.3"),
+		))
+	_, llm := tracer.Start(ctx, "chat gpt-4o",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("gen_ai.operation.name", "chat"),
+			attribute.String("gen_ai.request.model", "gpt-4o"),
+			attribute.String("gen_ai.provider.name", "openai"),
+			attribute.Int("gen_ai.usage.input_tokens", 12),
+			attribute.Int("gen_ai.usage.output_tokens", 5),
+			attribute.String("gen_ai.prompt.0.role", "user"),
+			attribute.String("gen_ai.prompt.0.content", "Hi"),
+			attribute.String("gen_ai.output.messages", `[{"role":"assistant","content":"Hello"}]`),
+		))
+	llm.End()
+	_, tool := tracer.Start(ctx, "execute_tool search",
+		trace.WithAttributes(
+			attribute.String("gen_ai.operation.name", "execute_tool"),
+			attribute.String("gen_ai.tool.call.arguments", `{"query":"go"}`),
+		))
+	tool.RecordError(errors.New("index unavailable"))
+	tool.SetStatus(codes.Error, "search failed")
+	tool.End()
+	root.End()
+
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+
+	traces, spans := byName(tracetest.TraceWrites(ms)), byName(tracetest.SpanWrites(ms))
+	if len(traces) != 1 || len(spans) != 3 {
+		t.Fatalf("exported %d traces and %d spans, want 1 and 3", len(traces), len(spans))
+	}
+
+	traceID := uuidFromTraceID(root.SpanContext().TraceID()).String()
+	rootID := uuidFromSpanID(root.SpanContext().TraceID(), root.SpanContext().SpanID()).String()
+	tr := traces["agent"]
+	if tr["id"] != traceID || tr["thread_id"] != "conversation-1" || tr["project_name"] != "otel-project" {
+		t.Errorf("trace = %+v", tr)
+	}
+	if metadata, _ := tr["metadata"].(map[string]any); metadata["app.version"] != "1.2.3" {
+		t.Errorf("trace metadata = %+v", tr["metadata"])
+	}
+	if spans["agent"]["id"] != rootID || spans["agent"]["parent_span_id"] != nil {
+		t.Errorf("root span = %+v", spans["agent"])
+	}
+
+	chat := spans["chat gpt-4o"]
+	if chat["type"] != SpanTypeLLM || chat["model"] != "gpt-4o" || chat["provider"] != "openai" {
+		t.Errorf("llm span = %+v", chat)
+	}
+	if chat["trace_id"] != traceID || chat["parent_span_id"] != rootID {
+		t.Errorf("llm span trace_id = %v, parent_span_id = %v", chat["trace_id"], chat["parent_span_id"])
+	}
+	usage, _ := chat["usage"].(map[string]any)
+	if usage["prompt_tokens"] != 12.0 || usage["completion_tokens"] != 5.0 || usage["total_tokens"] != 17.0 {
+		t.Errorf("usage = %+v", usage)
+	}
+	input, _ := json.Marshal(chat["input"])
+	output, _ := json.Marshal(chat["output"])
+	if string(input) != `{"messages":[{"content":"Hi","role":"user"}]}` {
+		t.Errorf("input = %s", input)
+	}
+	if string(output) != `{"messages":[{"content":"Hello","role":"assistant"}]}` {
+		t.Errorf("output = %s", output)
+	}
+	if metadata, _ := chat["metadata"].(map[string]any); metadata["otel.span_kind"] != "client" || metadata["gen_ai.usage.input_tokens"] != nil {
+		t.Errorf("llm span metadata = %+v", metadata)
+	}
+
+	search := spans["execute_tool search"]
+	if search["type"] != SpanTypeTool {
+		t.Errorf("tool span type = %v", search["type"])
+	}
+	if args, _ := search["input"].(map[string]any); args["query"] != "go" {
+		t.Errorf("tool input = %+v", search["input"])
+	}
+	errorInfo, _ := search["error_info"].(map[string]any)
+	if errorInfo["exception_type"] != "*errors.errorString" || errorInfo["message"] != "index unavailable" {
+		t.Errorf("error_info = %+v", errorInfo)
+	}
+}
+
+func TestSpanExporterBatching(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestBatchingClient(t, ms)
+	defer client.Close(time.Second)
+
+	exporter := NewSpanExporter(client.Client)
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	_, span := provider.Tracer("test").Start(context.Background(), "batched")
+	span.End()
+
+	// Shutting down the provider flushes the client's batcher
+	if err := provider.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	traces, spans := byName(tracetest.TraceWrites(ms)), byName(tracetest.SpanWrites(ms))
+	if len(traces) != 1 || len(spans) != 1 {
+		t.Errorf("exported %d traces and %d spans, want 1 and 1", len(traces), len(spans))
+	}
+}
+
+func TestOTelIDMapping(t *testing.T) {
+	opikID := uuid.Must(uuid.NewV7())
+	if got := uuidFromTraceID(trace.TraceID(opikID)); got != opikID {
+		t.Errorf("uuidFromTraceID(%s) = %s, want it unchanged", opikID, got)
+	}
+
+	traceID := trace.TraceID{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	spanID := trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+	traceUUID := uuidFromTraceID(traceID)
+	spanUUID := uuidFromSpanID(traceID, spanID)
+	for _, u := range []uuid.UUID{traceUUID, spanUUID} {
+		if u.Version() != 7 || u.Variant() != uuid.RFC4122 {
+			t.Errorf("%s: version %d, variant %s", u, u.Version(), u.Variant())
+		}
+	}
+	if spanUUID.String() != "ffffffff-ffff-7fff-8102-030405060708" {
+		t.Errorf("uuidFromSpanID = %s", spanUUID)
+	}
+}
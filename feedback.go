@@ -0,0 +1,536 @@
+package opik
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// FeedbackScore represents a feedback score recorded on a trace, span, or thread.
+type FeedbackScore struct {
+	Name          string
+	CategoryName  string
+	Value         float64
+	Reason        string
+	Source        string
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+	CreatedBy     string
+	LastUpdatedBy string
+}
+
+// feedbackScoreFromAPI converts an API feedback score.
+func feedbackScoreFromAPI(s api.FeedbackScore) FeedbackScore {
+	score := FeedbackScore{
+		Name:   s.Name,
+		Value:  s.Value,
+		Source: string(s.Source),
+	}
+	if s.CategoryName.Set {
+		score.CategoryName = s.CategoryName.Value
+	}
+	if s.Reason.Set {
+		score.Reason = s.Reason.Value
+	}
+	if s.CreatedAt.Set {
+		score.CreatedAt = s.CreatedAt.Value
+	}
+	if s.LastUpdatedAt.Set {
+		score.LastUpdatedAt = s.LastUpdatedAt.Value
+	}
+	if s.CreatedBy.Set {
+		score.CreatedBy = s.CreatedBy.Value
+	}
+	if s.LastUpdatedBy.Set {
+		score.LastUpdatedBy = s.LastUpdatedBy.Value
+	}
+	return score
+}
+
+// feedbackScoresFromAPI converts a list of API feedback scores.
+func feedbackScoresFromAPI(scores []api.FeedbackScore) []FeedbackScore {
+	if len(scores) == 0 {
+		return nil
+	}
+	result := make([]FeedbackScore, 0, len(scores))
+	for _, s := range scores {
+		result = append(result, feedbackScoreFromAPI(s))
+	}
+	return result
+}
+
+// feedbackScoresFromPublic converts a list of API feedback scores as
+// returned with traces and spans.
+func feedbackScoresFromPublic(scores []api.FeedbackScorePublic) []FeedbackScore {
+	if len(scores) == 0 {
+		return nil
+	}
+	result := make([]FeedbackScore, 0, len(scores))
+	for _, s := range scores {
+		result = append(result, feedbackScoreFromAPI(api.FeedbackScore{
+			Name:          s.Name,
+			CategoryName:  s.CategoryName,
+			Value:         s.Value,
+			Reason:        s.Reason,
+			Source:        api.FeedbackScoreSource(s.Source),
+			CreatedAt:     s.CreatedAt,
+			LastUpdatedAt: s.LastUpdatedAt,
+			CreatedBy:     s.CreatedBy,
+			LastUpdatedBy: s.LastUpdatedBy,
+		}))
+	}
+	return result
+}
+
+// FeedbackDefinitionType is the kind of values a feedback definition accepts.
+type FeedbackDefinitionType string
+
+// Feedback definition types.
+const (
+	FeedbackNumerical   FeedbackDefinitionType = "numerical"
+	FeedbackCategorical FeedbackDefinitionType = "categorical"
+	FeedbackBoolean     FeedbackDefinitionType = "boolean"
+)
+
+// FeedbackDefinition describes a feedback score: its name and the values it
+// accepts. Definitions are shared by the whole workspace.
+type FeedbackDefinition struct {
+	ID          string
+	Name        string
+	Description string
+	Type        FeedbackDefinitionType
+
+	// Min and Max bound the values of numerical scores.
+	Min float64
+	Max float64
+
+	// Categories maps category names to score values for categorical scores.
+	Categories map[string]float64
+
+	// TrueLabel and FalseLabel name the values 1 and 0 of boolean scores.
+	TrueLabel  string
+	FalseLabel string
+
+	CreatedAt     time.Time
+	CreatedBy     string
+	LastUpdatedAt time.Time
+	LastUpdatedBy string
+}
+
+// NewNumericalFeedbackDefinition creates a definition for scores between
+// minValue and maxValue inclusive.
+func NewNumericalFeedbackDefinition(name string, minValue, maxValue float64) *FeedbackDefinition {
+	return &FeedbackDefinition{Name: name, Type: FeedbackNumerical, Min: minValue, Max: maxValue}
+}
+
+// NewCategoricalFeedbackDefinition creates a definition for scores that take
+// the value of one of the named categories.
+func NewCategoricalFeedbackDefinition(name string, categories map[string]float64) *FeedbackDefinition {
+	return &FeedbackDefinition{Name: name, Type: FeedbackCategorical, Categories: categories}
+}
+
+// NewBooleanFeedbackDefinition creates a definition for scores of 1
+// (trueLabel) or 0 (falseLabel).
+func NewBooleanFeedbackDefinition(name, trueLabel, falseLabel string) *FeedbackDefinition {
+	return &FeedbackDefinition{Name: name, Type: FeedbackBoolean, TrueLabel: trueLabel, FalseLabel: falseLabel}
+}
+
+// FeedbackScoreError is returned when a feedback score does not match its
+// definition. It is returned before the score is sent.
+type FeedbackScoreError struct {
+	Name   string
+	Value  float64
+	Reason string
+}
+
+func (e *FeedbackScoreError) Error() string {
+	return fmt.Sprintf("opik: invalid feedback score %q = %v: %s", e.Name, e.Value, e.Reason)
+}
+
+// Unwrap returns ErrInvalidFeedbackScore.
+func (e *FeedbackScoreError) Unwrap() error {
+	return ErrInvalidFeedbackScore
+}
+
+// Check reports whether value is allowed by the definition. For categorical
+// definitions it also returns the name of the matching category.
+func (d *FeedbackDefinition) Check(value float64) (category string, err error) {
+	invalid := func(format string, args ...any) error {
+		return &FeedbackScoreError{Name: d.Name, Value: value, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	switch d.Type {
+	case FeedbackNumerical:
+		if value < d.Min || value > d.Max {
+			return "", invalid("must be between %v and %v", d.Min, d.Max)
+		}
+	case FeedbackCategorical:
+		names := make([]string, 0, len(d.Categories))
+		for name := range d.Categories {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if d.Categories[name] == value {
+				return name, nil
+			}
+		}
+		return "", invalid("must be the value of one of the categories %s", strings.Join(names, ", "))
+	case FeedbackBoolean:
+		if value != 0 && value != 1 {
+			return "", invalid("must be 0 or 1")
+		}
+	}
+	return "", nil
+}
+
+// equal reports whether two definitions describe the same score.
+func (d *FeedbackDefinition) equal(other *FeedbackDefinition) bool {
+	return d.Name == other.Name &&
+		d.Description == other.Description &&
+		d.Type == other.Type &&
+		d.Min == other.Min &&
+		d.Max == other.Max &&
+		maps.Equal(d.Categories, other.Categories) &&
+		d.TrueLabel == other.TrueLabel &&
+		d.FalseLabel == other.FalseLabel
+}
+
+// feedbackDefinitionJSON is the API representation of a feedback definition.
+// The generated client drops the type-specific details, so definitions are
+// sent and decoded by hand.
+type feedbackDefinitionJSON struct {
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Type        FeedbackDefinitionType `json:"type"`
+	Details     feedbackDetailsJSON    `json:"details"`
+
+	CreatedAt     time.Time `json:"created_at,omitzero"`
+	CreatedBy     string    `json:"created_by,omitempty"`
+	LastUpdatedAt time.Time `json:"last_updated_at,omitzero"`
+	LastUpdatedBy string    `json:"last_updated_by,omitempty"`
+}
+
+type feedbackDetailsJSON struct {
+	Min        *float64           `json:"min,omitempty"`
+	Max        *float64           `json:"max,omitempty"`
+	Categories map[string]float64 `json:"categories,omitempty"`
+	TrueLabel  string             `json:"trueLabel,omitempty"`
+	FalseLabel string             `json:"falseLabel,omitempty"`
+}
+
+// toJSON converts the definition to its API payload.
+func (d *FeedbackDefinition) toJSON() feedbackDefinitionJSON {
+	out := feedbackDefinitionJSON{
+		Name:        d.Name,
+		Description: d.Description,
+		Type:        d.Type,
+	}
+	switch d.Type {
+	case FeedbackNumerical:
+		out.Details.Min = &d.Min
+		out.Details.Max = &d.Max
+	case FeedbackCategorical:
+		out.Details.Categories = d.Categories
+	case FeedbackBoolean:
+		out.Details.TrueLabel = d.TrueLabel
+		out.Details.FalseLabel = d.FalseLabel
+	}
+	return out
+}
+
+// fromJSON converts a definition returned by the API.
+func (j *feedbackDefinitionJSON) fromJSON() *FeedbackDefinition {
+	d := &FeedbackDefinition{
+		ID:            j.ID,
+		Name:          j.Name,
+		Description:   j.Description,
+		Type:          j.Type,
+		Categories:    j.Details.Categories,
+		TrueLabel:     j.Details.TrueLabel,
+		FalseLabel:    j.Details.FalseLabel,
+		CreatedAt:     j.CreatedAt,
+		CreatedBy:     j.CreatedBy,
+		LastUpdatedAt: j.LastUpdatedAt,
+		LastUpdatedBy: j.LastUpdatedBy,
+	}
+	if j.Details.Min != nil {
+		d.Min = *j.Details.Min
+	}
+	if j.Details.Max != nil {
+		d.Max = *j.Details.Max
+	}
+	return d
+}
+
+// CreateFeedbackDefinition creates a feedback definition in the workspace
+// and sets its ID.
+func (c *Client) CreateFeedbackDefinition(ctx context.Context, def *FeedbackDefinition) error {
+	header, err := c.doJSON(ctx, http.MethodPost, "/v1/private/feedback-definitions", def.toJSON(), nil)
+	if err != nil {
+		return err
+	}
+	if loc := header.Get("Location"); loc != "" {
+		def.ID = path.Base(loc)
+	}
+	return nil
+}
+
+// GetFeedbackDefinition retrieves a feedback definition by ID.
+func (c *Client) GetFeedbackDefinition(ctx context.Context, id string) (*FeedbackDefinition, error) {
+	defUUID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp feedbackDefinitionJSON
+	if _, err := c.doJSON(ctx, http.MethodGet, "/v1/private/feedback-definitions/"+defUUID.String(), nil, &resp); err != nil {
+		if IsNotFound(err) {
+			return nil, ErrFeedbackDefinitionNotFound
+		}
+		return nil, err
+	}
+	return resp.fromJSON(), nil
+}
+
+// GetFeedbackDefinitionByName retrieves a feedback definition by its exact name.
+func (c *Client) GetFeedbackDefinitionByName(ctx context.Context, name string) (*FeedbackDefinition, error) {
+	for def, err := range c.findFeedbackDefinitions(ctx, name) {
+		if err != nil {
+			return nil, err
+		}
+		if def.Name == name {
+			return def, nil
+		}
+	}
+	return nil, ErrFeedbackDefinitionNotFound
+}
+
+// ListFeedbackDefinitions lists the feedback definitions of the workspace.
+func (c *Client) ListFeedbackDefinitions(ctx context.Context, page, size int) ([]*FeedbackDefinition, error) {
+	return c.listFeedbackDefinitions(ctx, "", page, size)
+}
+
+// AllFeedbackDefinitions iterates over all feedback definitions of the
+// workspace, fetching them page by page.
+func (c *Client) AllFeedbackDefinitions(ctx context.Context, opts ...PageOption) iter.Seq2[*FeedbackDefinition, error] {
+	return paginate(ctx, c.ListFeedbackDefinitions, opts)
+}
+
+// findFeedbackDefinitions iterates over the definitions whose name contains name.
+func (c *Client) findFeedbackDefinitions(ctx context.Context, name string) iter.Seq2[*FeedbackDefinition, error] {
+	return paginate(ctx, func(ctx context.Context, page, size int) ([]*FeedbackDefinition, error) {
+		return c.listFeedbackDefinitions(ctx, name, page, size)
+	}, nil)
+}
+
+func (c *Client) listFeedbackDefinitions(ctx context.Context, name string, page, size int) ([]*FeedbackDefinition, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("size", strconv.Itoa(size))
+	if name != "" {
+		query.Set("name", name)
+	}
+
+	var resp struct {
+		Content []feedbackDefinitionJSON `json:"content"`
+	}
+	if _, err := c.doJSON(ctx, http.MethodGet, "/v1/private/feedback-definitions?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+
+	defs := make([]*FeedbackDefinition, 0, len(resp.Content))
+	for i := range resp.Content {
+		defs = append(defs, resp.Content[i].fromJSON())
+	}
+	return defs, nil
+}
+
+// UpdateFeedbackDefinition replaces the feedback definition with def.ID.
+func (c *Client) UpdateFeedbackDefinition(ctx context.Context, def *FeedbackDefinition) error {
+	defUUID, err := uuid.Parse(def.ID)
+	if err != nil {
+		return fmt.Errorf("%w: invalid feedback definition ID %q", ErrInvalidInput, def.ID)
+	}
+	_, err = c.doJSON(ctx, http.MethodPut, "/v1/private/feedback-definitions/"+defUUID.String(), def.toJSON(), nil)
+	return err
+}
+
+// DeleteFeedbackDefinitions deletes feedback definitions by ID.
+func (c *Client) DeleteFeedbackDefinitions(ctx context.Context, ids ...string) error {
+	req, err := newBatchDelete(ids)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.apiClient.DeleteFeedbackDefinitionsBatch(ctx, api.NewOptBatchDelete(req))
+	if err != nil {
+		return err
+	}
+	if msg, ok := resp.(*api.ErrorMessage); ok {
+		return newAPIError(409, msg)
+	}
+	return nil
+}
+
+// SyncFeedbackDefinitions makes the workspace match defs: definitions that
+// do not exist are created and ones that differ are updated. Definitions
+// not in defs are left alone. The IDs of defs are set, and AddFeedbackScore
+// calls are checked against them from then on.
+func (c *Client) SyncFeedbackDefinitions(ctx context.Context, defs ...*FeedbackDefinition) error {
+	existing := make(map[string]*FeedbackDefinition)
+	for def, err := range c.AllFeedbackDefinitions(ctx) {
+		if err != nil {
+			return err
+		}
+		existing[def.Name] = def
+	}
+
+	for _, def := range defs {
+		current, ok := existing[def.Name]
+		switch {
+		case !ok:
+			if err := c.CreateFeedbackDefinition(ctx, def); err != nil {
+				return fmt.Errorf("create feedback definition %q: %w", def.Name, err)
+			}
+		case !current.equal(def):
+			def.ID = current.ID
+			if err := c.UpdateFeedbackDefinition(ctx, def); err != nil {
+				return fmt.Errorf("update feedback definition %q: %w", def.Name, err)
+			}
+		default:
+			def.ID = current.ID
+		}
+	}
+
+	c.feedbackRegistry().set(defs...)
+	return nil
+}
+
+// LoadFeedbackDefinitions fetches the feedback definitions of the workspace
+// and checks AddFeedbackScore calls against them from then on.
+func (c *Client) LoadFeedbackDefinitions(ctx context.Context) error {
+	var defs []*FeedbackDefinition
+	for def, err := range c.AllFeedbackDefinitions(ctx) {
+		if err != nil {
+			return err
+		}
+		defs = append(defs, def)
+	}
+	c.feedbackRegistry().set(defs...)
+	return nil
+}
+
+// feedbackDefinitions holds the definitions feedback scores are checked
+// against.
+type feedbackDefinitions struct {
+	mu   sync.RWMutex
+	defs map[string]*FeedbackDefinition
+}
+
+func newFeedbackDefinitions(defs ...*FeedbackDefinition) *feedbackDefinitions {
+	r := &feedbackDefinitions{defs: make(map[string]*FeedbackDefinition)}
+	r.set(defs...)
+	return r
+}
+
+// set adds or replaces definitions by name.
+func (r *feedbackDefinitions) set(defs ...*FeedbackDefinition) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, def := range defs {
+		r.defs[def.Name] = def
+	}
+}
+
+// check checks a score against its definition. Scores without a definition
+// are allowed.
+func (r *feedbackDefinitions) check(name string, value float64) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	r.mu.RLock()
+	def, ok := r.defs[name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", nil
+	}
+	return def.Check(value)
+}
+
+// feedbackRegistry returns the client's feedback definitions, enabling
+// score checks if they were not enabled.
+func (c *Client) feedbackRegistry() *feedbackDefinitions {
+	c.feedbackMu.Lock()
+	defer c.feedbackMu.Unlock()
+	if c.feedbackDefs == nil {
+		c.feedbackDefs = newFeedbackDefinitions()
+	}
+	return c.feedbackDefs
+}
+
+// checkFeedbackScore checks a score against the client's feedback
+// definitions and returns the category name for categorical scores.
+func (c *Client) checkFeedbackScore(name string, value float64) (string, error) {
+	c.feedbackMu.Lock()
+	defs := c.feedbackDefs
+	c.feedbackMu.Unlock()
+	return defs.check(name, value)
+}
+
+// DeleteTraceFeedbackScores deletes the named feedback scores from a trace.
+func (c *Client) DeleteTraceFeedbackScores(ctx context.Context, traceID string, names ...string) error {
+	traceUUID, err := uuid.Parse(traceID)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err := c.apiClient.DeleteTraceFeedbackScore(ctx, api.NewOptDeleteFeedbackScore(api.DeleteFeedbackScore{
+			Name: name,
+		}), api.DeleteTraceFeedbackScoreParams{ID: traceUUID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSpanFeedbackScores deletes the named feedback scores from a span.
+func (c *Client) DeleteSpanFeedbackScores(ctx context.Context, spanID string, names ...string) error {
+	spanUUID, err := uuid.Parse(spanID)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		err := c.apiClient.DeleteSpanFeedbackScore(ctx, api.NewOptDeleteFeedbackScore(api.DeleteFeedbackScore{
+			Name: name,
+		}), api.DeleteSpanFeedbackScoreParams{ID: spanUUID})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteThreadFeedbackScores deletes the named feedback scores from a thread.
+func (c *Client) DeleteThreadFeedbackScores(ctx context.Context, threadID string, names []string, opts ...ThreadOption) error {
+	return c.apiClient.DeleteThreadFeedbackScores(ctx, api.NewOptDeleteThreadFeedbackScores(api.DeleteThreadFeedbackScores{
+		ProjectName: c.threadProjectName(opts),
+		ThreadID:    threadID,
+		Names:       names,
+	}))
+}
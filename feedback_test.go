@@ -0,0 +1,203 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+const testDefinitionID = "01890a5d-ac96-774b-bcce-b302099a8301"
+
+func TestFeedbackDefinitionCheck(t *testing.T) {
+	numerical := NewNumericalFeedbackDefinition("hallucination", 0, 1)
+	categorical := NewCategoricalFeedbackDefinition("tone", map[string]float64{"rude": 0, "neutral": 0.5, "friendly": 1})
+	boolean := NewBooleanFeedbackDefinition("resolved", "yes", "no")
+
+	tests := []struct {
+		def          *FeedbackDefinition
+		value        float64
+		wantCategory string
+		wantErr      bool
+	}{
+		{numerical, 0, "", false},
+		{numerical, 1, "", false},
+		{numerical, 1.5, "", true},
+		{numerical, -0.1, "", true},
+		{categorical, 0.5, "neutral", false},
+		{categorical, 0.7, "", true},
+		{boolean, 1, "", false},
+		{boolean, 0.5, "", true},
+	}
+	for _, tt := range tests {
+		category, err := tt.def.Check(tt.value)
+		if (err != nil) != tt.wantErr || category != tt.wantCategory {
+			t.Errorf("%s.Check(%v) = %q, %v", tt.def.Name, tt.value, category, err)
+		}
+		var scoreErr *FeedbackScoreError
+		if tt.wantErr && (!errors.As(err, &scoreErr) || !errors.Is(err, ErrInvalidFeedbackScore)) {
+			t.Errorf("%s.Check(%v) error = %v, want a FeedbackScoreError", tt.def.Name, tt.value, err)
+		}
+	}
+}
+
+func TestAddFeedbackScoreChecked(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPut("/v1/private/traces/"+testTraceID+"/feedback-scores").Respond(http.StatusNoContent, nil)
+
+	client, err := NewClient(
+		WithURL(ms.URL()),
+		WithFeedbackDefinitions(NewCategoricalFeedbackDefinition("tone", map[string]float64{"rude": 0, "friendly": 1})),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	trace := &Trace{client: client, id: testTraceID}
+	ctx := context.Background()
+
+	if err := trace.AddFeedbackScore(ctx, "tone", 0.3, ""); !errors.Is(err, ErrInvalidFeedbackScore) {
+		t.Fatalf("AddFeedbackScore error = %v, want ErrInvalidFeedbackScore", err)
+	}
+	if ms.RequestCount() != 0 {
+		t.Fatalf("invalid score was sent")
+	}
+
+	if err := trace.AddFeedbackScore(ctx, "tone", 1, ""); err != nil {
+		t.Fatalf("AddFeedbackScore error: %v", err)
+	}
+	var sent struct {
+		CategoryName string `json:"category_name"`
+	}
+	if err := json.Unmarshal(ms.LastRequest().Body, &sent); err != nil {
+		t.Fatalf("unmarshal score: %v", err)
+	}
+	if sent.CategoryName != "friendly" {
+		t.Errorf("category_name = %q, want friendly", sent.CategoryName)
+	}
+
+	// Scores without a definition are not checked
+	if err := trace.AddFeedbackScore(ctx, "other", 42, ""); err != nil {
+		t.Errorf("AddFeedbackScore error: %v", err)
+	}
+}
+
+func TestSyncFeedbackDefinitions(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnGet("/v1/private/feedback-definitions").RespondJSON(http.StatusOK, map[string]any{
+		"content": []map[string]any{{
+			"id":      testDefinitionID,
+			"name":    "hallucination",
+			"type":    "numerical",
+			"details": map[string]any{"min": 0, "max": 5},
+		}},
+	})
+	ms.OnPut("/v1/private/feedback-definitions/"+testDefinitionID).Respond(http.StatusNoContent, nil)
+	ms.OnPost("/v1/private/feedback-definitions").
+		WithHeaders(map[string]string{"Location": "/v1/private/feedback-definitions/01890a5d-ac96-774b-bcce-b302099a8302"}).
+		Respond(http.StatusCreated, nil)
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	hallucination := NewNumericalFeedbackDefinition("hallucination", 0, 1)
+	tone := NewCategoricalFeedbackDefinition("tone", map[string]float64{"rude": 0, "friendly": 1})
+	if err := client.SyncFeedbackDefinitions(context.Background(), hallucination, tone); err != nil {
+		t.Fatalf("SyncFeedbackDefinitions error: %v", err)
+	}
+
+	if hallucination.ID != testDefinitionID || tone.ID != "01890a5d-ac96-774b-bcce-b302099a8302" {
+		t.Errorf("IDs = %q, %q", hallucination.ID, tone.ID)
+	}
+
+	var updated feedbackDefinitionJSON
+	if err := json.Unmarshal(ms.RequestsForPath("/v1/private/feedback-definitions/" + testDefinitionID)[0].Body, &updated); err != nil {
+		t.Fatalf("unmarshal update: %v", err)
+	}
+	if updated.Details.Max == nil || *updated.Details.Max != 1 {
+		t.Errorf("updated details = %+v", updated.Details)
+	}
+
+	var created feedbackDefinitionJSON
+	for _, req := range ms.RequestsForPath("/v1/private/feedback-definitions") {
+		if req.Method == http.MethodPost {
+			if err := json.Unmarshal(req.Body, &created); err != nil {
+				t.Fatalf("unmarshal create: %v", err)
+			}
+		}
+	}
+	if created.Name != "tone" || created.Type != FeedbackCategorical || created.Details.Categories["friendly"] != 1 {
+		t.Errorf("created = %+v", created)
+	}
+
+	// Synced definitions are used to check scores
+	if _, err := client.checkFeedbackScore("hallucination", 3); !errors.Is(err, ErrInvalidFeedbackScore) {
+		t.Errorf("checkFeedbackScore error = %v, want ErrInvalidFeedbackScore", err)
+	}
+}
+
+func TestGetFeedbackDefinitionByName(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnGet("/v1/private/feedback-definitions").RespondJSON(http.StatusOK, map[string]any{
+		"content": []map[string]any{
+			{"id": testDefinitionID, "name": "tone-v2", "type": "boolean", "details": map[string]any{"trueLabel": "ok", "falseLabel": "bad"}},
+			{"id": testDefinitionID, "name": "tone", "type": "categorical", "details": map[string]any{"categories": map[string]any{"rude": 0}}},
+		},
+	})
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	ctx := context.Background()
+
+	def, err := client.GetFeedbackDefinitionByName(ctx, "tone")
+	if err != nil {
+		t.Fatalf("GetFeedbackDefinitionByName error: %v", err)
+	}
+	if def.Type != FeedbackCategorical || len(def.Categories) != 1 {
+		t.Errorf("unexpected definition %+v", def)
+	}
+
+	if _, err := client.GetFeedbackDefinitionByName(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("GetFeedbackDefinitionByName error = %v, want not found", err)
+	}
+}
+
+func TestDeleteFeedbackScores(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/"+testTraceID+"/feedback-scores/delete").Respond(http.StatusNoContent, nil)
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	trace := &Trace{client: client, id: testTraceID}
+
+	if err := trace.DeleteFeedbackScores(context.Background(), "accuracy", "relevance"); err != nil {
+		t.Fatalf("DeleteFeedbackScores error: %v", err)
+	}
+
+	requests := ms.RequestsForPath("/v1/private/traces/" + testTraceID + "/feedback-scores/delete")
+	if len(requests) != 2 {
+		t.Fatalf("got %d delete requests, want 2", len(requests))
+	}
+	var body struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(requests[1].Body, &body); err != nil || body.Name != "relevance" {
+		t.Errorf("delete body = %s", requests[1].Body)
+	}
+}
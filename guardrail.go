@@ -0,0 +1,83 @@
+package opik
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// Guardrail names shown in Opik's guardrail views.
+const (
+	GuardrailTopic = "TOPIC"
+	GuardrailPII   = "PII"
+)
+
+// GuardrailResult is the result of a guardrail check, reported with
+// LogGuardrails.
+type GuardrailResult struct {
+	// TraceID is the trace the check ran in.
+	TraceID string
+	// SpanID is the guardrail span recording the check.
+	SpanID string
+	// Name is GuardrailTopic or GuardrailPII.
+	Name string
+	// Passed reports whether the checked text passed.
+	Passed bool
+	// Config describes how the check was configured.
+	Config map[string]any
+	// Details describes the outcome, such as the score and reason.
+	Details map[string]any
+}
+
+// LogGuardrails reports guardrail results, so that they show up in Opik's
+// guardrail views. The results are logged to the client's project.
+func (c *Client) LogGuardrails(ctx context.Context, results ...GuardrailResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	guardrails := make([]api.GuardrailWrite, 0, len(results))
+	for _, r := range results {
+		traceUUID, err := uuid.Parse(r.TraceID)
+		if err != nil {
+			return fmt.Errorf("invalid trace ID %q: %w", r.TraceID, err)
+		}
+		spanUUID, err := uuid.Parse(r.SpanID)
+		if err != nil {
+			return fmt.Errorf("invalid span ID %q: %w", r.SpanID, err)
+		}
+		var name api.GuardrailWriteName
+		if err := name.UnmarshalText([]byte(r.Name)); err != nil {
+			return fmt.Errorf("opik: unknown guardrail name %q", r.Name)
+		}
+
+		result := api.GuardrailWriteResultFailed
+		if r.Passed {
+			result = api.GuardrailWriteResultPassed
+		}
+		guardrails = append(guardrails, api.GuardrailWrite{
+			EntityID:    traceUUID,
+			SecondaryID: spanUUID,
+			ProjectName: api.NewOptString(c.projectName),
+			Name:        name,
+			Result:      result,
+			Config:      nonNilJsonNode(r.Config),
+			Details:     nonNilJsonNode(c.redactMetadata(r.Details)),
+		})
+	}
+
+	return c.apiClient.CreateGuardrails(ctx, api.NewOptGuardrailBatchWrite(api.GuardrailBatchWrite{
+		Guardrails: guardrails,
+	}))
+}
+
+// nonNilJsonNode converts m to a JSON object, which is empty if m is nil.
+func nonNilJsonNode(m map[string]any) api.JsonNode {
+	if m == nil {
+		return api.JsonNode{}
+	}
+	return mapToJsonNode(m)
+}
@@ -0,0 +1,66 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+func TestLogGuardrails(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms, WithProjectName("chat-project"))
+	ms.OnPost("/v1/private/guardrails").Respond(http.StatusNoContent, nil)
+
+	err := client.LogGuardrails(context.Background(), GuardrailResult{
+		TraceID: testTraceID,
+		SpanID:  testTraceID,
+		Name:    GuardrailPII,
+		Passed:  false,
+		Config:  map[string]any{"metric": "regex_not_match"},
+		Details: map[string]any{"score": 0.0},
+	})
+	if err != nil {
+		t.Fatalf("LogGuardrails error: %v", err)
+	}
+
+	req := ms.LastRequest()
+	var body struct {
+		Guardrails []struct {
+			EntityID    string         `json:"entity_id"`
+			ProjectName string         `json:"project_name"`
+			Name        string         `json:"name"`
+			Result      string         `json:"result"`
+			Config      map[string]any `json:"config"`
+		} `json:"guardrails"`
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		t.Fatalf("unmarshal guardrails: %v", err)
+	}
+	if len(body.Guardrails) != 1 {
+		t.Fatalf("sent %d guardrails, want 1", len(body.Guardrails))
+	}
+	g := body.Guardrails[0]
+	if g.EntityID != testTraceID || g.ProjectName != "chat-project" || g.Name != "PII" || g.Result != "failed" {
+		t.Errorf("guardrail = %+v", g)
+	}
+	if g.Config["metric"] != "regex_not_match" {
+		t.Errorf("config = %v", g.Config)
+	}
+}
+
+func TestLogGuardrailsUnknownName(t *testing.T) {
+	client := &Client{}
+	err := client.LogGuardrails(context.Background(), GuardrailResult{
+		TraceID: testTraceID,
+		SpanID:  testTraceID,
+		Name:    "TOXICITY",
+	})
+	if err == nil {
+		t.Error("LogGuardrails accepted an unknown guardrail name")
+	}
+}
@@ -0,0 +1,47 @@
+// Package guardrails runs evaluation metrics as runtime checks on the inputs
+// and outputs of LLM calls.
+//
+// A Check runs an evaluation.Metric on the prompt or the completion, and
+// flags, blocks or redacts texts that fail it. A Guard runs a list of checks
+// and records each one as a guardrail span in the current trace, reported to
+// Opik's guardrail views.
+//
+// # Usage
+//
+//	import (
+//	    opik "github.com/agentplexus/go-opik"
+//	    "github.com/agentplexus/go-opik/evaluation/heuristic"
+//	    "github.com/agentplexus/go-opik/guardrails"
+//	)
+//
+//	ssn := regexp.MustCompile(`\d{3}-\d{2}-\d{4}`)
+//
+//	guard := guardrails.New(client,
+//	    // Redact social security numbers from completions
+//	    guardrails.NewCheck(heuristic.MustRegexNotMatch(ssn.String()),
+//	        guardrails.WithAction(guardrails.ActionRedact),
+//	        guardrails.WithRedactor(func(s string) string {
+//	            return ssn.ReplaceAllString(s, "[SSN]")
+//	        }),
+//	        guardrails.WithGuardrailName(opik.GuardrailPII),
+//	    ),
+//	    // Block prompts that an LLM judge finds harmful
+//	    guardrails.NewCheck(llm.NewModeration(judge),
+//	        guardrails.WithStage(guardrails.StageInput),
+//	        guardrails.WithAction(guardrails.ActionBlock),
+//	        guardrails.WithMaxScore(0.3),
+//	    ),
+//	)
+//
+//	result, err := guard.CheckOutput(ctx, prompt, completion)
+//	if errors.Is(err, guardrails.ErrBlocked) {
+//	    // ...
+//	}
+//	completion = result.Text
+//
+// # Wrapping Providers
+//
+// WrapProvider guards an evaluation llm.Provider, and the omnillm
+// integration's NewGuardedClient guards a TracingClient, so that every call
+// is checked.
+package guardrails
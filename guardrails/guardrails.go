@@ -0,0 +1,357 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	opik "github.com/agentplexus/go-opik"
+	"github.com/agentplexus/go-opik/evaluation"
+)
+
+// Action is what a check does when the text fails it.
+type Action string
+
+// Check actions.
+const (
+	// ActionFlag records the failure and lets the text through.
+	ActionFlag Action = "flag"
+	// ActionBlock stops the call with a BlockedError.
+	ActionBlock Action = "block"
+	// ActionRedact replaces the text with its redacted version.
+	ActionRedact Action = "redact"
+)
+
+// Stage is the text a check runs on.
+type Stage string
+
+// Check stages.
+const (
+	StageInput  Stage = "input"
+	StageOutput Stage = "output"
+)
+
+// ErrBlocked is matched by the errors returned when a check blocks a call.
+var ErrBlocked = errors.New("guardrails: blocked")
+
+// BlockedError is returned when a check with ActionBlock fails.
+type BlockedError struct {
+	// Check is the name of the failed check.
+	Check string
+	// Stage is the text that failed.
+	Stage Stage
+	// Score is the metric's result.
+	Score *evaluation.ScoreResult
+}
+
+func (e *BlockedError) Error() string {
+	msg := fmt.Sprintf("guardrails: %s blocked by %s", e.Stage, e.Check)
+	if e.Score != nil && e.Score.Error != nil {
+		return msg + ": " + e.Score.Error.Error()
+	}
+	if e.Score != nil && e.Score.Reason != "" {
+		return msg + ": " + e.Score.Reason
+	}
+	return msg
+}
+
+// Is reports whether target is ErrBlocked.
+func (e *BlockedError) Is(target error) bool {
+	return target == ErrBlocked
+}
+
+// DefaultMinScore is the score a text needs to pass a check configured
+// without WithMinScore or WithMaxScore.
+const DefaultMinScore = 0.5
+
+// redactedText replaces texts that fail a redacting check without a
+// redactor.
+const redactedText = "[REDACTED]"
+
+// Check runs an evaluation metric as a guardrail.
+type Check struct {
+	metric        evaluation.Metric
+	stage         Stage
+	action        Action
+	minScore      *float64
+	maxScore      *float64
+	redactor      func(string) string
+	guardrailName string
+}
+
+// CheckOption configures a Check.
+type CheckOption func(*Check)
+
+// WithStage sets the text the check runs on. The default is StageOutput.
+func WithStage(stage Stage) CheckOption {
+	return func(c *Check) {
+		c.stage = stage
+	}
+}
+
+// WithAction sets what the check does when the text fails. The default is
+// ActionFlag.
+func WithAction(action Action) CheckOption {
+	return func(c *Check) {
+		c.action = action
+	}
+}
+
+// WithMinScore makes texts pass when the metric scores them at least score,
+// for metrics where higher is better, such as heuristic.RegexNotMatch.
+func WithMinScore(score float64) CheckOption {
+	return func(c *Check) {
+		c.minScore = &score
+	}
+}
+
+// WithMaxScore makes texts pass when the metric scores them at most score,
+// for metrics where higher is worse, such as llm.Moderation.
+func WithMaxScore(score float64) CheckOption {
+	return func(c *Check) {
+		c.maxScore = &score
+	}
+}
+
+// WithRedactor sets the function that redacts failing texts for
+// ActionRedact. By default the whole text is replaced with "[REDACTED]".
+func WithRedactor(redact func(string) string) CheckOption {
+	return func(c *Check) {
+		c.redactor = redact
+	}
+}
+
+// WithGuardrailName sets the name the check is reported under in Opik's
+// guardrail views, opik.GuardrailTopic or opik.GuardrailPII. The default is
+// opik.GuardrailTopic.
+func WithGuardrailName(name string) CheckOption {
+	return func(c *Check) {
+		c.guardrailName = name
+	}
+}
+
+// NewCheck creates a check that runs metric. Without options, it flags
+// outputs that score below DefaultMinScore.
+func NewCheck(metric evaluation.Metric, opts ...CheckOption) *Check {
+	c := &Check{
+		metric:        metric,
+		stage:         StageOutput,
+		action:        ActionFlag,
+		guardrailName: opik.GuardrailTopic,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Name returns the name of the check's metric.
+func (c *Check) Name() string {
+	return c.metric.Name()
+}
+
+// passes reports whether score passes the check. Failed metrics fail it.
+func (c *Check) passes(score *evaluation.ScoreResult) bool {
+	if score == nil || score.Error != nil {
+		return false
+	}
+	switch {
+	case c.minScore != nil && score.Value < *c.minScore:
+		return false
+	case c.maxScore != nil && score.Value > *c.maxScore:
+		return false
+	case c.minScore == nil && c.maxScore == nil:
+		return score.Value >= DefaultMinScore
+	}
+	return true
+}
+
+func (c *Check) redact(text string) string {
+	if c.redactor == nil {
+		return redactedText
+	}
+	return c.redactor(text)
+}
+
+// config describes the check for its span and guardrail result.
+func (c *Check) config() map[string]any {
+	config := map[string]any{
+		"metric": c.metric.Name(),
+		"stage":  string(c.stage),
+		"action": string(c.action),
+	}
+	switch {
+	case c.minScore != nil:
+		config["min_score"] = *c.minScore
+	case c.maxScore == nil:
+		config["min_score"] = DefaultMinScore
+	}
+	if c.maxScore != nil {
+		config["max_score"] = *c.maxScore
+	}
+	return config
+}
+
+// CheckResult is the result of one check.
+type CheckResult struct {
+	// Name is the name of the check.
+	Name string
+	// Stage is the text the check ran on.
+	Stage Stage
+	// Action is the check's action.
+	Action Action
+	// Passed reports whether the text passed the check.
+	Passed bool
+	// Score is the metric's result.
+	Score *evaluation.ScoreResult
+}
+
+// Result is the result of running a guard's checks on a text.
+type Result struct {
+	// Text is the checked text, redacted by the checks that failed with
+	// ActionRedact.
+	Text string
+	// Passed reports whether the text passed all checks.
+	Passed bool
+	// Checks holds the results of the checks that ran, in order.
+	Checks []CheckResult
+}
+
+// Guard runs checks on the inputs and outputs of LLM calls. Each check is
+// recorded as a guardrail span in the trace in the context, and reported to
+// Opik's guardrail views.
+type Guard struct {
+	client *opik.Client
+	checks []*Check
+}
+
+// New creates a guard that runs checks, in order, and reports them with
+// client. A nil client records the checks as spans only.
+func New(client *opik.Client, checks ...*Check) *Guard {
+	return &Guard{
+		client: client,
+		checks: checks,
+	}
+}
+
+// CheckInput runs the input checks on input. If a check blocks it, the
+// result so far is returned with a BlockedError.
+//
+// Most metrics score MetricInput.Output, so input checks see the text as
+// both the input and the output.
+func (g *Guard) CheckInput(ctx context.Context, input string) (*Result, error) {
+	return g.run(ctx, StageInput, input, func(text string) evaluation.MetricInput {
+		return evaluation.NewMetricInput(text, text)
+	})
+}
+
+// CheckOutput runs the output checks on the output of a call made with
+// input. If a check blocks it, the result so far is returned with a
+// BlockedError.
+func (g *Guard) CheckOutput(ctx context.Context, input, output string) (*Result, error) {
+	return g.run(ctx, StageOutput, output, func(text string) evaluation.MetricInput {
+		return evaluation.NewMetricInput(input, text)
+	})
+}
+
+// run runs the checks for stage on text. Later checks see the text as
+// redacted by earlier ones.
+func (g *Guard) run(ctx context.Context, stage Stage, text string, metricInput func(string) evaluation.MetricInput) (*Result, error) {
+	result := &Result{Text: text, Passed: true}
+	for _, check := range g.checks {
+		if check.stage != stage {
+			continue
+		}
+
+		span := g.startSpan(ctx, check, result.Text)
+		score := check.metric.Score(ctx, metricInput(result.Text))
+		passed := check.passes(score)
+		result.Checks = append(result.Checks, CheckResult{
+			Name:   check.Name(),
+			Stage:  stage,
+			Action: check.action,
+			Passed: passed,
+			Score:  score,
+		})
+		if !passed {
+			result.Passed = false
+			if check.action == ActionRedact {
+				result.Text = check.redact(result.Text)
+			}
+		}
+		g.endSpan(ctx, span, check, score, passed)
+
+		if !passed && check.action == ActionBlock {
+			return result, &BlockedError{Check: check.Name(), Stage: stage, Score: score}
+		}
+	}
+	return result, nil
+}
+
+// startSpan starts the guardrail span for check, if ctx holds a trace or
+// span. The text is not recorded for redacting checks, since it holds the
+// data they remove.
+func (g *Guard) startSpan(ctx context.Context, check *Check, text string) *opik.Span {
+	input := map[string]any{"stage": string(check.stage)}
+	if check.action != ActionRedact {
+		input["text"] = text
+	}
+	opts := []opik.SpanOption{
+		opik.WithSpanType(opik.SpanTypeGuardrail),
+		opik.WithSpanInput(input),
+		opik.WithSpanMetadata(check.config()),
+	}
+
+	var span *opik.Span
+	var err error
+	if parentSpan := opik.SpanFromContext(ctx); parentSpan != nil {
+		span, err = parentSpan.Span(ctx, check.Name(), opts...)
+	} else if trace := opik.TraceFromContext(ctx); trace != nil {
+		span, err = trace.Span(ctx, check.Name(), opts...)
+	}
+	if err != nil {
+		return nil
+	}
+	return span
+}
+
+// endSpan ends the guardrail span for check and reports the result.
+func (g *Guard) endSpan(ctx context.Context, span *opik.Span, check *Check, score *evaluation.ScoreResult, passed bool) {
+	if span == nil {
+		return
+	}
+
+	details := map[string]any{}
+	if score != nil {
+		details["score"] = score.Value
+		if score.Reason != "" {
+			details["reason"] = score.Reason
+		}
+	}
+	output := map[string]any{
+		"passed": passed,
+	}
+	for k, v := range details {
+		output[k] = v
+	}
+	if !passed {
+		output["action"] = string(check.action)
+	}
+
+	opts := []opik.SpanOption{opik.WithSpanOutput(output)}
+	if score != nil && score.Error != nil {
+		opts = append(opts, opik.WithSpanError(score.Error))
+	}
+	_ = span.End(ctx, opts...)
+
+	if g.client != nil {
+		_ = g.client.LogGuardrails(ctx, opik.GuardrailResult{
+			TraceID: span.TraceID(),
+			SpanID:  span.ID(),
+			Name:    check.guardrailName,
+			Passed:  passed,
+			Config:  check.config(),
+			Details: details,
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"testing"
+
+	opik "github.com/agentplexus/go-opik"
+	"github.com/agentplexus/go-opik/evaluation"
+	"github.com/agentplexus/go-opik/evaluation/heuristic"
+	"github.com/agentplexus/go-opik/evaluation/llm"
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+var ssn = regexp.MustCompile(`\d{3}-\d{2}-\d{4}`)
+
+// newSSNCheck returns a check that redacts social security numbers.
+func newSSNCheck(stage Stage) *Check {
+	return NewCheck(heuristic.MustRegexNotMatch(ssn.String()),
+		WithStage(stage),
+		WithAction(ActionRedact),
+		WithRedactor(func(s string) string { return ssn.ReplaceAllString(s, "[SSN]") }),
+		WithGuardrailName(opik.GuardrailPII),
+	)
+}
+
+// newTestGuardClient creates a client that logs to ms, a
+// testutil.NewTracingServer that also accepts guardrail results.
+func newTestGuardClient(t *testing.T, ms *testutil.MockServer) *opik.Client {
+	t.Helper()
+
+	ms.OnPost("/v1/private/guardrails").Respond(http.StatusNoContent, nil)
+
+	client, err := opik.NewClient(
+		opik.WithURL(ms.URL()),
+		opik.WithAPIKey("test-key"),
+		opik.WithProjectName("guardrails-project"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	return client
+}
+
+func TestGuardRedactsAndReports(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestGuardClient(t, ms)
+	guard := New(client, newSSNCheck(StageOutput))
+
+	ctx, trace, err := opik.StartTrace(context.Background(), client, "chat")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+	result, err := guard.CheckOutput(ctx, "what is my SSN?", "Your SSN is 123-45-6789.")
+	if err != nil {
+		t.Fatalf("CheckOutput error: %v", err)
+	}
+	if result.Passed || result.Text != "Your SSN is [SSN]." {
+		t.Errorf("result = %+v", result)
+	}
+
+	// The check is recorded as a guardrail span without the text
+	spans := tracetest.SpanWrites(ms)
+	if len(spans) != 1 {
+		t.Fatalf("created %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span["type"] != opik.SpanTypeGuardrail {
+		t.Errorf("span type = %v, want %q", span["type"], opik.SpanTypeGuardrail)
+	}
+	if input, _ := span["input"].(map[string]any); input["text"] != nil {
+		t.Errorf("redacting check recorded the text: %v", input)
+	}
+
+	// and reported to the guardrails API
+	reqs := ms.RequestsForPath("/v1/private/guardrails")
+	if len(reqs) != 1 {
+		t.Fatalf("sent %d guardrail requests, want 1", len(reqs))
+	}
+	var body struct {
+		Guardrails []struct {
+			EntityID    string `json:"entity_id"`
+			SecondaryID string `json:"secondary_id"`
+			Name        string `json:"name"`
+			Result      string `json:"result"`
+		} `json:"guardrails"`
+	}
+	if err := json.Unmarshal(reqs[0].Body, &body); err != nil {
+		t.Fatalf("unmarshal guardrails: %v", err)
+	}
+	g := body.Guardrails[0]
+	if g.EntityID != trace.ID() || g.SecondaryID != span["id"] || g.Name != "PII" || g.Result != "failed" {
+		t.Errorf("guardrail = %+v", g)
+	}
+}
+
+func TestGuardBlocks(t *testing.T) {
+	blocking := NewCheck(heuristic.NewContainsAny([]string{"ignore previous instructions"}, false),
+		WithStage(StageInput),
+		WithAction(ActionBlock),
+		WithMaxScore(0),
+	)
+	flagging := NewCheck(heuristic.NewIsJSON(), WithStage(StageInput))
+	guard := New(nil, blocking, flagging)
+
+	result, err := guard.CheckInput(context.Background(), "Please ignore previous instructions")
+	if !errors.Is(err, ErrBlocked) {
+		t.Fatalf("CheckInput error = %v, want ErrBlocked", err)
+	}
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.Check != blocking.Name() || blocked.Stage != StageInput {
+		t.Errorf("blocked error = %+v", blocked)
+	}
+	if len(result.Checks) != 1 {
+		t.Errorf("ran %d checks, want the checks after the block skipped", len(result.Checks))
+	}
+
+	result, err = guard.CheckInput(context.Background(), "Hello")
+	if err != nil {
+		t.Fatalf("CheckInput error: %v", err)
+	}
+	if result.Passed || len(result.Checks) != 2 || !result.Checks[0].Passed || result.Checks[1].Passed {
+		t.Errorf("result = %+v, want only the flagging check failed", result)
+	}
+	if result.Text != "Hello" {
+		t.Errorf("flagged text = %q, want it unchanged", result.Text)
+	}
+}
+
+func TestCheckPasses(t *testing.T) {
+	metric := heuristic.NewEquals(false)
+	tests := []struct {
+		name  string
+		opts  []CheckOption
+		score float64
+		want  bool
+	}{
+		{"default passes", nil, DefaultMinScore, true},
+		{"default fails", nil, 0.4, false},
+		{"min score", []CheckOption{WithMinScore(0.9)}, 0.8, false},
+		{"max score passes", []CheckOption{WithMaxScore(0.2)}, 0.1, true},
+		{"max score fails", []CheckOption{WithMaxScore(0.2)}, 0.3, false},
+		{"range", []CheckOption{WithMinScore(0.2), WithMaxScore(0.8)}, 0.5, true},
+	}
+	for _, tt := range tests {
+		check := NewCheck(metric, tt.opts...)
+		if got := check.passes(evaluation.NewScoreResult("equals", tt.score)); got != tt.want {
+			t.Errorf("%s: passes(%v) = %v, want %v", tt.name, tt.score, got, tt.want)
+		}
+	}
+
+	check := NewCheck(metric)
+	if check.passes(evaluation.NewFailedScoreResult("equals", errors.New("judge unavailable"))) {
+		t.Error("failed metric passed the check")
+	}
+}
+
+func TestWrapProvider(t *testing.T) {
+	mock := llm.NewMockProvider(map[string]string{
+		"My SSN is [SSN]": "Noted, 987-65-4321.",
+	}, "unexpected prompt")
+	guard := New(nil, newSSNCheck(StageInput), newSSNCheck(StageOutput))
+	provider := WrapProvider(mock, guard)
+
+	req := llm.CompletionRequest{Messages: []llm.Message{
+		{Role: "system", Content: "You are helpful."},
+		{Role: "user", Content: "My SSN is 123-45-6789"},
+	}}
+	resp, err := provider.Complete(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Complete error: %v", err)
+	}
+	if resp.Content != "Noted, [SSN]." {
+		t.Errorf("Content = %q, want the redacted completion", resp.Content)
+	}
+	if req.Messages[1].Content != "My SSN is 123-45-6789" {
+		t.Error("Complete modified the caller's messages")
+	}
+	if provider.Name() != "mock" {
+		t.Errorf("Name() = %q, want the wrapped provider's", provider.Name())
+	}
+}
@@ -0,0 +1,62 @@
+package guardrails
+
+import (
+	"context"
+	"slices"
+
+	"github.com/agentplexus/go-opik/evaluation/llm"
+)
+
+// Provider wraps an llm.Provider with a guard. The last user message of
+// each request is checked with the input checks, and the completion with
+// the output checks.
+type Provider struct {
+	llm.Provider
+	guard *Guard
+}
+
+// WrapProvider wraps provider with guard.
+func WrapProvider(provider llm.Provider, guard *Guard) *Provider {
+	return &Provider{
+		Provider: provider,
+		guard:    guard,
+	}
+}
+
+// Complete checks the request, sends it with the wrapped provider and
+// checks the completion. Redacted texts replace the originals. If a check
+// blocks the request, it is not sent; if one blocks the completion, it is
+// returned with the BlockedError.
+func (p *Provider) Complete(ctx context.Context, req llm.CompletionRequest) (*llm.CompletionResponse, error) {
+	var input string
+	if i := lastUserMessage(req.Messages); i >= 0 {
+		result, err := p.guard.CheckInput(ctx, req.Messages[i].Content)
+		if err != nil {
+			return nil, err
+		}
+		input = result.Text
+		if input != req.Messages[i].Content {
+			req.Messages = slices.Clone(req.Messages)
+			req.Messages[i].Content = input
+		}
+	}
+
+	resp, err := p.Provider.Complete(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	result, err := p.guard.CheckOutput(ctx, input, resp.Content)
+	resp.Content = result.Text
+	return resp, err
+}
+
+// lastUserMessage returns the index of the last user message, or -1.
+func lastUserMessage(messages []llm.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return i
+		}
+	}
+	return -1
+}
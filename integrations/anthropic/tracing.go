@@ -92,26 +92,20 @@ func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 			if json.Unmarshal(body, &respData) == nil {
 				endOpts = append(endOpts, opik.WithSpanOutput(respData))
 
+				metadata := map[string]any{
+					"duration_ms": duration.Milliseconds(),
+				}
+				endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
+
 				// Extract usage info
 				if usage, ok := respData["usage"].(map[string]any); ok {
-					metadata := map[string]any{
-						"duration_ms": duration.Milliseconds(),
-					}
-					if it, ok := usage["input_tokens"].(float64); ok {
-						metadata["input_tokens"] = int(it)
-					}
-					if ot, ok := usage["output_tokens"].(float64); ok {
-						metadata["output_tokens"] = int(ot)
-					}
-					endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
+					endOpts = append(endOpts, opik.WithSpanUsage(usageFromResponse(usage)))
 				}
 			}
 		}
 
 		if respErr != nil {
-			endOpts = append(endOpts, opik.WithSpanMetadata(map[string]any{
-				"error": respErr.Error(),
-			}))
+			endOpts = append(endOpts, opik.WithSpanError(respErr))
 		}
 
 		_ = span.End(ctx, endOpts...)
@@ -120,6 +114,29 @@ func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return resp, respErr
 }
 
+// usageFromResponse converts an Anthropic usage object into Opik usage keys.
+// Anthropic reports cache reads and writes separately from input_tokens, so
+// they are added back to obtain the total prompt token count.
+func usageFromResponse(usage map[string]any) map[string]int {
+	input, _ := usage["input_tokens"].(float64)
+	output, _ := usage["output_tokens"].(float64)
+	cacheRead, _ := usage["cache_read_input_tokens"].(float64)
+	cacheWrite, _ := usage["cache_creation_input_tokens"].(float64)
+
+	prompt := int(input + cacheRead + cacheWrite)
+	completion := int(output)
+
+	result := map[string]int{
+		opik.UsagePromptTokens:     prompt,
+		opik.UsageCompletionTokens: completion,
+		opik.UsageTotalTokens:      prompt + completion,
+	}
+	if cacheRead > 0 {
+		result[opik.UsageCachedTokens] = int(cacheRead)
+	}
+	return result
+}
+
 func isAnthropicRequest(req *http.Request) bool {
 	host := req.URL.Host
 	return host == "api.anthropic.com"
@@ -1,9 +1,15 @@
 package anthropic
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+
+	opik "github.com/agentplexus/go-opik"
+	"github.com/agentplexus/go-opik/internal/tracetest"
 )
 
 func TestIsAnthropicRequest(t *testing.T) {
@@ -168,3 +174,81 @@ func TestTracingTransportNonAnthropicRequest(t *testing.T) {
 
 	_ = tr
 }
+
+func TestUsageFromResponse(t *testing.T) {
+	usage := map[string]any{
+		"input_tokens":                float64(100),
+		"output_tokens":               float64(50),
+		"cache_read_input_tokens":     float64(300),
+		"cache_creation_input_tokens": float64(10),
+	}
+
+	got := usageFromResponse(usage)
+	want := map[string]int{
+		opik.UsagePromptTokens:     410,
+		opik.UsageCompletionTokens: 50,
+		opik.UsageTotalTokens:      460,
+		opik.UsageCachedTokens:     300,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("usage[%s] = %d, want %d", k, got[k], v)
+		}
+	}
+}
+
+func TestTracingTransportRedactsOnce(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	// The redactor wraps payloads, so redacting twice would nest them.
+	redactor := opik.RedactorFunc(func(field string, value any) any {
+		if field == opik.RedactFieldInput || field == opik.RedactFieldOutput {
+			return map[string]any{"redacted": value}
+		}
+		return value
+	})
+	client, err := opik.NewClient(opik.WithURL(ms.URL()), opik.WithRedactor(redactor))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	ctx, _, err := opik.StartTrace(context.Background(), client, "chat")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+
+	upstream := roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"id":"msg_1"}`)),
+		}, nil
+	})
+	httpClient := &http.Client{Transport: NewTracingTransport(upstream, client)}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.anthropic.com/v1/messages", strings.NewReader(`{"model":"claude-model"}`))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do error: %v", err)
+	}
+	resp.Body.Close()
+
+	spans := tracetest.SpanWrites(ms)
+	if len(spans) != 1 {
+		t.Fatalf("span writes = %d, want 1", len(spans))
+	}
+	if input, _ := spans[0]["input"].(map[string]any); input["redacted"] == nil || input["redacted"].(map[string]any)["model"] != "claude-model" {
+		t.Errorf("span input = %v, want the request redacted once", spans[0]["input"])
+	}
+	updates := tracetest.SpanUpdates(ms)
+	if len(updates) != 1 {
+		t.Fatalf("span updates = %d, want 1", len(updates))
+	}
+	if output, _ := updates[0]["output"].(map[string]any); output["redacted"] == nil || output["redacted"].(map[string]any)["id"] != "msg_1" {
+		t.Errorf("span output = %v, want the response redacted once", updates[0]["output"])
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
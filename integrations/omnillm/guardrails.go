@@ -0,0 +1,101 @@
+package omnillm
+
+import (
+	"context"
+	"slices"
+
+	"github.com/agentplexus/omnillm/provider"
+
+	"github.com/agentplexus/go-opik/guardrails"
+)
+
+// GuardedClient wraps a TracingClient with a guard. The last user message
+// of each request is checked with the input checks, and the first choice of
+// each completion with the output checks.
+type GuardedClient struct {
+	*TracingClient
+	guard *guardrails.Guard
+}
+
+// NewGuardedClient wraps client with guard.
+func NewGuardedClient(client *TracingClient, guard *guardrails.Guard) *GuardedClient {
+	return &GuardedClient{
+		TracingClient: client,
+		guard:         guard,
+	}
+}
+
+// CreateChatCompletion checks the request, creates a traced chat completion
+// and checks the response. Redacted texts replace the originals. If a check
+// blocks the request, it is not sent; if one blocks the response, it is
+// returned with the BlockedError.
+func (g *GuardedClient) CreateChatCompletion(ctx context.Context, req *provider.ChatCompletionRequest) (*provider.ChatCompletionResponse, error) {
+	req, input, err := g.checkRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.TracingClient.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	return g.checkResponse(ctx, input, resp)
+}
+
+// CreateChatCompletionWithMemory is CreateChatCompletion with conversation
+// memory.
+func (g *GuardedClient) CreateChatCompletionWithMemory(ctx context.Context, sessionID string, req *provider.ChatCompletionRequest) (*provider.ChatCompletionResponse, error) {
+	req, input, err := g.checkRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.TracingClient.CreateChatCompletionWithMemory(ctx, sessionID, req)
+	if err != nil {
+		return resp, err
+	}
+	return g.checkResponse(ctx, input, resp)
+}
+
+// CreateChatCompletionStream checks the request and creates a traced
+// streaming chat completion. Streamed chunks are not checked, since they
+// reach the caller before the completion is known.
+func (g *GuardedClient) CreateChatCompletionStream(ctx context.Context, req *provider.ChatCompletionRequest) (provider.ChatCompletionStream, error) {
+	req, _, err := g.checkRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return g.TracingClient.CreateChatCompletionStream(ctx, req)
+}
+
+// checkRequest runs the input checks on the last user message of req. It
+// returns a copy of req if the message was redacted, and the checked text.
+func (g *GuardedClient) checkRequest(ctx context.Context, req *provider.ChatCompletionRequest) (*provider.ChatCompletionRequest, string, error) {
+	i := len(req.Messages) - 1
+	for i >= 0 && req.Messages[i].Role != provider.RoleUser {
+		i--
+	}
+	if i < 0 {
+		return req, "", nil
+	}
+
+	result, err := g.guard.CheckInput(ctx, req.Messages[i].Content)
+	if err != nil {
+		return req, "", err
+	}
+	if result.Text != req.Messages[i].Content {
+		redacted := *req
+		redacted.Messages = slices.Clone(req.Messages)
+		redacted.Messages[i].Content = result.Text
+		req = &redacted
+	}
+	return req, result.Text, nil
+}
+
+// checkResponse runs the output checks on the first choice of resp.
+func (g *GuardedClient) checkResponse(ctx context.Context, input string, resp *provider.ChatCompletionResponse) (*provider.ChatCompletionResponse, error) {
+	if resp == nil || len(resp.Choices) == 0 {
+		return resp, nil
+	}
+	result, err := g.guard.CheckOutput(ctx, input, resp.Choices[0].Message.Content)
+	resp.Choices[0].Message.Content = result.Text
+	return resp, err
+}
@@ -63,23 +63,20 @@ func (t *TracingClient) CreateChatCompletion(ctx context.Context, req *provider.
 		if resp != nil {
 			endOpts = append(endOpts, opik.WithSpanOutput(responseToMap(resp)))
 
-			// Add usage metadata
 			metadata := map[string]any{
-				"duration_ms":       duration.Milliseconds(),
-				"prompt_tokens":     resp.Usage.PromptTokens,
-				"completion_tokens": resp.Usage.CompletionTokens,
-				"total_tokens":      resp.Usage.TotalTokens,
+				"duration_ms": duration.Milliseconds(),
 			}
 			if resp.Model != "" {
 				metadata["model"] = resp.Model
 			}
-			endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
+			endOpts = append(endOpts,
+				opik.WithSpanMetadata(metadata),
+				opik.WithSpanUsage(usageToMap(&resp.Usage)),
+			)
 		}
 
 		if respErr != nil {
-			endOpts = append(endOpts, opik.WithSpanMetadata(map[string]any{
-				"error": respErr.Error(),
-			}))
+			endOpts = append(endOpts, opik.WithSpanError(respErr))
 		}
 
 		_ = span.End(ctx, endOpts...)
@@ -116,9 +113,7 @@ func (t *TracingClient) CreateChatCompletionStream(ctx context.Context, req *pro
 	if streamErr != nil {
 		// End span with error if stream creation failed
 		if span != nil && err == nil {
-			_ = span.End(ctx, opik.WithSpanMetadata(map[string]any{
-				"error": streamErr.Error(),
-			}))
+			_ = span.End(ctx, opik.WithSpanError(streamErr))
 		}
 		return nil, streamErr
 	}
@@ -169,18 +164,16 @@ func (t *TracingClient) CreateChatCompletionWithMemory(ctx context.Context, sess
 			endOpts = append(endOpts, opik.WithSpanOutput(responseToMap(resp)))
 
 			metadata := map[string]any{
-				"duration_ms":       duration.Milliseconds(),
-				"prompt_tokens":     resp.Usage.PromptTokens,
-				"completion_tokens": resp.Usage.CompletionTokens,
-				"total_tokens":      resp.Usage.TotalTokens,
+				"duration_ms": duration.Milliseconds(),
 			}
-			endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
+			endOpts = append(endOpts,
+				opik.WithSpanMetadata(metadata),
+				opik.WithSpanUsage(usageToMap(&resp.Usage)),
+			)
 		}
 
 		if respErr != nil {
-			endOpts = append(endOpts, opik.WithSpanMetadata(map[string]any{
-				"error": respErr.Error(),
-			}))
+			endOpts = append(endOpts, opik.WithSpanError(respErr))
 		}
 
 		_ = span.End(ctx, endOpts...)
@@ -279,22 +272,27 @@ func (s *tracingStream) endSpan(err error) {
 	if s.model != "" {
 		metadata["model"] = s.model
 	}
+	endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
 	if s.usage != nil {
-		metadata["prompt_tokens"] = s.usage.PromptTokens
-		metadata["completion_tokens"] = s.usage.CompletionTokens
-		metadata["total_tokens"] = s.usage.TotalTokens
+		endOpts = append(endOpts, opik.WithSpanUsage(usageToMap(s.usage)))
 	}
-	endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
 
 	if err != nil {
-		endOpts = append(endOpts, opik.WithSpanMetadata(map[string]any{
-			"error": err.Error(),
-		}))
+		endOpts = append(endOpts, opik.WithSpanError(err))
 	}
 
 	_ = s.span.End(s.ctx, endOpts...)
 }
 
+// usageToMap converts omnillm usage into Opik usage keys.
+func usageToMap(usage *provider.Usage) map[string]int {
+	return map[string]int{
+		opik.UsagePromptTokens:     usage.PromptTokens,
+		opik.UsageCompletionTokens: usage.CompletionTokens,
+		opik.UsageTotalTokens:      usage.TotalTokens,
+	}
+}
+
 // requestToMap converts a ChatCompletionRequest to a map for span input.
 func requestToMap(req *provider.ChatCompletionRequest) map[string]any {
 	m := map[string]any{
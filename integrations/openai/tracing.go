@@ -92,29 +92,20 @@ func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 			if json.Unmarshal(body, &respData) == nil {
 				endOpts = append(endOpts, opik.WithSpanOutput(respData))
 
+				metadata := map[string]any{
+					"duration_ms": duration.Milliseconds(),
+				}
+				endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
+
 				// Extract usage info
 				if usage, ok := respData["usage"].(map[string]any); ok {
-					metadata := map[string]any{
-						"duration_ms": duration.Milliseconds(),
-					}
-					if pt, ok := usage["prompt_tokens"].(float64); ok {
-						metadata["prompt_tokens"] = int(pt)
-					}
-					if ct, ok := usage["completion_tokens"].(float64); ok {
-						metadata["completion_tokens"] = int(ct)
-					}
-					if tt, ok := usage["total_tokens"].(float64); ok {
-						metadata["total_tokens"] = int(tt)
-					}
-					endOpts = append(endOpts, opik.WithSpanMetadata(metadata))
+					endOpts = append(endOpts, opik.WithSpanUsage(usageFromResponse(usage)))
 				}
 			}
 		}
 
 		if respErr != nil {
-			endOpts = append(endOpts, opik.WithSpanMetadata(map[string]any{
-				"error": respErr.Error(),
-			}))
+			endOpts = append(endOpts, opik.WithSpanError(respErr))
 		}
 
 		_ = span.End(ctx, endOpts...)
@@ -123,6 +114,31 @@ func (t *TracingTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return resp, respErr
 }
 
+// usageFromResponse converts an OpenAI usage object into Opik usage keys.
+func usageFromResponse(usage map[string]any) map[string]int {
+	result := make(map[string]int)
+	if pt, ok := usage["prompt_tokens"].(float64); ok {
+		result[opik.UsagePromptTokens] = int(pt)
+	}
+	if ct, ok := usage["completion_tokens"].(float64); ok {
+		result[opik.UsageCompletionTokens] = int(ct)
+	}
+	if tt, ok := usage["total_tokens"].(float64); ok {
+		result[opik.UsageTotalTokens] = int(tt)
+	}
+	if details, ok := usage["prompt_tokens_details"].(map[string]any); ok {
+		if cached, ok := details["cached_tokens"].(float64); ok {
+			result[opik.UsageCachedTokens] = int(cached)
+		}
+	}
+	if details, ok := usage["completion_tokens_details"].(map[string]any); ok {
+		if reasoning, ok := details["reasoning_tokens"].(float64); ok {
+			result[opik.UsageReasoningTokens] = int(reasoning)
+		}
+	}
+	return result
+}
+
 func isOpenAIRequest(req *http.Request) bool {
 	host := req.URL.Host
 	return host == "api.openai.com" || host == "openai.azure.com"
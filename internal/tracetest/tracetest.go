@@ -0,0 +1,81 @@
+// Package tracetest provides a mock Opik server that records the traces and
+// spans logged to it, for use in the SDK's own tests.
+package tracetest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+// Paths of the trace and span batch endpoints the SDK logs to.
+const (
+	TracesBatchPath = "/v1/private/traces/batch"
+	SpansBatchPath  = "/v1/private/spans/batch"
+)
+
+// NewServer creates a mock server that accepts trace and span batch creates
+// and updates, so that a client pointed at it can log traces.
+func NewServer() *testutil.MockServer {
+	ms := testutil.NewMockServer()
+	ms.OnPost(TracesBatchPath).Respond(http.StatusNoContent, nil)
+	ms.OnPatch(TracesBatchPath).Respond(http.StatusNoContent, nil)
+	ms.OnPost(SpansBatchPath).Respond(http.StatusNoContent, nil)
+	ms.OnPatch(SpansBatchPath).Respond(http.StatusNoContent, nil)
+	return ms
+}
+
+// TraceWrites returns the traces sent to the trace batch create endpoint,
+// in the order they were sent.
+func TraceWrites(ms *testutil.MockServer) []map[string]any {
+	return batchWrites(ms, TracesBatchPath, "traces")
+}
+
+// SpanWrites returns the spans sent to the span batch create endpoint, in
+// the order they were sent.
+func SpanWrites(ms *testutil.MockServer) []map[string]any {
+	return batchWrites(ms, SpansBatchPath, "spans")
+}
+
+// TraceUpdates returns the updates sent to the trace batch update endpoint,
+// in the order they were sent.
+func TraceUpdates(ms *testutil.MockServer) []map[string]any {
+	return batchUpdates(ms, TracesBatchPath)
+}
+
+// SpanUpdates returns the updates sent to the span batch update endpoint, in
+// the order they were sent.
+func SpanUpdates(ms *testutil.MockServer) []map[string]any {
+	return batchUpdates(ms, SpansBatchPath)
+}
+
+// batchWrites decodes the items in field of the POST requests to path.
+// Bodies that are not JSON objects are skipped.
+func batchWrites(ms *testutil.MockServer, path, field string) []map[string]any {
+	var writes []map[string]any
+	for _, req := range ms.RequestsForPath(path) {
+		var body map[string][]map[string]any
+		if req.Method != http.MethodPost || json.Unmarshal(req.Body, &body) != nil {
+			continue
+		}
+		writes = append(writes, body[field]...)
+	}
+	return writes
+}
+
+// batchUpdates decodes the updates of the PATCH requests to path. Bodies
+// that are not JSON objects are skipped.
+func batchUpdates(ms *testutil.MockServer, path string) []map[string]any {
+	var updates []map[string]any
+	for _, req := range ms.RequestsForPath(path) {
+		var body struct {
+			Update map[string]any `json:"update"`
+		}
+		if req.Method != http.MethodPatch || json.Unmarshal(req.Body, &body) != nil {
+			continue
+		}
+		updates = append(updates, body.Update)
+	}
+	return updates
+}
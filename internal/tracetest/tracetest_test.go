@@ -0,0 +1,65 @@
+package tracetest
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestTracingServer(t *testing.T) {
+	ms := NewServer()
+	defer ms.Close()
+
+	send := func(method, path, body string) {
+		t.Helper()
+		req, err := http.NewRequest(method, ms.URL()+path, bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("NewRequest error: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request error: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("%s %s StatusCode = %d, want %d", method, path, resp.StatusCode, http.StatusNoContent)
+		}
+	}
+
+	send(http.MethodPost, TracesBatchPath, `{"traces": [{"name": "a"}, {"name": "b"}]}`)
+	send(http.MethodPatch, TracesBatchPath, `{"ids": ["1"], "update": {"name": "c"}}`)
+	send(http.MethodPost, SpansBatchPath, `{"spans": [{"name": "d"}]}`)
+	send(http.MethodPatch, SpansBatchPath, `{"ids": ["2"], "update": {"name": "e"}}`)
+	send(http.MethodPatch, SpansBatchPath, `{"ids": ["3"], "update": {"name": "f"}}`)
+
+	names := func(items []map[string]any) []any {
+		var names []any
+		for _, item := range items {
+			names = append(names, item["name"])
+		}
+		return names
+	}
+	tests := []struct {
+		name  string
+		items []map[string]any
+		want  []any
+	}{
+		{"TraceWrites", TraceWrites(ms), []any{"a", "b"}},
+		{"TraceUpdates", TraceUpdates(ms), []any{"c"}},
+		{"SpanWrites", SpanWrites(ms), []any{"d"}},
+		{"SpanUpdates", SpanUpdates(ms), []any{"e", "f"}},
+	}
+	for _, tt := range tests {
+		got := names(tt.items)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -84,6 +84,9 @@ func (t *traceAdapter) End(opts ...llmops.EndOption) error {
 	if cfg.Metadata != nil {
 		opikOpts = append(opikOpts, opik.WithTraceMetadata(cfg.Metadata))
 	}
+	if cfg.Error != nil {
+		opikOpts = append(opikOpts, opik.WithTraceError(cfg.Error))
+	}
 
 	return t.trace.End(context.Background(), opikOpts...)
 }
@@ -214,6 +217,9 @@ func (s *spanAdapter) End(opts ...llmops.EndOption) error {
 	if cfg.Metadata != nil {
 		opikOpts = append(opikOpts, opik.WithSpanMetadata(cfg.Metadata))
 	}
+	if cfg.Error != nil {
+		opikOpts = append(opikOpts, opik.WithSpanError(cfg.Error))
+	}
 
 	return s.span.End(context.Background(), opikOpts...)
 }
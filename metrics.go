@@ -0,0 +1,151 @@
+package opik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// meterName is the instrumentation scope of the SDK's own metrics.
+const meterName = "github.com/agentplexus/go-opik"
+
+// Attribute keys of the SDK's own metrics.
+const (
+	attrItemType   = attribute.Key("opik.item.type")
+	attrErrorType  = attribute.Key("error.type")
+	attrStatusCode = attribute.Key("http.response.status_code")
+	attrMethod     = attribute.Key("http.request.method")
+)
+
+// sdkMetrics holds the instruments the client and batcher report to.
+// Without a MeterProvider they are no-ops.
+type sdkMetrics struct {
+	meter metric.Meter
+
+	enqueued      metric.Int64Counter
+	sent          metric.Int64Counter
+	dropped       metric.Int64Counter
+	spooled       metric.Int64Counter
+	flushDuration metric.Float64Histogram
+	queueDepth    metric.Int64ObservableGauge
+	spoolDepth    metric.Int64ObservableGauge
+
+	httpErrors    metric.Int64Counter
+	httpRetries   metric.Int64Counter
+	httpBodyBytes metric.Int64Counter
+}
+
+// newSDKMetrics creates the SDK's instruments with the given provider; nil
+// disables metrics.
+func newSDKMetrics(provider metric.MeterProvider) (*sdkMetrics, error) {
+	if provider == nil {
+		provider = noop.NewMeterProvider()
+	}
+	m := &sdkMetrics{
+		meter: provider.Meter(meterName, metric.WithInstrumentationVersion(Version)),
+	}
+
+	var err, errs error
+	m.enqueued, err = m.meter.Int64Counter("opik.batcher.items.enqueued",
+		metric.WithDescription("Items added to the batcher."),
+		metric.WithUnit("{item}"))
+	errs = errors.Join(errs, err)
+	m.sent, err = m.meter.Int64Counter("opik.batcher.items.sent",
+		metric.WithDescription("Items delivered by the batcher. Operations on the same entity merged in a batch count once."),
+		metric.WithUnit("{item}"))
+	errs = errors.Join(errs, err)
+	m.dropped, err = m.meter.Int64Counter("opik.batcher.items.dropped",
+		metric.WithDescription("Items the batcher could not deliver and reported to OnError."),
+		metric.WithUnit("{item}"))
+	errs = errors.Join(errs, err)
+	m.spooled, err = m.meter.Int64Counter("opik.batcher.items.spooled",
+		metric.WithDescription("Items written to the batcher's spool."),
+		metric.WithUnit("{item}"))
+	errs = errors.Join(errs, err)
+	m.flushDuration, err = m.meter.Float64Histogram("opik.batcher.flush.duration",
+		metric.WithDescription("Time taken to send a batch, including retries."),
+		metric.WithUnit("s"))
+	errs = errors.Join(errs, err)
+	m.queueDepth, err = m.meter.Int64ObservableGauge("opik.batcher.queue.depth",
+		metric.WithDescription("Items added to the batcher but not yet processed."),
+		metric.WithUnit("{item}"))
+	errs = errors.Join(errs, err)
+	m.spoolDepth, err = m.meter.Int64ObservableGauge("opik.batcher.spool.depth",
+		metric.WithDescription("Items waiting in the batcher's spool."),
+		metric.WithUnit("{item}"))
+	errs = errors.Join(errs, err)
+
+	m.httpErrors, err = m.meter.Int64Counter("opik.http.client.errors",
+		metric.WithDescription("Failed API request attempts, by response status or error type."),
+		metric.WithUnit("{request}"))
+	errs = errors.Join(errs, err)
+	m.httpRetries, err = m.meter.Int64Counter("opik.http.client.retries",
+		metric.WithDescription("API requests retried by the client."),
+		metric.WithUnit("{request}"))
+	errs = errors.Join(errs, err)
+	m.httpBodyBytes, err = m.meter.Int64Counter("opik.http.client.request.body.size",
+		metric.WithDescription("Bytes of request payloads sent to the API."),
+		metric.WithUnit("By"))
+	errs = errors.Join(errs, err)
+
+	return m, errs
+}
+
+// countItems adds the number of items of each type to counter.
+func countItems(counter metric.Int64Counter, items []BatchItem, attrs ...attribute.KeyValue) {
+	counts := make(map[string]int64, 3)
+	for _, item := range items {
+		counts[item.Type()]++
+	}
+	for itemType, n := range counts {
+		counter.Add(context.Background(), n,
+			metric.WithAttributes(append(attrs, attrItemType.String(itemType))...))
+	}
+}
+
+// recordAttempt records the outcome of an API request attempt.
+func (m *sdkMetrics) recordAttempt(req *http.Request, resp *http.Response, err error) {
+	ctx := context.Background()
+	// Requests rejected by the circuit breaker were not sent
+	if req.ContentLength > 0 && !IsCircuitOpen(err) {
+		m.httpBodyBytes.Add(ctx, req.ContentLength, metric.WithAttributes(attrMethod.String(req.Method)))
+	}
+	switch {
+	case err != nil:
+		m.httpErrors.Add(ctx, 1, metric.WithAttributes(
+			attrMethod.String(req.Method), attrErrorType.String(errorType(err))))
+	case resp.StatusCode >= 400:
+		m.httpErrors.Add(ctx, 1, metric.WithAttributes(
+			attrMethod.String(req.Method), attrStatusCode.Int(resp.StatusCode)))
+	}
+}
+
+// errorType classifies err for the error.type attribute.
+func errorType(err error) string {
+	switch {
+	case IsCircuitOpen(err):
+		return "circuit_open"
+	case errors.Is(err, ErrSpoolFull):
+		return "spool_full"
+	case errors.Is(err, ErrClientClosed):
+		return "client_closed"
+	case errors.Is(err, ErrInvalidFeedbackScore), errors.Is(err, ErrInvalidInput):
+		return "invalid_input"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case IsRateLimited(err):
+		return "rate_limited"
+	case IsServerError(err):
+		return "server_error"
+	}
+	if _, ok := statusCode(err); ok {
+		return "client_error"
+	}
+	return "network"
+}
@@ -0,0 +1,123 @@
+package opik
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+// collectMetrics returns the int64 data points of each metric, keyed by
+// metric name and the value of attr.
+func collectMetrics(t *testing.T, reader sdkmetric.Reader, attr attribute.Key) map[string]int64 {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	points := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			var dps []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				dps = data.DataPoints
+			case metricdata.Gauge[int64]:
+				dps = data.DataPoints
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					points[m.Name] += int64(dp.Count) //nolint:gosec // G115: test counts are small
+				}
+			}
+			for _, dp := range dps {
+				key := m.Name
+				if v, ok := dp.Attributes.Value(attr); ok {
+					key += "/" + v.Emit()
+				}
+				points[key] += dp.Value
+			}
+		}
+	}
+	return points
+}
+
+func TestBatcherMetrics(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/batch").Respond(http.StatusNoContent, nil)
+	ms.OnPut("/v1/private/traces/feedback-scores").Respond(http.StatusBadRequest, nil)
+
+	reader := sdkmetric.NewManualReader()
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	client, err := NewBatchingClientWithConfig(config,
+		WithURL(ms.URL()),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	defer client.Close(time.Second)
+
+	if _, err := client.Trace(context.Background(), "measured"); err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	client.AddFeedbackAsync("trace", testTraceID, "accuracy", 1, "")
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	points := collectMetrics(t, reader, attrItemType)
+	want := map[string]int64{
+		"opik.batcher.items.enqueued/trace":    1,
+		"opik.batcher.items.enqueued/feedback": 1,
+		"opik.batcher.items.sent/trace":        1,
+		"opik.batcher.items.dropped/feedback":  1,
+		"opik.batcher.queue.depth":             0,
+	}
+	for key, n := range want {
+		if points[key] != n {
+			t.Errorf("%s = %d, want %d", key, points[key], n)
+		}
+	}
+	if points["opik.batcher.flush.duration"] == 0 {
+		t.Error("flush duration not recorded")
+	}
+	if _, ok := points["opik.batcher.queue.depth"]; !ok {
+		t.Error("queue depth not observed")
+	}
+
+	errs := collectMetrics(t, reader, attrStatusCode)
+	if errs["opik.http.client.errors/400"] != 1 {
+		t.Errorf("HTTP errors = %v", errs)
+	}
+	if errs["opik.http.client.request.body.size"] == 0 {
+		t.Error("request payload bytes not recorded")
+	}
+}
+
+func TestClientRetryMetrics(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	failingRoute(ms.OnGet("/v1/private/projects"), 1, http.StatusServiceUnavailable, nil, `{"content":[]}`)
+
+	reader := sdkmetric.NewManualReader()
+	client := newTestRetryClient(t, ms, WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if _, err := client.ListProjects(context.Background(), 1, 10); err != nil {
+		t.Fatalf("ListProjects error: %v", err)
+	}
+
+	points := collectMetrics(t, reader, attrStatusCode)
+	if points["opik.http.client.errors/503"] != 1 || points["opik.http.client.retries"] != 1 {
+		t.Errorf("metrics = %v", points)
+	}
+}
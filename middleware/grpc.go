@@ -0,0 +1,416 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	opik "github.com/agentplexus/go-opik"
+)
+
+// gRPC metadata keys for distributed tracing. Metadata keys are lower case.
+var (
+	metadataTraceID      = strings.ToLower(opik.HeaderTraceID)
+	metadataParentSpanID = strings.ToLower(opik.HeaderParentSpanID)
+)
+
+// UnaryServerInterceptor returns a gRPC interceptor that traces incoming
+// unary RPCs. RPCs carrying the trace context of an Opik trace in their
+// metadata continue the caller's trace, as with ContinueTrace; others start
+// a new trace. A new trace for an RPC with only a W3C traceparent takes its
+// ID from the W3C trace.
+func UnaryServerInterceptor(client *opik.Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		rpcCtx, trace, span, startErr := startRPC(ctx, client, info.FullMethod)
+		if startErr != nil {
+			// If tracing fails, continue without it
+			return handler(ctx, req)
+		}
+		ctx = rpcCtx
+
+		// Record handler panics before letting them propagate
+		defer func() {
+			if rec := recover(); rec != nil {
+				endRPC(ctx, trace, span, codes.Internal, opik.NewPanicError(rec), nil)
+				panic(rec)
+			}
+		}()
+
+		resp, err = handler(ctx, req)
+		code := status.Code(err)
+		recorded := err
+		if !isServerError(code) {
+			recorded = nil
+		}
+		endRPC(ctx, trace, span, code, recorded, nil)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns a gRPC interceptor that traces incoming
+// streaming RPCs, like UnaryServerInterceptor. The trace and span are in the
+// stream's context.
+func StreamServerInterceptor(client *opik.Client) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, trace, span, err := startRPC(ss.Context(), client, info.FullMethod)
+		if err != nil {
+			// If tracing fails, continue without it
+			return handler(srv, ss)
+		}
+
+		wrapped := &serverStream{ServerStream: ss, ctx: ctx}
+
+		// Record handler panics before letting them propagate
+		defer func() {
+			if rec := recover(); rec != nil {
+				endRPC(ctx, trace, span, codes.Internal, opik.NewPanicError(rec), wrapped.counts())
+				panic(rec)
+			}
+		}()
+
+		err = handler(srv, wrapped)
+		code := status.Code(err)
+		recorded := err
+		if !isServerError(code) {
+			recorded = nil
+		}
+		endRPC(ctx, trace, span, code, recorded, wrapped.counts())
+		return err
+	}
+}
+
+// startRPC starts the trace and span for an incoming RPC. The trace is nil
+// when the RPC continues a trace from another service.
+func startRPC(ctx context.Context, client *opik.Client, fullMethod string) (context.Context, *opik.Trace, *opik.Span, error) {
+	input := RPCMetadata(ctx, fullMethod)
+	headers := ExtractTraceMetadata(ctx)
+
+	if headers.HasOpikTrace() {
+		ctx, span, err := client.ContinueTrace(ctx, headers, fullMethod,
+			opik.WithSpanType(opik.SpanTypeGeneral),
+			opik.WithSpanInput(input),
+		)
+		return ctx, nil, span, err
+	}
+
+	// A traceparent from a service or proxy that only uses OpenTelemetry is
+	// kept, so that the trace takes its ID and outgoing RPCs continue it
+	ctx = opik.ContextWithDistributedTraceHeaders(ctx, headers)
+	ctx, trace, err := opik.StartTrace(ctx, client, fullMethod, opik.WithTraceInput(input))
+	if err != nil {
+		return ctx, nil, nil, err
+	}
+	ctx, span, err := opik.StartSpan(ctx, fullMethod,
+		opik.WithSpanType(opik.SpanTypeGeneral),
+		opik.WithSpanInput(input),
+	)
+	if err != nil {
+		_ = trace.End(ctx)
+		return ctx, nil, nil, err
+	}
+	return ctx, trace, span, nil
+}
+
+// endRPC ends the span and trace of an incoming RPC with its status code,
+// recording err on both if it is non-nil.
+func endRPC(ctx context.Context, trace *opik.Trace, span *opik.Span, code codes.Code, err error, extra map[string]any) {
+	output := rpcOutput(code, extra)
+
+	spanOpts := []opik.SpanOption{opik.WithSpanOutput(output)}
+	traceOpts := []opik.TraceOption{opik.WithTraceOutput(output)}
+	if err != nil {
+		spanOpts = append(spanOpts, opik.WithSpanError(err))
+		traceOpts = append(traceOpts, opik.WithTraceError(err))
+	}
+
+	_ = span.End(ctx, spanOpts...)
+	if trace != nil {
+		_ = trace.End(ctx, traceOpts...)
+	}
+}
+
+// UnaryClientInterceptor returns a gRPC interceptor that creates a span for
+// each outgoing unary RPC made within a trace, and adds the trace context
+// to the RPC's metadata.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		// Only create span if there's an active trace/span in context
+		if opik.SpanFromContext(ctx) == nil && opik.TraceFromContext(ctx) == nil {
+			return invoker(InjectTraceMetadata(ctx), method, req, reply, cc, opts...)
+		}
+
+		spanCtx, span, err := startClientRPC(ctx, cc.Target(), method)
+		if err != nil {
+			return invoker(InjectTraceMetadata(ctx), method, req, reply, cc, opts...)
+		}
+
+		err = invoker(InjectTraceMetadata(spanCtx), method, req, reply, cc, opts...)
+		endClientRPC(spanCtx, span, err, nil)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns a gRPC interceptor that creates a span for
+// each outgoing streaming RPC made within a trace, like
+// UnaryClientInterceptor. The span ends when the stream does.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		// Only create span if there's an active trace/span in context
+		if opik.SpanFromContext(ctx) == nil && opik.TraceFromContext(ctx) == nil {
+			return streamer(InjectTraceMetadata(ctx), desc, cc, method, opts...)
+		}
+
+		spanCtx, span, err := startClientRPC(ctx, cc.Target(), method)
+		if err != nil {
+			return streamer(InjectTraceMetadata(ctx), desc, cc, method, opts...)
+		}
+
+		cs, err := streamer(InjectTraceMetadata(spanCtx), desc, cc, method, opts...)
+		if err != nil {
+			endClientRPC(spanCtx, span, err, nil)
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, ctx: spanCtx, span: span, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+// startClientRPC starts the span for an outgoing RPC.
+func startClientRPC(ctx context.Context, target, method string) (context.Context, *opik.Span, error) {
+	return opik.StartSpan(ctx, method,
+		opik.WithSpanType(opik.SpanTypeTool),
+		opik.WithSpanInput(map[string]any{
+			"method": method,
+			"target": target,
+		}),
+	)
+}
+
+// endClientRPC ends the span of an outgoing RPC. Any status other than OK
+// is recorded as an error.
+func endClientRPC(ctx context.Context, span *opik.Span, err error, extra map[string]any) {
+	opts := []opik.SpanOption{opik.WithSpanOutput(rpcOutput(status.Code(err), extra))}
+	if err != nil {
+		opts = append(opts, opik.WithSpanError(err))
+	}
+	_ = span.End(ctx, opts...)
+}
+
+// InjectTraceMetadata returns ctx with the current trace context added to
+// its outgoing gRPC metadata. W3C entries already present, such as those
+// set by OpenTelemetry instrumentation, are left unchanged.
+func InjectTraceMetadata(ctx context.Context) context.Context {
+	headers := opik.GetDistributedTraceHeaders(ctx)
+	md, _ := metadata.FromOutgoingContext(ctx)
+
+	var kv []string
+	if headers.TraceID != "" {
+		kv = append(kv, metadataTraceID, headers.TraceID)
+	}
+	if headers.ParentSpanID != "" {
+		kv = append(kv, metadataParentSpanID, headers.ParentSpanID)
+	}
+	if headers.TraceParent != "" && len(md.Get(opik.HeaderTraceParent)) == 0 {
+		kv = append(kv, opik.HeaderTraceParent, headers.TraceParent)
+		if headers.TraceState != "" {
+			kv = append(kv, opik.HeaderTraceState, headers.TraceState)
+		}
+	}
+	if headers.Baggage != "" && len(md.Get(opik.HeaderBaggage)) == 0 {
+		kv = append(kv, opik.HeaderBaggage, headers.Baggage)
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+
+	md = md.Copy()
+	for i := 0; i < len(kv); i += 2 {
+		md.Set(kv[i], kv[i+1])
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// ExtractTraceMetadata extracts trace context from the incoming gRPC
+// metadata in ctx. Without Opik entries, ContinueTrace derives the Opik
+// trace and parent span IDs from the W3C traceparent entry.
+func ExtractTraceMetadata(ctx context.Context) opik.DistributedTraceHeaders {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return opik.DistributedTraceHeaders{
+		TraceID:      firstValue(md, metadataTraceID),
+		ParentSpanID: firstValue(md, metadataParentSpanID),
+		TraceParent:  firstValue(md, opik.HeaderTraceParent),
+		TraceState:   firstValue(md, opik.HeaderTraceState),
+		Baggage:      firstValue(md, opik.HeaderBaggage),
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// RPCMetadata extracts common metadata from an incoming RPC.
+func RPCMetadata(ctx context.Context, fullMethod string) map[string]any {
+	service, method := splitMethod(fullMethod)
+	md := map[string]any{
+		"service": service,
+		"method":  method,
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		md["peer"] = p.Addr.String()
+	}
+	if incoming, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := incoming.Get("user-agent"); len(ua) > 0 {
+			md["user_agent"] = ua[0]
+		}
+	}
+	return md
+}
+
+// splitMethod splits a full RPC method name, "/package.Service/Method",
+// into its service and method names.
+func splitMethod(fullMethod string) (service, method string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "", fullMethod
+	}
+	return service, method
+}
+
+// rpcOutput creates the span output of an RPC with status code.
+func rpcOutput(code codes.Code, extra map[string]any) map[string]any {
+	output := map[string]any{
+		"status_code": int(code),
+		"status":      code.String(),
+	}
+	for k, v := range extra {
+		output[k] = v
+	}
+	return output
+}
+
+// isServerError reports whether an RPC that returned code failed because
+// of the server, as opposed to the request. Like 5xx HTTP responses, only
+// these are recorded as errors on server spans.
+func isServerError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented,
+		codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	default:
+		return false
+	}
+}
+
+// serverStream wraps grpc.ServerStream to carry the traced context and
+// count messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+
+	mu       sync.Mutex
+	received int
+	sent     int
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.received++
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *serverStream) counts() map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]any{
+		"messages_received": s.received,
+		"messages_sent":     s.sent,
+	}
+}
+
+// clientStream wraps grpc.ClientStream to end the RPC's span when the
+// stream finishes.
+type clientStream struct {
+	grpc.ClientStream
+	ctx           context.Context
+	span          *opik.Span
+	serverStreams bool
+
+	mu       sync.Mutex
+	received int
+	sent     int
+	once     sync.Once
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		// io.EOF means the stream failed; the status comes from RecvMsg
+		if !errors.Is(err, io.EOF) {
+			s.end(err)
+		}
+		return err
+	}
+	s.mu.Lock()
+	s.sent++
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case errors.Is(err, io.EOF):
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	default:
+		s.mu.Lock()
+		s.received++
+		s.mu.Unlock()
+		if !s.serverStreams {
+			// The single response finishes the stream
+			s.end(nil)
+		}
+	}
+	return err
+}
+
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		counts := map[string]any{
+			"messages_received": s.received,
+			"messages_sent":     s.sent,
+		}
+		s.mu.Unlock()
+		endClientRPC(s.ctx, s.span, err, counts)
+	})
+}
@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	opik "github.com/agentplexus/go-opik"
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+// startHealthServer serves the gRPC health service in memory, with the
+// tracing interceptors installed on both ends.
+func startHealthServer(t *testing.T, client *opik.Client, healthServer healthpb.HealthServer) healthpb.HealthClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(client)),
+		grpc.StreamInterceptor(StreamServerInterceptor(client)),
+	)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+// sentSpans returns the spans created, by type and name.
+func sentSpans(ms *testutil.MockServer) map[string]map[string]any {
+	spans := make(map[string]map[string]any)
+	for _, span := range tracetest.SpanWrites(ms) {
+		spans[span["type"].(string)+" "+span["name"].(string)] = span
+	}
+	return spans
+}
+
+func TestGRPCInterceptorsPropagateTraces(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	var serverHeaders opik.DistributedTraceHeaders
+	var serverSpanID string
+	hs := &recordingHealthServer{Server: health.NewServer(), check: func(ctx context.Context) {
+		serverHeaders = ExtractTraceMetadata(ctx)
+		serverSpanID = opik.CurrentSpanID(ctx)
+	}}
+	hc := startHealthServer(t, client, hs)
+
+	ctx, trace, err := opik.StartTrace(context.Background(), client, "caller")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+	if _, err := hc.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+	_ = trace.End(ctx)
+
+	spans := sentSpans(ms)
+	clientSpan := spans["tool /grpc.health.v1.Health/Check"]
+	if clientSpan == nil {
+		t.Fatal("no span created for the RPC")
+	}
+	if serverHeaders.TraceID != trace.ID() || serverHeaders.ParentSpanID != clientSpan["id"] {
+		t.Errorf("server received %s/%s, want %s/%s", serverHeaders.TraceID, serverHeaders.ParentSpanID, trace.ID(), clientSpan["id"])
+	}
+	if serverHeaders.TraceParent == "" {
+		t.Error("server received no traceparent")
+	}
+	if serverSpanID == "" || serverSpanID == clientSpan["id"] {
+		t.Errorf("server span ID = %q, want a child of the client span", serverSpanID)
+	}
+}
+
+func TestGRPCServerInterceptorStartsTracesForTraceParent(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	var serverTraceID string
+	hs := &recordingHealthServer{Server: health.NewServer(), check: func(ctx context.Context) {
+		serverTraceID = opik.CurrentTraceID(ctx)
+	}}
+	hc := startHealthServer(t, client, hs)
+
+	// An RPC forwarded by a proxy that only uses OpenTelemetry
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		opik.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if _, err := hc.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("Check error: %v", err)
+	}
+
+	traces := tracetest.TraceWrites(ms)
+	if len(traces) != 1 || traces[0]["id"] != serverTraceID {
+		t.Fatalf("traces = %v, want the server's trace %s", traces, serverTraceID)
+	}
+	if serverTraceID != "4bf92f35-77b3-7da6-a3ce-929d0e0e4736" {
+		t.Errorf("trace ID = %q, want the one derived from traceparent", serverTraceID)
+	}
+	if update := lastUpdate(t, tracetest.TraceUpdates(ms)); update["end_time"] == nil {
+		t.Errorf("trace update = %v, want the trace ended", update)
+	}
+}
+
+func TestGRPCServerInterceptorRecordsErrors(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	tests := []struct {
+		err      error
+		wantType string
+	}{
+		{nil, ""},
+		{status.Error(codes.NotFound, "unknown service"), ""},
+		{status.Error(codes.Unavailable, "index down"), "*status.Error"},
+	}
+
+	for _, tt := range tests {
+		hs := &recordingHealthServer{Server: health.NewServer(), err: tt.err}
+		hc := startHealthServer(t, client, hs)
+
+		_, err := hc.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if status.Code(err) != status.Code(tt.err) {
+			t.Fatalf("Check error = %v, want %v", err, tt.err)
+		}
+
+		if got := lastErrorType(t, tracetest.SpanUpdates(ms)); got != tt.wantType {
+			t.Errorf("%v: span exception type = %q, want %q", status.Code(tt.err), got, tt.wantType)
+		}
+		if got := lastErrorType(t, tracetest.TraceUpdates(ms)); got != tt.wantType {
+			t.Errorf("%v: trace exception type = %q, want %q", status.Code(tt.err), got, tt.wantType)
+		}
+	}
+}
+
+func TestGRPCStreamInterceptors(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	var serverTraceID string
+	hs := &recordingHealthServer{Server: health.NewServer(), watch: func(ctx context.Context) {
+		serverTraceID = opik.CurrentTraceID(ctx)
+	}}
+	hc := startHealthServer(t, client, hs)
+
+	ctx, trace, err := opik.StartTrace(context.Background(), client, "caller")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+	stream, err := hc.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Watch error: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv error: %v", err)
+	}
+	if _, err := stream.Recv(); err == nil {
+		t.Fatal("Recv after the last message succeeded")
+	}
+	_ = trace.End(ctx)
+
+	if serverTraceID != trace.ID() {
+		t.Errorf("server trace ID = %q, want %q", serverTraceID, trace.ID())
+	}
+	if sentSpans(ms)["tool /grpc.health.v1.Health/Watch"] == nil {
+		t.Error("no span created for the stream")
+	}
+}
+
+func TestInjectTraceMetadataWithoutTrace(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "key", "value")
+	if got := InjectTraceMetadata(ctx); got != ctx {
+		t.Error("InjectTraceMetadata changed a context without a trace")
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	service, method := splitMethod("/grpc.health.v1.Health/Check")
+	if service != "grpc.health.v1.Health" || method != "Check" {
+		t.Errorf("splitMethod = %q, %q", service, method)
+	}
+}
+
+// recordingHealthServer is a health server that calls check and watch with
+// the RPC context, and fails Check with err.
+type recordingHealthServer struct {
+	*health.Server
+	check func(context.Context)
+	watch func(context.Context)
+	err   error
+}
+
+func (s *recordingHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if s.check != nil {
+		s.check(ctx)
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.Server.Check(ctx, req)
+}
+
+func (s *recordingHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if s.watch != nil {
+		s.watch(stream.Context())
+	}
+	// Send the current status and finish
+	resp, err := s.Server.Check(stream.Context(), req)
+	if err != nil {
+		return err
+	}
+	return stream.Send(resp)
+}
@@ -1,71 +1,320 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	opik "github.com/agentplexus/go-opik"
 )
 
+// MiddlewareOption configures TracingMiddleware.
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	bodyLimit int
+	identify  func(*http.Request) RequestIdentity
+	skip      []func(*http.Request) bool
+}
+
+// RequestIdentity identifies the user and session a request belongs to.
+type RequestIdentity struct {
+	UserID    string
+	SessionID string
+}
+
+// WithBodyCapture records request and response bodies of up to limit bytes
+// in the input and output. Longer bodies are truncated.
+func WithBodyCapture(limit int) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.bodyLimit = limit
+	}
+}
+
+// WithIdentityFunc sets a function that identifies the user and session of
+// each request. The user ID is added as a "user:<id>" tag, and the session
+// ID is used as the thread ID, so that a session's requests are grouped
+// into one thread.
+func WithIdentityFunc(fn func(*http.Request) RequestIdentity) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.identify = fn
+	}
+}
+
+// WithSkipPaths skips tracing requests for the given paths, such as health
+// checks.
+func WithSkipPaths(paths ...string) MiddlewareOption {
+	skipped := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		skipped[path] = true
+	}
+	return WithSkipFunc(func(r *http.Request) bool {
+		return skipped[r.URL.Path]
+	})
+}
+
+// WithSkipFunc skips tracing requests for which fn returns true.
+func WithSkipFunc(fn func(*http.Request) bool) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.skip = append(c.skip, fn)
+	}
+}
+
 // TracingMiddleware wraps an http.Handler to automatically create spans for incoming requests.
-func TracingMiddleware(client *opik.Client, traceName string) func(http.Handler) http.Handler {
+//
+// Requests carrying the trace context of an Opik trace continue the
+// caller's trace, as with ContinueTrace; others start a new trace named
+// traceName. A new trace for a request with only a W3C traceparent takes
+// its ID from the W3C trace, as described for
+// opik.ContextWithDistributedTraceHeaders. Traces and
+// spans are named after the ServeMux pattern that matched the request, such
+// as "GET /items/{id}", falling back to the path for other routers. An
+// empty traceName names new traces after the pattern as well.
+func TracingMiddleware(client *opik.Client, traceName string, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	cfg := &middlewareConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Create a trace for this request
-			ctx, trace, err := opik.StartTrace(r.Context(), client, traceName,
-				opik.WithTraceInput(map[string]any{
-					"method":     r.Method,
-					"path":       r.URL.Path,
-					"query":      r.URL.RawQuery,
-					"host":       r.Host,
-					"user_agent": r.UserAgent(),
-				}),
-			)
-			if err != nil {
-				// If tracing fails, continue without it
+			if cfg.skipped(r) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Wrap the response writer to capture status code
-			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-
-			// Create a span for the handler
-			ctx, span, err := opik.StartSpan(ctx, r.URL.Path,
-				opik.WithSpanType(opik.SpanTypeGeneral),
-				opik.WithSpanInput(map[string]any{
-					"method": r.Method,
-					"path":   r.URL.Path,
-				}),
-			)
+			route := routeName(r)
+			body := cfg.captureRequestBody(r)
+			ctx, trace, span, err := cfg.startRequest(client, r, traceName, route, body)
 			if err != nil {
-				_ = trace.End(ctx)
+				// If tracing fails, continue without it
 				next.ServeHTTP(w, r)
 				return
 			}
 
+			// Wrap the response writer to capture status code
+			wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK, bodyLimit: cfg.bodyLimit}
+			req := r.WithContext(ctx)
+			end := func(statusCode int, handlerErr error) {
+				output := map[string]any{
+					"status_code": statusCode,
+				}
+				if cfg.bodyLimit > 0 {
+					output["body"] = capturedBody(wrapped.body.Bytes(), wrapped.truncated)
+				}
+
+				// ServeMux sets the pattern once it has routed the request
+				var spanName, renamedTrace string
+				if matched := routeName(req); matched != route {
+					spanName = matched
+					if traceName == "" {
+						renamedTrace = matched
+					}
+				}
+				endRequest(ctx, trace, span, output, handlerErr, spanName, renamedTrace)
+			}
+
+			// Record handler panics before letting them propagate
+			defer func() {
+				if rec := recover(); rec != nil {
+					var panicErr error
+					if rec != http.ErrAbortHandler {
+						panicErr = opik.NewPanicError(rec)
+					}
+					end(http.StatusInternalServerError, panicErr)
+					panic(rec)
+				}
+			}()
+
 			// Call the next handler
-			next.ServeHTTP(wrapped, r.WithContext(ctx))
-
-			// End span with response info
-			_ = span.End(ctx,
-				opik.WithSpanOutput(map[string]any{
-					"status_code": wrapped.statusCode,
-				}),
-			)
-
-			// End trace with response info
-			_ = trace.End(ctx,
-				opik.WithTraceOutput(map[string]any{
-					"status_code": wrapped.statusCode,
-				}),
-			)
+			next.ServeHTTP(wrapped, req)
+
+			var handlerErr error
+			if wrapped.statusCode >= 500 {
+				handlerErr = StatusError{StatusCode: wrapped.statusCode}
+			}
+			end(wrapped.statusCode, handlerErr)
 		})
 	}
 }
 
+// startRequest starts the trace and span for a request. The trace is nil
+// when the request continues another service's trace.
+func (c *middlewareConfig) startRequest(client *opik.Client, r *http.Request, traceName, route string, body any) (context.Context, *opik.Trace, *opik.Span, error) {
+	identity := c.identity(r)
+	var tags []string
+	if identity.UserID != "" {
+		tags = append(tags, "user:"+identity.UserID)
+	}
+
+	spanInput := map[string]any{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	}
+	if body != nil {
+		spanInput["body"] = body
+	}
+	spanOpts := []opik.SpanOption{
+		opik.WithSpanType(opik.SpanTypeGeneral),
+		opik.WithSpanInput(spanInput),
+	}
+
+	headers := opik.ExtractDistributedTraceHeaders(r)
+	if headers.ParentSpanID == "" && r.Header.Get(opik.HeaderTraceID) != "" {
+		// Requests from services using an earlier InjectTraceHeaders
+		headers.ParentSpanID = r.Header.Get(legacySpanIDHeader)
+	}
+	if headers.HasOpikTrace() {
+		// The thread belongs to the caller's trace, so only the user tag
+		// is recorded
+		if len(tags) > 0 {
+			spanOpts = append(spanOpts, opik.WithSpanTags(tags...))
+		}
+		ctx, span, err := client.ContinueTrace(r.Context(), headers, route, spanOpts...)
+		return ctx, nil, span, err
+	}
+
+	// Create a trace for this request
+	input := map[string]any{
+		"method":     r.Method,
+		"path":       r.URL.Path,
+		"query":      r.URL.RawQuery,
+		"host":       r.Host,
+		"user_agent": r.UserAgent(),
+	}
+	if body != nil {
+		input["body"] = body
+	}
+	traceOpts := []opik.TraceOption{opik.WithTraceInput(input)}
+	if len(tags) > 0 {
+		traceOpts = append(traceOpts, opik.WithTraceTags(tags...))
+	}
+	if identity.SessionID != "" {
+		traceOpts = append(traceOpts, opik.WithTraceThreadID(identity.SessionID))
+	}
+	if traceName == "" {
+		traceName = route
+	}
+	// A traceparent from a service or proxy that only uses OpenTelemetry is
+	// kept, so that the trace takes its ID and outgoing requests continue it
+	ctx := opik.ContextWithDistributedTraceHeaders(r.Context(), headers)
+	ctx, trace, err := opik.StartTrace(ctx, client, traceName, traceOpts...)
+	if err != nil {
+		return ctx, nil, nil, err
+	}
+
+	// Create a span for the handler
+	ctx, span, err := opik.StartSpan(ctx, route, spanOpts...)
+	if err != nil {
+		_ = trace.End(ctx)
+		return ctx, nil, nil, err
+	}
+	return ctx, trace, span, nil
+}
+
+func (c *middlewareConfig) skipped(r *http.Request) bool {
+	for _, skip := range c.skip {
+		if skip(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *middlewareConfig) identity(r *http.Request) RequestIdentity {
+	if c.identify == nil {
+		return RequestIdentity{}
+	}
+	return c.identify(r)
+}
+
+// captureRequestBody returns up to the body limit of r's body, or nil if
+// bodies are not captured. r's body is replaced so that the handler still
+// reads all of it.
+func (c *middlewareConfig) captureRequestBody(r *http.Request) any {
+	if c.bodyLimit <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, int64(c.bodyLimit)+1))
+	r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+	if err != nil && len(buf) == 0 {
+		return nil
+	}
+	truncated := len(buf) > c.bodyLimit
+	if truncated {
+		buf = buf[:c.bodyLimit]
+	}
+	return capturedBody(buf, truncated)
+}
+
+// capturedBody returns a captured body for recording. Complete JSON bodies
+// are decoded, so that they display as objects.
+func capturedBody(body []byte, truncated bool) any {
+	if truncated {
+		return string(body) + "..."
+	}
+	var v any
+	if json.Valid(body) && json.Unmarshal(body, &v) == nil {
+		return v
+	}
+	return string(body)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// routeName returns the name of the route r matched: its ServeMux pattern
+// with the method, or its path if it has no pattern.
+func routeName(r *http.Request) string {
+	if r.Pattern == "" {
+		return r.URL.Path
+	}
+	if strings.Contains(r.Pattern, " ") {
+		return r.Pattern
+	}
+	return r.Method + " " + r.Pattern
+}
+
+// StatusError is recorded on request traces and spans when a handler
+// responds with a 5xx status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
+
+// endRequest ends the request span and trace with output, recording err on
+// both if it is non-nil. The trace is nil when the request continued another
+// service's trace. Non-empty names rename the span and trace.
+func endRequest(ctx context.Context, trace *opik.Trace, span *opik.Span, output map[string]any, err error, spanName, traceName string) {
+	spanOpts := []opik.SpanOption{opik.WithSpanOutput(output)}
+	traceOpts := []opik.TraceOption{opik.WithTraceOutput(output)}
+	if err != nil {
+		spanOpts = append(spanOpts, opik.WithSpanError(err))
+		traceOpts = append(traceOpts, opik.WithTraceError(err))
+	}
+	if spanName != "" {
+		spanOpts = append(spanOpts, opik.WithSpanName(spanName))
+	}
+	if traceName != "" {
+		traceOpts = append(traceOpts, opik.WithTraceName(traceName))
+	}
+
+	_ = span.End(ctx, spanOpts...)
+	if trace != nil {
+		_ = trace.End(ctx, traceOpts...)
+	}
+}
+
 // TracingRoundTripper wraps an http.RoundTripper to automatically create spans for outgoing requests.
 type TracingRoundTripper struct {
 	transport http.RoundTripper
@@ -123,9 +372,9 @@ func (t *TracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 	if err != nil {
 		_ = span.End(ctx,
 			opik.WithSpanOutput(map[string]any{
-				"error":    err.Error(),
 				"duration": duration.String(),
 			}),
+			opik.WithSpanError(err),
 		)
 	} else {
 		_ = span.End(ctx,
@@ -141,10 +390,15 @@ func (t *TracingRoundTripper) RoundTrip(req *http.Request) (*http.Response, erro
 	return resp, err
 }
 
-// responseWriter wraps http.ResponseWriter to capture the status code.
+// responseWriter wraps http.ResponseWriter to capture the status code, and
+// the body up to bodyLimit bytes.
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+
+	bodyLimit int
+	body      bytes.Buffer
+	truncated bool
 }
 
 func (w *responseWriter) WriteHeader(code int) {
@@ -152,6 +406,21 @@ func (w *responseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if room := w.bodyLimit - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(room, len(b))])
+		w.truncated = w.truncated || len(b) > room
+	} else if w.bodyLimit > 0 && len(b) > 0 {
+		w.truncated = true
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 // TracingHTTPClient returns an http.Client with tracing enabled.
 func TracingHTTPClient(spanName string) *http.Client {
 	return &http.Client{
@@ -159,25 +428,29 @@ func TracingHTTPClient(spanName string) *http.Client {
 	}
 }
 
+// legacySpanIDHeader is the parent span header sent by earlier versions of
+// InjectTraceHeaders.
+const legacySpanIDHeader = "X-Opik-Span-ID"
+
 // InjectTraceHeaders adds trace context headers to an HTTP request.
-// This can be used for distributed tracing across services.
+// This can be used for distributed tracing across services. It is the same
+// as opik.InjectDistributedTraceHeaders, so the receiving service can use
+// TracingMiddleware or opik.ExtractDistributedTraceHeaders.
 func InjectTraceHeaders(ctx context.Context, req *http.Request) {
-	traceID := opik.CurrentTraceID(ctx)
-	spanID := opik.CurrentSpanID(ctx)
-
-	if traceID != "" {
-		req.Header.Set("X-Opik-Trace-ID", traceID)
-	}
-	if spanID != "" {
-		req.Header.Set("X-Opik-Span-ID", spanID)
-	}
+	opik.InjectDistributedTraceHeaders(ctx, req)
 }
 
 // ExtractTraceContext extracts trace context from HTTP request headers.
-// Returns the trace ID and span ID if present.
+// Returns the trace ID and parent span ID if present.
+//
+// Deprecated: Use opik.ExtractDistributedTraceHeaders, which also reads the
+// W3C trace context headers.
 func ExtractTraceContext(r *http.Request) (traceID, spanID string) {
-	traceID = r.Header.Get("X-Opik-Trace-ID")
-	spanID = r.Header.Get("X-Opik-Span-ID")
+	traceID = r.Header.Get(opik.HeaderTraceID)
+	spanID = r.Header.Get(legacySpanIDHeader)
+	if spanID == "" {
+		spanID = r.Header.Get(opik.HeaderParentSpanID)
+	}
 	return
 }
 
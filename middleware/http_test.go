@@ -1,10 +1,19 @@
 package middleware
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
+
+	opik "github.com/agentplexus/go-opik"
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
 )
 
 func TestResponseWriter(t *testing.T) {
@@ -258,3 +267,282 @@ func TestRequestMetadataContentLength(t *testing.T) {
 		t.Errorf("content_length = %v, want 100", metadata["content_length"])
 	}
 }
+
+// newTestOpikClient creates a client that logs to ms, which is usually a
+// tracetest.NewServer.
+func newTestOpikClient(t *testing.T, ms *testutil.MockServer) *opik.Client {
+	t.Helper()
+
+	client, err := opik.NewClient(
+		opik.WithURL(ms.URL()),
+		opik.WithAPIKey("test-key"),
+		opik.WithProjectName("middleware-project"),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	return client
+}
+
+// lastErrorType returns the exception type sent with the last of updates.
+func lastErrorType(t *testing.T, updates []map[string]any) string {
+	t.Helper()
+
+	info, _ := lastUpdate(t, updates)["error_info"].(map[string]any)
+	exceptionType, _ := info["exception_type"].(string)
+	return exceptionType
+}
+
+func TestTracingMiddlewareRecordsServerErrors(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	tests := []struct {
+		status   int
+		wantType string
+	}{
+		{http.StatusOK, ""},
+		{http.StatusNotFound, ""},
+		{http.StatusBadGateway, "middleware.StatusError"},
+	}
+
+	for _, tt := range tests {
+		handler := TracingMiddleware(client, "request")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+
+		if got := lastErrorType(t, tracetest.SpanUpdates(ms)); got != tt.wantType {
+			t.Errorf("status %d: span exception type = %q, want %q", tt.status, got, tt.wantType)
+		}
+		if got := lastErrorType(t, tracetest.TraceUpdates(ms)); got != tt.wantType {
+			t.Errorf("status %d: trace exception type = %q, want %q", tt.status, got, tt.wantType)
+		}
+	}
+}
+
+func TestTracingMiddlewareRecordsPanics(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	handler := TracingMiddleware(client, "request")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler exploded")
+	}))
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != "handler exploded" {
+				t.Errorf("recovered %v, want the original panic value", rec)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items", nil))
+	}()
+
+	if got := lastErrorType(t, tracetest.SpanUpdates(ms)); got != "panic" {
+		t.Errorf("span exception type = %q, want %q", got, "panic")
+	}
+	if got := lastErrorType(t, tracetest.TraceUpdates(ms)); got != "panic" {
+		t.Errorf("trace exception type = %q, want %q", got, "panic")
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := StatusError{StatusCode: http.StatusServiceUnavailable}
+	if err.Error() != "503 Service Unavailable" {
+		t.Errorf("Error() = %q, want %q", err.Error(), "503 Service Unavailable")
+	}
+}
+
+// lastUpdate returns the last of the updates recorded by a tracing server.
+func lastUpdate(t *testing.T, updates []map[string]any) map[string]any {
+	t.Helper()
+
+	if len(updates) == 0 {
+		t.Fatal("no update sent")
+	}
+	return updates[len(updates)-1]
+}
+
+func TestTracingMiddlewareContinuesTraces(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+	traceID := uuid.Must(uuid.NewV7()).String()
+	parentSpanID := uuid.Must(uuid.NewV7()).String()
+
+	var gotTraceID string
+	handler := TracingMiddleware(client, "request")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceID = opik.CurrentTraceID(r.Context())
+	}))
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set(opik.HeaderTraceID, traceID)
+	req.Header.Set(opik.HeaderParentSpanID, parentSpanID)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if gotTraceID != traceID {
+		t.Errorf("handler trace ID = %q, want %q", gotTraceID, traceID)
+	}
+	if n := len(tracetest.TraceWrites(ms)) + len(tracetest.TraceUpdates(ms)); n != 0 {
+		t.Errorf("sent %d trace requests, want none for a continued trace", n)
+	}
+	update := lastUpdate(t, tracetest.SpanUpdates(ms))
+	if update["trace_id"] != traceID || update["parent_span_id"] != parentSpanID {
+		t.Errorf("span trace_id = %v, parent_span_id = %v", update["trace_id"], update["parent_span_id"])
+	}
+}
+
+func TestTracingMiddlewareStartsTracesForTraceParent(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	var gotTraceID string
+	var outgoing opik.DistributedTraceHeaders
+	handler := TracingMiddleware(client, "request")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceID = opik.CurrentTraceID(r.Context())
+		outgoing = opik.GetDistributedTraceHeaders(r.Context())
+	}))
+	// A request forwarded by a proxy that only uses OpenTelemetry
+	w3cTraceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/items", nil)
+	req.Header.Set(opik.HeaderTraceParent, "00-"+w3cTraceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	traces := tracetest.TraceWrites(ms)
+	if len(traces) != 1 || traces[0]["id"] != gotTraceID {
+		t.Fatalf("traces = %v, want the handler's trace %s", traces, gotTraceID)
+	}
+	if gotTraceID != "4bf92f35-77b3-7da6-a3ce-929d0e0e4736" {
+		t.Errorf("trace ID = %q, want the one derived from traceparent", gotTraceID)
+	}
+	if update := lastUpdate(t, tracetest.TraceUpdates(ms)); update["end_time"] == nil {
+		t.Errorf("trace update = %v, want the trace ended", update)
+	}
+	if !strings.HasPrefix(outgoing.TraceParent, "00-"+w3cTraceID+"-") {
+		t.Errorf("outgoing traceparent = %q, want the W3C trace continued", outgoing.TraceParent)
+	}
+}
+
+func TestInjectTraceHeadersToMiddleware(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	var gotTraceID, gotParentSpanID string
+	server := httptest.NewServer(TracingMiddleware(client, "request")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTraceID = opik.CurrentTraceID(r.Context())
+		if span := opik.SpanFromContext(r.Context()); span != nil {
+			gotParentSpanID = span.ParentSpanID()
+		}
+	})))
+	defer server.Close()
+
+	ctx, trace, err := opik.StartTrace(context.Background(), client, "caller")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+	ctx, span, err := opik.StartSpan(ctx, "call")
+	if err != nil {
+		t.Fatalf("StartSpan error: %v", err)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	InjectTraceHeaders(ctx, req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotTraceID != trace.ID() || gotParentSpanID != span.ID() {
+		t.Errorf("handler span in %s under %s, want %s under %s", gotTraceID, gotParentSpanID, trace.ID(), span.ID())
+	}
+
+	// Requests from services using the earlier header are continued too
+	req, _ = http.NewRequest("GET", server.URL, nil)
+	req.Header.Set(opik.HeaderTraceID, trace.ID())
+	req.Header.Set(legacySpanIDHeader, span.ID())
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request error: %v", err)
+	}
+	resp.Body.Close()
+	if gotParentSpanID != span.ID() {
+		t.Errorf("legacy header parent = %q, want %q", gotParentSpanID, span.ID())
+	}
+}
+
+func TestTracingMiddlewareNamesFromPatterns(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	handler := TracingMiddleware(client, "")(mux)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/42", nil))
+
+	if name := lastUpdate(t, tracetest.TraceUpdates(ms))["name"]; name != "GET /items/{id}" {
+		t.Errorf("trace name = %v, want %q", name, "GET /items/{id}")
+	}
+	if name := lastUpdate(t, tracetest.SpanUpdates(ms))["name"]; name != "GET /items/{id}" {
+		t.Errorf("span name = %v, want %q", name, "GET /items/{id}")
+	}
+}
+
+func TestTracingMiddlewareOptions(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestOpikClient(t, ms)
+
+	var received string
+	handler := TracingMiddleware(client, "request",
+		WithBodyCapture(16),
+		WithSkipPaths("/healthz"),
+		WithIdentityFunc(func(r *http.Request) RequestIdentity {
+			return RequestIdentity{UserID: r.Header.Get("X-User"), SessionID: r.Header.Get("X-Session")}
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		_, _ = w.Write([]byte(`{"result":"a long response body"}`))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthz", nil))
+	if n := len(ms.Requests()); n != 0 {
+		t.Fatalf("sent %d requests for a skipped path", n)
+	}
+
+	req := httptest.NewRequest("POST", "/chat", strings.NewReader(`{"q":"hi"}`))
+	req.Header.Set("X-User", "alice")
+	req.Header.Set("X-Session", "session-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != `{"q":"hi"}` {
+		t.Errorf("handler read %q, want the full body", received)
+	}
+	update := lastUpdate(t, tracetest.TraceUpdates(ms))
+	if update["thread_id"] != "session-1" {
+		t.Errorf("thread_id = %v, want %q", update["thread_id"], "session-1")
+	}
+	if tags, _ := update["tags"].([]any); len(tags) != 1 || tags[0] != "user:alice" {
+		t.Errorf("tags = %v, want [user:alice]", update["tags"])
+	}
+	input, _ := update["input"].(map[string]any)
+	if body, _ := input["body"].(map[string]any); body["q"] != "hi" {
+		t.Errorf("input body = %v", input["body"])
+	}
+	output, _ := update["output"].(map[string]any)
+	if output["body"] != `{"result":"a lon...` {
+		t.Errorf("output body = %v", output["body"])
+	}
+}
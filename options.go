@@ -3,6 +3,9 @@ package opik
 import (
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Option is a functional option for configuring the Client.
@@ -13,12 +16,28 @@ type clientOptions struct {
 	config     *Config
 	httpClient *http.Client
 	timeout    time.Duration
+	pricing    *PricingRegistry
+	sampler    Sampler
+	redactor   Redactor
+	extractor  *AttachmentExtractor
+	retry      RetryPolicy
+	breaker    *CircuitBreaker
+	meters     metric.MeterProvider
+	tracers    trace.TracerProvider
+
+	feedbackDefs *feedbackDefinitions
+	deferLimits  deferLimits
 }
 
 func defaultClientOptions() *clientOptions {
 	return &clientOptions{
-		config:  LoadConfig(),
-		timeout: 60 * time.Second,
+		config:    LoadConfig(),
+		timeout:   60 * time.Second,
+		extractor: NewAttachmentExtractor(),
+		deferLimits: deferLimits{
+			maxSpans: DefaultMaxDeferredSpans,
+			timeout:  DefaultDeferredTraceTimeout,
+		},
 	}
 }
 
@@ -71,6 +90,45 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithRetryPolicy sets how failed API requests are retried, for example
+// DefaultRetryPolicy. By default requests are sent once.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *clientOptions) {
+		o.retry = policy
+	}
+}
+
+// WithCircuitBreaker sets the circuit breaker that fails requests fast
+// while the server is down. By default there is none.
+func WithCircuitBreaker(breaker *CircuitBreaker) Option {
+	return func(o *clientOptions) {
+		o.breaker = breaker
+	}
+}
+
+// WithMeterProvider publishes metrics about the SDK itself, such as the
+// batcher's queue depth, items sent and dropped, and failed API requests,
+// with the given meter provider. By default no metrics are recorded.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *clientOptions) {
+		o.meters = provider
+	}
+}
+
+// WithTracerProvider mirrors every trace and span as an OpenTelemetry span
+// created with the given tracer provider, so that the same operations show up
+// in both Opik and an OpenTelemetry backend. LLM and tool spans carry GenAI
+// semantic convention attributes, and the contexts returned by StartTrace,
+// StartSpan and ContinueTrace hold the OpenTelemetry span, so that
+// OpenTelemetry instrumentation below them joins the same tree. Inputs and
+// outputs are only sent to Opik. By default no OpenTelemetry spans are
+// created.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *clientOptions) {
+		o.tracers = provider
+	}
+}
+
 // WithTracingDisabled disables tracing.
 func WithTracingDisabled(disabled bool) Option {
 	return func(o *clientOptions) {
@@ -78,16 +136,75 @@ func WithTracingDisabled(disabled bool) Option {
 	}
 }
 
+// WithPricingRegistry sets a pricing registry used to estimate the cost of
+// spans that report token usage but no cost.
+func WithPricingRegistry(registry *PricingRegistry) Option {
+	return func(o *clientOptions) {
+		o.pricing = registry
+	}
+}
+
+// WithSampler sets the sampler that decides which traces are exported.
+// By default every trace is exported.
+func WithSampler(sampler Sampler) Option {
+	return func(o *clientOptions) {
+		o.sampler = sampler
+	}
+}
+
+// WithTailSamplingLimits bounds the memory held by traces a tail sampler
+// defers. Spans beyond maxSpans per trace are dropped, and a trace still open
+// after timeout is decided as if it had ended, the next time it or one of
+// its spans is sent. Zero disables a limit. The defaults are
+// DefaultMaxDeferredSpans and DefaultDeferredTraceTimeout.
+func WithTailSamplingLimits(maxSpans int, timeout time.Duration) Option {
+	return func(o *clientOptions) {
+		o.deferLimits = deferLimits{maxSpans: maxSpans, timeout: timeout}
+	}
+}
+
+// WithRedactor sets a redactor that scrubs the input, output, metadata and
+// tags of traces and spans, and feedback score reasons, before they are sent.
+func WithRedactor(redactor Redactor) Option {
+	return func(o *clientOptions) {
+		o.redactor = redactor
+	}
+}
+
+// WithAttachmentExtractor sets the extractor that moves inline base64 media
+// out of trace and span inputs and outputs into attachments. By default
+// media of at least DefaultExtractionMinSize is extracted; pass nil to
+// disable extraction.
+func WithAttachmentExtractor(extractor *AttachmentExtractor) Option {
+	return func(o *clientOptions) {
+		o.extractor = extractor
+	}
+}
+
+// WithFeedbackDefinitions checks feedback scores against the given
+// definitions before they are sent. Scores outside a definition's range or
+// categories fail with a FeedbackScoreError; scores without a definition are
+// sent as is. See also Client.LoadFeedbackDefinitions and
+// Client.SyncFeedbackDefinitions.
+func WithFeedbackDefinitions(defs ...*FeedbackDefinition) Option {
+	return func(o *clientOptions) {
+		o.feedbackDefs = newFeedbackDefinitions(defs...)
+	}
+}
+
 // TraceOption is a functional option for configuring a Trace.
 type TraceOption func(*traceOptions)
 
 type traceOptions struct {
 	projectName string
+	name        string
 	input       any
 	output      any
 	metadata    map[string]any
 	tags        []string
 	threadID    string
+	errorInfo   *ErrorInfo
+	attachments []*Attachment
 }
 
 func defaultTraceOptions() *traceOptions {
@@ -104,6 +221,14 @@ func WithTraceProject(projectName string) TraceOption {
 	}
 }
 
+// WithTraceName renames the trace when passed to End or Update, for names
+// that are only known once the work is done.
+func WithTraceName(name string) TraceOption {
+	return func(o *traceOptions) {
+		o.name = name
+	}
+}
+
 // WithTraceInput sets the input for the trace.
 func WithTraceInput(input any) TraceOption {
 	return func(o *traceOptions) {
@@ -139,17 +264,31 @@ func WithTraceThreadID(threadID string) TraceOption {
 	}
 }
 
+// WithTraceError records err on the trace, marking it as failed.
+// The stack trace is captured when the option is created.
+func WithTraceError(err error) TraceOption {
+	info := NewErrorInfo(err)
+	return func(o *traceOptions) {
+		o.errorInfo = info
+	}
+}
+
 // SpanOption is a functional option for configuring a Span.
 type SpanOption func(*spanOptions)
 
 type spanOptions struct {
-	spanType string
-	input    any
-	output   any
-	metadata map[string]any
-	tags     []string
-	model    string
-	provider string
+	name        string
+	spanType    string
+	input       any
+	output      any
+	metadata    map[string]any
+	tags        []string
+	model       string
+	provider    string
+	usage       map[string]int
+	cost        *float64
+	errorInfo   *ErrorInfo
+	attachments []*Attachment
 }
 
 func defaultSpanOptions() *spanOptions {
@@ -167,6 +306,14 @@ func WithSpanType(spanType string) SpanOption {
 	}
 }
 
+// WithSpanName renames the span when passed to End or Update, for names
+// that are only known once the work is done.
+func WithSpanName(name string) SpanOption {
+	return func(o *spanOptions) {
+		o.name = name
+	}
+}
+
 // WithSpanInput sets the input for the span.
 func WithSpanInput(input any) SpanOption {
 	return func(o *spanOptions) {
@@ -209,6 +356,31 @@ func WithSpanProvider(provider string) SpanOption {
 	}
 }
 
+// WithSpanUsage sets the token usage for LLM spans.
+// See the Usage* constants for the recognized keys.
+func WithSpanUsage(usage map[string]int) SpanOption {
+	return func(o *spanOptions) {
+		o.usage = usage
+	}
+}
+
+// WithSpanTotalCost sets the estimated cost of the span in USD.
+// It takes precedence over the client's pricing registry.
+func WithSpanTotalCost(cost float64) SpanOption {
+	return func(o *spanOptions) {
+		o.cost = &cost
+	}
+}
+
+// WithSpanError records err on the span, marking it as failed.
+// The stack trace is captured when the option is created.
+func WithSpanError(err error) SpanOption {
+	info := NewErrorInfo(err)
+	return func(o *spanOptions) {
+		o.errorInfo = info
+	}
+}
+
 // SpanTypeLLM is the span type for LLM calls.
 const SpanTypeLLM = "llm"
 
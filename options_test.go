@@ -157,6 +157,15 @@ func TestWithTraceProject(t *testing.T) {
 	}
 }
 
+func TestWithTraceName(t *testing.T) {
+	opts := defaultTraceOptions()
+	WithTraceName("GET /items/{id}")(opts)
+
+	if opts.name != "GET /items/{id}" {
+		t.Errorf("name = %q, want %q", opts.name, "GET /items/{id}")
+	}
+}
+
 func TestWithTraceInput(t *testing.T) {
 	opts := defaultTraceOptions()
 	input := map[string]any{"prompt": "hello"}
@@ -297,6 +306,15 @@ func TestWithSpanModel(t *testing.T) {
 	}
 }
 
+func TestWithSpanName(t *testing.T) {
+	opts := defaultSpanOptions()
+	WithSpanName("GET /items/{id}")(opts)
+
+	if opts.name != "GET /items/{id}" {
+		t.Errorf("name = %q, want %q", opts.name, "GET /items/{id}")
+	}
+}
+
 func TestWithSpanProvider(t *testing.T) {
 	opts := defaultSpanOptions()
 	WithSpanProvider("openai")(opts)
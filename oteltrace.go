@@ -0,0 +1,163 @@
+package opik
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the OpenTelemetry spans that
+// mirror Opik traces and spans.
+const tracerName = "github.com/agentplexus/go-opik"
+
+// Attributes linking OpenTelemetry spans to the Opik traces and spans they
+// mirror.
+const (
+	attrOpikTraceID     = attribute.Key("opik.trace_id")
+	attrOpikSpanID      = attribute.Key("opik.span_id")
+	attrOpikProjectName = attribute.Key("opik.project_name")
+	attrOpikSpanType    = attribute.Key("opik.span_type")
+	attrOpikTags        = attribute.Key("opik.tags")
+)
+
+// startOTelSpan starts the OpenTelemetry span mirroring an Opik trace or
+// span, if the client has a tracer. The parent is the OpenTelemetry span in
+// ctx. It returns the span and a copy of metadata with the span's trace and
+// span IDs added, so that it can be found from Opik.
+func (c *Client) startOTelSpan(ctx context.Context, name string, start time.Time, kind trace.SpanKind, metadata map[string]any) (trace.Span, map[string]any) {
+	if c.tracer == nil {
+		return nil, metadata
+	}
+	_, span := c.tracer.Start(ctx, name, trace.WithTimestamp(start), trace.WithSpanKind(kind))
+	if sc := span.SpanContext(); sc.IsValid() {
+		metadata = maps.Clone(metadata)
+		if metadata == nil {
+			metadata = make(map[string]any, 2)
+		}
+		metadata["otel.trace_id"] = sc.TraceID().String()
+		metadata["otel.span_id"] = sc.SpanID().String()
+	}
+	return span, metadata
+}
+
+// withOTelSpan returns ctx with span as the current OpenTelemetry span, or
+// ctx itself if span is nil.
+func withOTelSpan(ctx context.Context, span trace.Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, span)
+}
+
+// abortOTelSpan ends span as failed with err, for an Opik trace or span that
+// could not be created. span may be nil.
+func abortOTelSpan(span trace.Span, err error) {
+	if span == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, firstLine(err.Error()))
+	span.End()
+}
+
+// endOTelSpan sets the attributes of the OpenTelemetry span mirroring t and
+// ends it.
+func (t *Trace) endOTelSpan() {
+	span := t.otelSpan
+	if span == nil {
+		return
+	}
+	span.SetName(t.name)
+	span.SetAttributes(
+		attrOpikTraceID.String(t.id),
+		attrOpikProjectName.String(t.projectName),
+	)
+	if t.threadID != "" {
+		span.SetAttributes(attribute.String(attrGenAIConversationID, t.threadID))
+	}
+	if len(t.tags) > 0 {
+		span.SetAttributes(attrOpikTags.StringSlice(t.tags))
+	}
+	recordOTelError(span, t.errorInfo)
+	span.End(trace.WithTimestamp(*t.endTime))
+}
+
+// endOTelSpan sets the attributes of the OpenTelemetry span mirroring s,
+// following the GenAI semantic conventions, and ends it.
+func (s *Span) endOTelSpan() {
+	span := s.otelSpan
+	if span == nil {
+		return
+	}
+	span.SetName(s.name)
+	span.SetAttributes(
+		attrOpikTraceID.String(s.traceID),
+		attrOpikSpanID.String(s.id),
+		attrOpikProjectName.String(s.projectName),
+		attrOpikSpanType.String(s.spanType),
+	)
+	switch s.spanType {
+	case SpanTypeLLM:
+		span.SetAttributes(attribute.String(attrGenAIOperation, "chat"))
+	case SpanTypeTool:
+		span.SetAttributes(
+			attribute.String(attrGenAIOperation, "execute_tool"),
+			attribute.String(attrGenAIToolName, s.name),
+		)
+	}
+	if s.model != "" {
+		span.SetAttributes(attribute.String(attrGenAIRequestModel, s.model))
+	}
+	if s.provider != "" {
+		span.SetAttributes(attribute.String(attrGenAIProvider, s.provider))
+	}
+	for key, n := range s.usage {
+		switch key {
+		case UsagePromptTokens:
+			key = "input_tokens"
+		case UsageCompletionTokens:
+			key = "output_tokens"
+		}
+		span.SetAttributes(attribute.Int(attrGenAIUsagePrefix+key, n))
+	}
+	if len(s.tags) > 0 {
+		span.SetAttributes(attrOpikTags.StringSlice(s.tags))
+	}
+	recordOTelError(span, s.errorInfo)
+	span.End(trace.WithTimestamp(*s.endTime))
+}
+
+// recordOTelError marks span as failed with info, if info is not nil.
+func recordOTelError(span trace.Span, info *ErrorInfo) {
+	if info == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String(attrExceptionType, info.ExceptionType),
+		attribute.String(attrExceptionMsg, info.Message),
+	}
+	if info.Traceback != "" {
+		attrs = append(attrs, attribute.String(attrExceptionStack, info.Traceback))
+	}
+	span.AddEvent(eventException, trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, firstLine(info.Message))
+}
+
+// otelSpanKind returns the OpenTelemetry span kind of an Opik span type.
+// LLM calls are requests to a model provider.
+func otelSpanKind(spanType string) trace.SpanKind {
+	if spanType == SpanTypeLLM {
+		return trace.SpanKindClient
+	}
+	return trace.SpanKindInternal
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,194 @@
+package opik
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	sdktracetest "go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+func newTestOTelClient(t *testing.T, ms *testutil.MockServer) (*Client, *sdktracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := sdktracetest.NewSpanRecorder()
+	client := newTestClient(t, ms, WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+	return client, recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracerProviderMirrorsSpans(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client, recorder := newTestOTelClient(t, ms)
+
+	ctx, tr, err := StartTrace(context.Background(), client, "agent", WithTraceThreadID("thread-1"))
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+	if sc := trace.SpanContextFromContext(ctx); !sc.IsValid() {
+		t.Fatal("StartTrace context has no OpenTelemetry span")
+	}
+
+	llmCtx, llm, err := StartSpan(ctx, "chat", WithSpanType(SpanTypeLLM), WithSpanModel("gpt-4o"), WithSpanProvider("openai"))
+	if err != nil {
+		t.Fatalf("StartSpan error: %v", err)
+	}
+	_, tool, err := StartSpan(llmCtx, "search", WithSpanType(SpanTypeTool))
+	if err != nil {
+		t.Fatalf("StartSpan error: %v", err)
+	}
+	if err := tool.End(ctx, WithSpanError(errors.New("index unavailable"))); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	if err := llm.End(ctx, WithSpanUsage(map[string]int{UsagePromptTokens: 12, UsageCompletionTokens: 5})); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	if err := tr.End(ctx); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+
+	ended := recorder.Ended()
+	if len(ended) != 3 {
+		t.Fatalf("ended %d OpenTelemetry spans, want 3", len(ended))
+	}
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range ended {
+		byName[span.Name()] = span
+	}
+
+	root, chat, search := byName["agent"], byName["chat"], byName["search"]
+	if chat.Parent().SpanID() != root.SpanContext().SpanID() || search.Parent().SpanID() != chat.SpanContext().SpanID() {
+		t.Error("OpenTelemetry spans do not mirror the Opik tree")
+	}
+	if attrs := spanAttributes(root); attrs["opik.trace_id"].AsString() != tr.ID() || attrs["gen_ai.conversation.id"].AsString() != "thread-1" {
+		t.Errorf("trace attributes = %v", attrs)
+	}
+
+	attrs := spanAttributes(chat)
+	if chat.SpanKind() != trace.SpanKindClient || attrs["gen_ai.operation.name"].AsString() != "chat" ||
+		attrs["gen_ai.request.model"].AsString() != "gpt-4o" || attrs["gen_ai.provider.name"].AsString() != "openai" {
+		t.Errorf("llm span kind = %v, attributes = %v", chat.SpanKind(), attrs)
+	}
+	if attrs["gen_ai.usage.input_tokens"].AsInt64() != 12 || attrs["gen_ai.usage.output_tokens"].AsInt64() != 5 {
+		t.Errorf("llm span usage = %v", attrs)
+	}
+	if attrs["opik.span_id"].AsString() != llm.ID() {
+		t.Errorf("opik.span_id = %v, want %s", attrs["opik.span_id"], llm.ID())
+	}
+
+	if search.Status().Code != codes.Error || search.Status().Description != "index unavailable" {
+		t.Errorf("tool span status = %+v", search.Status())
+	}
+	if attrs := spanAttributes(search); attrs["gen_ai.tool.name"].AsString() != "search" {
+		t.Errorf("tool span attributes = %v", attrs)
+	}
+
+	// The Opik span records the OpenTelemetry IDs
+	if got := llm.metadata["otel.span_id"]; got != chat.SpanContext().SpanID().String() {
+		t.Errorf("otel.span_id metadata = %v, want %s", got, chat.SpanContext().SpanID())
+	}
+}
+
+func TestTracerProviderEndsSpansOfFailedSends(t *testing.T) {
+	tests := []struct {
+		name       string
+		failedPath string
+		wantName   string
+	}{
+		{"trace", tracetest.TracesBatchPath, "agent"},
+		{"span", tracetest.SpansBatchPath, "chat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := testutil.NewMockServer()
+			defer ms.Close()
+			ms.OnPost(tracetest.TracesBatchPath).Respond(http.StatusNoContent, nil)
+			ms.OnPost(tracetest.SpansBatchPath).Respond(http.StatusNoContent, nil)
+			ms.OnPost(tt.failedPath).Respond(http.StatusBadRequest, nil)
+
+			client, recorder := newTestOTelClient(t, ms)
+
+			ctx, _, err := StartTrace(context.Background(), client, "agent")
+			if err == nil {
+				_, _, err = StartSpan(ctx, "chat")
+			}
+			if err == nil {
+				t.Fatal("expected a send error")
+			}
+
+			ended := recorder.Ended()
+			if len(ended) != 1 || ended[0].Name() != tt.wantName {
+				t.Fatalf("ended OpenTelemetry spans = %d, want only %q", len(ended), tt.wantName)
+			}
+			if ended[0].Status().Code != codes.Error {
+				t.Errorf("status = %+v, want error", ended[0].Status())
+			}
+			if events := ended[0].Events(); len(events) != 1 || events[0].Name != "exception" {
+				t.Errorf("events = %v, want the recorded error", events)
+			}
+		})
+	}
+}
+
+func TestTracerProviderPropagation(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client, recorder := newTestOTelClient(t, ms)
+
+	ctx, tr, err := StartTrace(context.Background(), client, "agent")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+	ctx, span, err := StartSpan(ctx, "call-downstream")
+	if err != nil {
+		t.Fatalf("StartSpan error: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	InjectDistributedTraceHeaders(ctx, req)
+
+	// traceparent names the OpenTelemetry span, and tracestate the Opik IDs
+	sc := trace.SpanContextFromContext(ctx)
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if got := req.Header.Get(HeaderTraceParent); got != want {
+		t.Errorf("traceparent = %q, want %q", got, want)
+	}
+	req.Header.Del(HeaderTraceID)
+	req.Header.Del(HeaderParentSpanID)
+	headers := ExtractDistributedTraceHeaders(req)
+	if headers.TraceID != tr.ID() || headers.ParentSpanID != span.ID() {
+		t.Errorf("extracted %s/%s, want %s/%s", headers.TraceID, headers.ParentSpanID, tr.ID(), span.ID())
+	}
+
+	// A downstream service continues both trees
+	downCtx, down, err := client.ContinueTrace(context.Background(), headers, "handler")
+	if err != nil {
+		t.Fatalf("ContinueTrace error: %v", err)
+	}
+	if err := down.End(downCtx); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	ended := recorder.Ended()
+	if len(ended) != 1 || ended[0].Parent().SpanID() != sc.SpanID() || ended[0].SpanContext().TraceID() != sc.TraceID() {
+		t.Errorf("downstream OpenTelemetry span does not continue the caller's span")
+	}
+}
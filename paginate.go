@@ -0,0 +1,105 @@
+package opik
+
+import (
+	"context"
+	"iter"
+)
+
+// DefaultPageSize is the page size used by the iterators over list APIs,
+// such as AllTraces and AllDatasets.
+const DefaultPageSize = 100
+
+// PageOption configures an iterator over a list API.
+type PageOption func(*pageOptions)
+
+type pageOptions struct {
+	size     int
+	prefetch bool
+}
+
+// WithPageSize sets the number of items fetched per request.
+// Sizes below 1 use DefaultPageSize.
+func WithPageSize(size int) PageOption {
+	return func(o *pageOptions) {
+		if size >= 1 {
+			o.size = size
+		}
+	}
+}
+
+// WithoutPrefetch fetches each page only when the previous one has been
+// consumed. By default the next page is fetched while the current one is
+// being iterated.
+func WithoutPrefetch() PageOption {
+	return func(o *pageOptions) {
+		o.prefetch = false
+	}
+}
+
+// pageFunc fetches a page of items. Pages are numbered from 1.
+type pageFunc[T any] func(ctx context.Context, page, size int) ([]T, error)
+
+// pageResult is the outcome of fetching a page.
+type pageResult[T any] struct {
+	items []T
+	err   error
+}
+
+// paginate iterates over every item of a paged list API. A page shorter
+// than the page size is the last one. If fetching a page fails, the error
+// is yielded once and iteration stops.
+func paginate[T any](ctx context.Context, fetch pageFunc[T], opts []PageOption) iter.Seq2[T, error] {
+	options := pageOptions{size: DefaultPageSize, prefetch: true}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	size := options.size
+
+	return func(yield func(T, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		start := func(page int) <-chan pageResult[T] {
+			ch := make(chan pageResult[T], 1)
+			go func() {
+				items, err := fetch(ctx, page, size)
+				ch <- pageResult[T]{items: items, err: err}
+			}()
+			return ch
+		}
+
+		next := start(1)
+		for page := 1; ; page++ {
+			r := <-next
+			if r.err != nil {
+				var zero T
+				yield(zero, r.err)
+				return
+			}
+
+			last := len(r.items) < size
+			next = nil
+			if !last && options.prefetch {
+				next = start(page + 1)
+			}
+
+			for _, item := range r.items {
+				if !yield(item, nil) {
+					if next != nil {
+						// Stop the prefetch and wait for it to return
+						cancel()
+						<-next
+					}
+					return
+				}
+			}
+
+			if last {
+				return
+			}
+			if next == nil {
+				next = start(page + 1)
+			}
+		}
+	}
+}
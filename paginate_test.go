@@ -0,0 +1,176 @@
+package opik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+// pagedSource serves total integers in pages and records the pages fetched.
+type pagedSource struct {
+	mu     sync.Mutex
+	total  int
+	failAt int
+	pages  []int
+}
+
+func (s *pagedSource) fetch(ctx context.Context, page, size int) ([]int, error) {
+	s.mu.Lock()
+	s.pages = append(s.pages, page)
+	s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if page == s.failAt {
+		return nil, errors.New("page failed")
+	}
+	var items []int
+	for i := (page - 1) * size; i < page*size && i < s.total; i++ {
+		items = append(items, i)
+	}
+	return items, nil
+}
+
+func (s *pagedSource) fetched() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int(nil), s.pages...)
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name      string
+		total     int
+		size      int
+		wantPages int
+	}{
+		{"empty", 0, 10, 1},
+		{"partial page", 7, 10, 1},
+		{"exact pages", 20, 10, 3},
+		{"several pages", 25, 10, 3},
+	}
+
+	for _, tt := range tests {
+		for _, prefetch := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/prefetch=%v", tt.name, prefetch), func(t *testing.T) {
+				src := &pagedSource{total: tt.total}
+				opts := []PageOption{WithPageSize(tt.size)}
+				if !prefetch {
+					opts = append(opts, WithoutPrefetch())
+				}
+
+				var got []int
+				for item, err := range paginate(context.Background(), src.fetch, opts) {
+					if err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					got = append(got, item)
+				}
+
+				if len(got) != tt.total {
+					t.Fatalf("got %d items, want %d", len(got), tt.total)
+				}
+				for i, item := range got {
+					if item != i {
+						t.Fatalf("item %d = %d", i, item)
+					}
+				}
+				if pages := src.fetched(); len(pages) != tt.wantPages {
+					t.Errorf("fetched pages %v, want %d", pages, tt.wantPages)
+				}
+			})
+		}
+	}
+}
+
+func TestPaginateEarlyBreak(t *testing.T) {
+	src := &pagedSource{total: 1000}
+
+	n := 0
+	for _, err := range paginate(context.Background(), src.fetch, []PageOption{WithPageSize(10)}) {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		n++
+		if n == 15 {
+			break
+		}
+	}
+
+	// Page 2 is being read and page 3 prefetched; nothing after that
+	if pages := src.fetched(); len(pages) > 3 {
+		t.Errorf("fetched pages %v after break", pages)
+	}
+}
+
+func TestPaginateError(t *testing.T) {
+	src := &pagedSource{total: 100, failAt: 2}
+
+	var items, errs int
+	for _, err := range paginate(context.Background(), src.fetch, []PageOption{WithPageSize(10)}) {
+		if err != nil {
+			errs++
+			continue
+		}
+		items++
+	}
+
+	if items != 10 || errs != 1 {
+		t.Errorf("got %d items and %d errors, want 10 and 1", items, errs)
+	}
+}
+
+func TestPaginateDefaultPageSize(t *testing.T) {
+	var sizes []int
+	fetch := func(_ context.Context, _, size int) ([]int, error) {
+		sizes = append(sizes, size)
+		return nil, nil
+	}
+
+	for range paginate(context.Background(), fetch, []PageOption{WithPageSize(0)}) {
+		t.Fatal("unexpected item")
+	}
+	if len(sizes) != 1 || sizes[0] != DefaultPageSize {
+		t.Errorf("sizes = %v, want [%d]", sizes, DefaultPageSize)
+	}
+}
+
+func TestAllProjects(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnGet("/v1/private/projects").WithHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "1":
+			_, _ = w.Write([]byte(`{"content":[{"name":"a"},{"name":"b"}]}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"content":[{"name":"c"}]}`))
+		default:
+			t.Errorf("unexpected page %s", r.URL.Query().Get("page"))
+			_, _ = w.Write([]byte(`{"content":[]}`))
+		}
+	})
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	var names []string
+	for project, err := range client.AllProjects(context.Background(), WithPageSize(2)) {
+		if err != nil {
+			t.Fatalf("AllProjects error: %v", err)
+		}
+		names = append(names, project.Name)
+	}
+	if fmt.Sprint(names) != "[a b c]" {
+		t.Errorf("names = %v", names)
+	}
+}
@@ -0,0 +1,134 @@
+package opik
+
+import (
+	"strings"
+	"sync"
+)
+
+// Usage keys recognized by Opik and by the pricing registry.
+// Cached and reasoning token counts are subsets of the prompt and completion
+// counts respectively, following the OpenAI usage convention.
+const (
+	UsagePromptTokens     = "prompt_tokens"
+	UsageCompletionTokens = "completion_tokens"
+	UsageTotalTokens      = "total_tokens"
+	UsageCachedTokens     = "cached_tokens"
+	UsageReasoningTokens  = "reasoning_tokens"
+)
+
+// ModelPricing holds per-token prices for a model, in USD per million tokens.
+type ModelPricing struct {
+	// InputPerMillion is the price of uncached prompt tokens.
+	InputPerMillion float64
+	// OutputPerMillion is the price of completion tokens.
+	OutputPerMillion float64
+	// CachedInputPerMillion is the price of cached prompt tokens.
+	// Defaults to InputPerMillion when zero.
+	CachedInputPerMillion float64
+	// ReasoningPerMillion is the price of reasoning tokens.
+	// Defaults to OutputPerMillion when zero.
+	ReasoningPerMillion float64
+}
+
+// Cost returns the estimated cost in USD for the given usage.
+func (p ModelPricing) Cost(usage map[string]int) float64 {
+	prompt := usage[UsagePromptTokens]
+	completion := usage[UsageCompletionTokens]
+	cached := min(usage[UsageCachedTokens], prompt)
+	reasoning := min(usage[UsageReasoningTokens], completion)
+
+	cachedRate := p.CachedInputPerMillion
+	if cachedRate == 0 {
+		cachedRate = p.InputPerMillion
+	}
+	reasoningRate := p.ReasoningPerMillion
+	if reasoningRate == 0 {
+		reasoningRate = p.OutputPerMillion
+	}
+
+	cost := float64(prompt-cached)*p.InputPerMillion +
+		float64(cached)*cachedRate +
+		float64(completion-reasoning)*p.OutputPerMillion +
+		float64(reasoning)*reasoningRate
+
+	return cost / 1_000_000
+}
+
+// PricingRegistry maps provider and model names to prices.
+// It is used by the client to fill in the estimated cost of LLM spans that
+// report usage but no cost.
+type PricingRegistry struct {
+	mu      sync.RWMutex
+	prices  map[string]map[string]ModelPricing
+	version string
+}
+
+// NewPricingRegistry creates an empty pricing registry.
+func NewPricingRegistry() *PricingRegistry {
+	return &PricingRegistry{
+		prices: make(map[string]map[string]ModelPricing),
+	}
+}
+
+// Register sets the price for a model. An empty provider matches any provider.
+func (r *PricingRegistry) Register(provider, model string, pricing ModelPricing) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	provider = strings.ToLower(provider)
+	if r.prices[provider] == nil {
+		r.prices[provider] = make(map[string]ModelPricing)
+	}
+	r.prices[provider][strings.ToLower(model)] = pricing
+}
+
+// SetVersion sets the version reported with estimated costs, e.g. "2026-01".
+func (r *PricingRegistry) SetVersion(version string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version = version
+}
+
+// Version returns the pricing version.
+func (r *PricingRegistry) Version() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
+// Lookup returns the pricing for a model. An exact model match is preferred;
+// otherwise the longest registered model name that prefixes the model is used,
+// so "gpt-4o" also matches "gpt-4o-2024-08-06".
+func (r *PricingRegistry) Lookup(provider, model string) (ModelPricing, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	model = strings.ToLower(model)
+	for _, p := range []string{strings.ToLower(provider), ""} {
+		models := r.prices[p]
+		if pricing, ok := models[model]; ok {
+			return pricing, true
+		}
+
+		best := ""
+		for name := range models {
+			if strings.HasPrefix(model, name) && len(name) > len(best) {
+				best = name
+			}
+		}
+		if best != "" {
+			return models[best], true
+		}
+	}
+	return ModelPricing{}, false
+}
+
+// EstimateCost returns the estimated cost in USD for the given usage, and
+// false if the model has no registered price.
+func (r *PricingRegistry) EstimateCost(provider, model string, usage map[string]int) (float64, bool) {
+	pricing, ok := r.Lookup(provider, model)
+	if !ok {
+		return 0, false
+	}
+	return pricing.Cost(usage), true
+}
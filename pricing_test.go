@@ -0,0 +1,127 @@
+package opik
+
+import (
+	"math"
+	"testing"
+)
+
+func floatNear(a, b float64) bool {
+	return math.Abs(a-b) < 1e-12
+}
+
+func TestModelPricingCost(t *testing.T) {
+	pricing := ModelPricing{
+		InputPerMillion:       2.0,
+		OutputPerMillion:      8.0,
+		CachedInputPerMillion: 0.5,
+		ReasoningPerMillion:   10.0,
+	}
+
+	tests := []struct {
+		name  string
+		usage map[string]int
+		want  float64
+	}{
+		{"empty", map[string]int{}, 0},
+		{"prompt and completion", map[string]int{
+			UsagePromptTokens:     1000,
+			UsageCompletionTokens: 500,
+		}, (1000*2.0 + 500*8.0) / 1e6},
+		{"cached tokens", map[string]int{
+			UsagePromptTokens:     1000,
+			UsageCompletionTokens: 0,
+			UsageCachedTokens:     400,
+		}, (600*2.0 + 400*0.5) / 1e6},
+		{"reasoning tokens", map[string]int{
+			UsageCompletionTokens: 500,
+			UsageReasoningTokens:  200,
+		}, (300*8.0 + 200*10.0) / 1e6},
+		{"cached exceeds prompt", map[string]int{
+			UsagePromptTokens: 100,
+			UsageCachedTokens: 500,
+		}, 100 * 0.5 / 1e6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pricing.Cost(tt.usage); !floatNear(got, tt.want) {
+				t.Errorf("Cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelPricingCostDefaults(t *testing.T) {
+	pricing := ModelPricing{InputPerMillion: 1.0, OutputPerMillion: 4.0}
+
+	got := pricing.Cost(map[string]int{
+		UsagePromptTokens:     1000,
+		UsageCachedTokens:     500,
+		UsageCompletionTokens: 1000,
+		UsageReasoningTokens:  500,
+	})
+	want := (1000*1.0 + 1000*4.0) / 1e6
+	if !floatNear(got, want) {
+		t.Errorf("Cost() = %v, want %v", got, want)
+	}
+}
+
+func TestPricingRegistryLookup(t *testing.T) {
+	r := NewPricingRegistry()
+	r.Register("openai", "gpt-4o", ModelPricing{InputPerMillion: 2.5})
+	r.Register("openai", "gpt-4o-mini", ModelPricing{InputPerMillion: 0.15})
+	r.Register("", "claude-sonnet", ModelPricing{InputPerMillion: 3})
+
+	tests := []struct {
+		name     string
+		provider string
+		model    string
+		want     float64
+		found    bool
+	}{
+		{"exact", "openai", "gpt-4o", 2.5, true},
+		{"case insensitive", "OpenAI", "GPT-4o", 2.5, true},
+		{"dated version", "openai", "gpt-4o-2024-08-06", 2.5, true},
+		{"longest prefix", "openai", "gpt-4o-mini-2024-07-18", 0.15, true},
+		{"any provider", "anthropic", "claude-sonnet-4", 3, true},
+		{"unknown model", "openai", "o1", 0, false},
+		{"unknown provider", "mistral", "gpt-4o", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.Lookup(tt.provider, tt.model)
+			if ok != tt.found {
+				t.Fatalf("Lookup() found = %v, want %v", ok, tt.found)
+			}
+			if got.InputPerMillion != tt.want {
+				t.Errorf("InputPerMillion = %v, want %v", got.InputPerMillion, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricingRegistryEstimateCost(t *testing.T) {
+	r := NewPricingRegistry()
+	r.SetVersion("2026-01")
+	r.Register("openai", "gpt-4o", ModelPricing{InputPerMillion: 1, OutputPerMillion: 2})
+
+	if r.Version() != "2026-01" {
+		t.Errorf("Version() = %q, want %q", r.Version(), "2026-01")
+	}
+
+	cost, ok := r.EstimateCost("openai", "gpt-4o", map[string]int{
+		UsagePromptTokens:     1_000_000,
+		UsageCompletionTokens: 1_000_000,
+	})
+	if !ok {
+		t.Fatal("EstimateCost should find registered model")
+	}
+	if !floatNear(cost, 3) {
+		t.Errorf("cost = %v, want 3", cost)
+	}
+
+	if _, ok := r.EstimateCost("openai", "unknown", nil); ok {
+		t.Error("EstimateCost should not find unregistered model")
+	}
+}
@@ -3,6 +3,7 @@ package opik
 import (
 	"context"
 	"fmt"
+	"iter"
 	"regexp"
 	"strings"
 
@@ -280,6 +281,11 @@ func (c *Client) GetPromptByName(ctx context.Context, name string, commit string
 	}
 }
 
+// AllPrompts iterates over all prompts, fetching them page by page.
+func (c *Client) AllPrompts(ctx context.Context, opts ...PageOption) iter.Seq2[*Prompt, error] {
+	return paginate(ctx, c.ListPrompts, opts)
+}
+
 // ListPrompts lists all prompts.
 //
 //nolint:dupl // Similar structure to ListDatasets is intentional for consistency
@@ -335,6 +341,12 @@ func (p *Prompt) Delete(ctx context.Context) error {
 	return p.client.DeletePrompt(ctx, p.id)
 }
 
+// AllVersions iterates over all versions of this prompt, fetching them
+// page by page.
+func (p *Prompt) AllVersions(ctx context.Context, opts ...PageOption) iter.Seq2[*PromptVersion, error] {
+	return paginate(ctx, p.GetVersions, opts)
+}
+
 // GetVersions retrieves all versions of this prompt.
 func (p *Prompt) GetVersions(ctx context.Context, page, size int) ([]*PromptVersion, error) {
 	promptUUID, err := uuid.Parse(p.id)
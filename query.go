@@ -0,0 +1,380 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// FilterOperator compares a trace or span field with a filter value.
+type FilterOperator string
+
+// Filter operators supported by Opik.
+const (
+	OpContains       FilterOperator = "contains"
+	OpNotContains    FilterOperator = "not_contains"
+	OpStartsWith     FilterOperator = "starts_with"
+	OpEndsWith       FilterOperator = "ends_with"
+	OpEqual          FilterOperator = "="
+	OpNotEqual       FilterOperator = "!="
+	OpGreaterThan    FilterOperator = ">"
+	OpGreaterOrEqual FilterOperator = ">="
+	OpLessThan       FilterOperator = "<"
+	OpLessOrEqual    FilterOperator = "<="
+	OpIsEmpty        FilterOperator = "is_empty"
+	OpIsNotEmpty     FilterOperator = "is_not_empty"
+)
+
+// Trace and span fields that can be filtered and sorted on.
+const (
+	FieldID             = "id"
+	FieldName           = "name"
+	FieldStartTime      = "start_time"
+	FieldEndTime        = "end_time"
+	FieldInput          = "input"
+	FieldOutput         = "output"
+	FieldMetadata       = "metadata"
+	FieldTags           = "tags"
+	FieldFeedbackScores = "feedback_scores"
+	FieldDuration       = "duration"
+	FieldTotalCost      = "total_estimated_cost"
+	FieldThreadID       = "thread_id"
+	FieldErrorInfo      = "error_info"
+	FieldModel          = "model"
+	FieldProvider       = "provider"
+)
+
+// SortDirection is the order of a sort field.
+type SortDirection string
+
+// Sort directions.
+const (
+	SortAsc  SortDirection = "ASC"
+	SortDesc SortDirection = "DESC"
+)
+
+// Filter is a single predicate in Opik's filter format. Key selects a
+// metadata field or feedback score name for fields that need one.
+type Filter struct {
+	Field    string         `json:"field"`
+	Operator FilterOperator `json:"operator"`
+	Key      string         `json:"key,omitempty"`
+	Value    string         `json:"value"`
+}
+
+// SortField orders query results by a field.
+type SortField struct {
+	Field     string        `json:"field"`
+	Direction SortDirection `json:"direction"`
+}
+
+// Query selects traces or spans for QueryTraces and QuerySpans. All
+// predicates must match. Build one with NewQuery and its chainable methods:
+//
+//	q := opik.NewQuery().
+//		Tag("checkout").
+//		FeedbackScore("hallucination", opik.OpLessThan, 0.5).
+//		Since(24 * time.Hour)
+//
+// Opik has no free-text search for traces and spans; use Input or Output
+// with OpContains to search their payloads.
+type Query struct {
+	projectName string
+	projectID   string
+	filters     []Filter
+	sorting     []SortField
+	from        time.Time
+	to          time.Time
+	traceID     string
+	spanType    string
+	truncate    bool
+}
+
+// NewQuery creates an empty query, which matches every trace or span in the
+// client's project.
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Project searches the named project instead of the client's project.
+func (q *Query) Project(name string) *Query {
+	q.projectName = name
+	return q
+}
+
+// ProjectID searches the project with the given ID instead of the client's
+// project. It takes precedence over Project.
+func (q *Query) ProjectID(id string) *Query {
+	q.projectID = id
+	return q
+}
+
+// Where adds a filter on a field, e.g. Where(FieldModel, OpEqual, "gpt-4o").
+func (q *Query) Where(field string, op FilterOperator, value any) *Query {
+	return q.WhereKey(field, "", op, value)
+}
+
+// WhereKey adds a filter on a keyed field, such as a metadata field or a
+// feedback score.
+func (q *Query) WhereKey(field, key string, op FilterOperator, value any) *Query {
+	q.filters = append(q.filters, Filter{
+		Field:    field,
+		Operator: op,
+		Key:      key,
+		Value:    filterValue(value),
+	})
+	return q
+}
+
+// Name filters by trace or span name.
+func (q *Query) Name(op FilterOperator, name string) *Query {
+	return q.Where(FieldName, op, name)
+}
+
+// Tag matches traces or spans that have the tag.
+func (q *Query) Tag(tag string) *Query {
+	return q.Where(FieldTags, OpContains, tag)
+}
+
+// Metadata filters by the metadata field key.
+func (q *Query) Metadata(key string, op FilterOperator, value any) *Query {
+	return q.WhereKey(FieldMetadata, key, op, value)
+}
+
+// FeedbackScore filters by the value of the named feedback score.
+func (q *Query) FeedbackScore(name string, op FilterOperator, value float64) *Query {
+	return q.WhereKey(FieldFeedbackScores, name, op, value)
+}
+
+// Duration filters by the time between start and end.
+func (q *Query) Duration(op FilterOperator, d time.Duration) *Query {
+	return q.Where(FieldDuration, op, float64(d)/float64(time.Millisecond))
+}
+
+// Cost filters by the total estimated cost in USD.
+func (q *Query) Cost(op FilterOperator, usd float64) *Query {
+	return q.Where(FieldTotalCost, op, usd)
+}
+
+// Input filters by the JSON input, e.g. Input(OpContains, "refund").
+func (q *Query) Input(op FilterOperator, value string) *Query {
+	return q.Where(FieldInput, op, value)
+}
+
+// Output filters by the JSON output.
+func (q *Query) Output(op FilterOperator, value string) *Query {
+	return q.Where(FieldOutput, op, value)
+}
+
+// ThreadID matches traces of the given thread.
+func (q *Query) ThreadID(threadID string) *Query {
+	return q.Where(FieldThreadID, OpEqual, threadID)
+}
+
+// Errored matches traces or spans with an error recorded on them.
+func (q *Query) Errored() *Query {
+	return q.Where(FieldErrorInfo, OpIsNotEmpty, "")
+}
+
+// TimeRange matches traces or spans started within [from, to]. A zero time
+// leaves that end of the range open.
+func (q *Query) TimeRange(from, to time.Time) *Query {
+	q.from = from
+	q.to = to
+	return q
+}
+
+// Since matches traces or spans started within d of now.
+func (q *Query) Since(d time.Duration) *Query {
+	return q.TimeRange(time.Now().Add(-d), time.Time{})
+}
+
+// OrderBy sorts results by a field. It may be called more than once to add
+// secondary sort fields. Opik sorts by descending start time by default.
+func (q *Query) OrderBy(field string, direction SortDirection) *Query {
+	q.sorting = append(q.sorting, SortField{Field: field, Direction: direction})
+	return q
+}
+
+// TraceID matches spans of the given trace. It is ignored by QueryTraces.
+func (q *Query) TraceID(traceID string) *Query {
+	q.traceID = traceID
+	return q
+}
+
+// SpanType matches spans of the given type, such as SpanTypeLLM. It is
+// ignored by QueryTraces.
+func (q *Query) SpanType(spanType string) *Query {
+	q.spanType = spanType
+	return q
+}
+
+// Truncate asks Opik to truncate images in inputs, outputs and metadata.
+func (q *Query) Truncate() *Query {
+	q.truncate = true
+	return q
+}
+
+// Filters returns the query's filters.
+func (q *Query) Filters() []Filter {
+	return q.filters
+}
+
+// filterValue formats a filter value the way Opik expects it.
+func filterValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return strconv.FormatFloat(float64(v)/float64(time.Millisecond), 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// compiledQuery holds the query parameters shared by the trace and span
+// search endpoints.
+type compiledQuery struct {
+	projectName api.OptString
+	projectID   api.OptUUID
+	filters     api.OptString
+	sorting     api.OptString
+	from        api.OptDateTime
+	to          api.OptDateTime
+	truncate    api.OptBool
+}
+
+// compile converts the query to API parameters, defaulting to the client's
+// project. A nil query matches everything.
+func (q *Query) compile(c *Client) (compiledQuery, error) {
+	if q == nil {
+		q = &Query{}
+	}
+
+	var cq compiledQuery
+	switch {
+	case q.projectID != "":
+		id, err := uuid.Parse(q.projectID)
+		if err != nil {
+			return cq, err
+		}
+		cq.projectID = api.NewOptUUID(id)
+	case q.projectName != "":
+		cq.projectName = api.NewOptString(q.projectName)
+	default:
+		cq.projectName = api.NewOptString(c.projectName)
+	}
+
+	if len(q.filters) > 0 {
+		data, err := json.Marshal(q.filters)
+		if err != nil {
+			return cq, err
+		}
+		cq.filters = api.NewOptString(string(data))
+	}
+	if len(q.sorting) > 0 {
+		data, err := json.Marshal(q.sorting)
+		if err != nil {
+			return cq, err
+		}
+		cq.sorting = api.NewOptString(string(data))
+	}
+	if !q.from.IsZero() {
+		cq.from = api.NewOptDateTime(q.from)
+	}
+	if !q.to.IsZero() {
+		cq.to = api.NewOptDateTime(q.to)
+	}
+	if q.truncate {
+		cq.truncate = api.NewOptBool(true)
+	}
+	return cq, nil
+}
+
+// QueryTraces lists the traces matching q. A nil query lists the traces of
+// the client's project.
+func (c *Client) QueryTraces(ctx context.Context, q *Query, page, size int) ([]*TraceInfo, error) {
+	cq, err := q.compile(c)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.apiClient.GetTracesByProject(ctx, api.GetTracesByProjectParams{
+		ProjectName: cq.projectName,
+		ProjectID:   cq.projectID,
+		Filters:     cq.filters,
+		Sorting:     cq.sorting,
+		FromTime:    cq.from,
+		ToTime:      cq.to,
+		Truncate:    cq.truncate,
+		Page:        api.NewOptInt32(int32(page)), //nolint:gosec // G115: page values are bounded by API limits
+		Size:        api.NewOptInt32(int32(size)), //nolint:gosec // G115: size values are bounded by API limits
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	traces := make([]*TraceInfo, 0, len(resp.Content))
+	for i := range resp.Content {
+		traces = append(traces, traceInfoFromAPI(&resp.Content[i]))
+	}
+
+	return traces, nil
+}
+
+// QuerySpans lists the spans matching q. A nil query lists the spans of the
+// client's project.
+func (c *Client) QuerySpans(ctx context.Context, q *Query, page, size int) ([]*SpanInfo, error) {
+	cq, err := q.compile(c)
+	if err != nil {
+		return nil, err
+	}
+
+	params := api.GetSpansByProjectParams{
+		ProjectName: cq.projectName,
+		ProjectID:   cq.projectID,
+		Filters:     cq.filters,
+		Sorting:     cq.sorting,
+		FromTime:    cq.from,
+		ToTime:      cq.to,
+		Truncate:    cq.truncate,
+		Page:        api.NewOptInt32(int32(page)), //nolint:gosec // G115: page values are bounded by API limits
+		Size:        api.NewOptInt32(int32(size)), //nolint:gosec // G115: size values are bounded by API limits
+	}
+	if q != nil && q.traceID != "" {
+		traceUUID, err := uuid.Parse(q.traceID)
+		if err != nil {
+			return nil, err
+		}
+		params.TraceID = api.NewOptUUID(traceUUID)
+	}
+	if q != nil && q.spanType != "" {
+		params.Type = api.NewOptGetSpansByProjectType(api.GetSpansByProjectType(q.spanType))
+	}
+
+	resp, err := c.apiClient.GetSpansByProject(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	spans := make([]*SpanInfo, 0, len(resp.Content))
+	for i := range resp.Content {
+		spans = append(spans, spanInfoFromAPI(&resp.Content[i]))
+	}
+
+	return spans, nil
+}
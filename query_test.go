@@ -0,0 +1,175 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+func TestQueryFilters(t *testing.T) {
+	q := NewQuery().
+		Tag("checkout").
+		FeedbackScore("hallucination", OpLessThan, 0.5).
+		Metadata("model", OpEqual, "gpt-4o").
+		Duration(OpGreaterThan, 1500*time.Millisecond).
+		Cost(OpLessOrEqual, 0.02).
+		Errored()
+
+	want := []Filter{
+		{Field: "tags", Operator: OpContains, Value: "checkout"},
+		{Field: "feedback_scores", Operator: "<", Key: "hallucination", Value: "0.5"},
+		{Field: "metadata", Operator: "=", Key: "model", Value: "gpt-4o"},
+		{Field: "duration", Operator: ">", Value: "1500"},
+		{Field: "total_estimated_cost", Operator: "<=", Value: "0.02"},
+		{Field: "error_info", Operator: "is_not_empty", Value: ""},
+	}
+	got := q.Filters()
+	if len(got) != len(want) {
+		t.Fatalf("got %d filters, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("filter %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFilterValue(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{nil, ""},
+		{"text", "text"},
+		{42, "42"},
+		{0.25, "0.25"},
+		{true, "true"},
+		{ts, "2025-01-02T02:04:05Z"},
+		{2 * time.Second, "2000"},
+	}
+	for _, tt := range tests {
+		if got := filterValue(tt.value); got != tt.want {
+			t.Errorf("filterValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// captureQuery records the query string of requests to a route and
+// responds with an empty page.
+func captureQuery(route *testutil.Route, query *url.Values) {
+	route.WithHandler(func(w http.ResponseWriter, r *http.Request) {
+		*query = r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"page":1,"size":0,"total":0,"content":[]}`))
+	})
+}
+
+func TestQueryTraces(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	var query url.Values
+	captureQuery(ms.OnGet("/v1/private/traces"), &query)
+
+	client, err := NewClient(WithURL(ms.URL()), WithProjectName("default-project"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	q := NewQuery().
+		Project("other-project").
+		Name(OpStartsWith, "chat").
+		TimeRange(from, from.Add(24*time.Hour)).
+		OrderBy(FieldStartTime, SortAsc)
+	if _, err := client.QueryTraces(context.Background(), q, 2, 25); err != nil {
+		t.Fatalf("QueryTraces error: %v", err)
+	}
+
+	if query.Get("project_name") != "other-project" || query.Get("page") != "2" || query.Get("size") != "25" {
+		t.Errorf("unexpected query %v", query)
+	}
+	if query.Get("from_time") == "" || query.Get("to_time") == "" {
+		t.Errorf("time range not sent: %v", query)
+	}
+
+	var filters []Filter
+	if err := json.Unmarshal([]byte(query.Get("filters")), &filters); err != nil {
+		t.Fatalf("filters = %q: %v", query.Get("filters"), err)
+	}
+	if len(filters) != 1 || filters[0].Field != "name" || filters[0].Operator != OpStartsWith || filters[0].Value != "chat" {
+		t.Errorf("filters = %+v", filters)
+	}
+	if got := query.Get("sorting"); got != `[{"field":"start_time","direction":"ASC"}]` {
+		t.Errorf("sorting = %s", got)
+	}
+}
+
+func TestQueryTracesDefaults(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	var query url.Values
+	captureQuery(ms.OnGet("/v1/private/traces"), &query)
+
+	client, err := NewClient(WithURL(ms.URL()), WithProjectName("default-project"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	if _, err := client.ListTraces(context.Background(), 1, 10); err != nil {
+		t.Fatalf("ListTraces error: %v", err)
+	}
+	if query.Get("project_name") != "default-project" || query.Has("filters") || query.Has("sorting") {
+		t.Errorf("unexpected query %v", query)
+	}
+}
+
+func TestQuerySpans(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	var query url.Values
+	captureQuery(ms.OnGet("/v1/private/spans"), &query)
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	q := NewQuery().
+		ProjectID(testProjectID).
+		TraceID(testTraceID).
+		SpanType(SpanTypeLLM).
+		Where(FieldModel, OpEqual, "gpt-4o")
+	if _, err := client.QuerySpans(context.Background(), q, 1, 10); err != nil {
+		t.Fatalf("QuerySpans error: %v", err)
+	}
+
+	if query.Get("project_id") != testProjectID || query.Has("project_name") {
+		t.Errorf("unexpected project in query %v", query)
+	}
+	if query.Get("trace_id") != testTraceID || query.Get("type") != "llm" {
+		t.Errorf("unexpected query %v", query)
+	}
+}
+
+func TestQueryInvalidIDs(t *testing.T) {
+	client, err := NewClient(WithURL("http://localhost:0"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	if _, err := client.QueryTraces(context.Background(), NewQuery().ProjectID("not-a-uuid"), 1, 10); err == nil {
+		t.Error("QueryTraces should reject an invalid project ID")
+	}
+	if _, err := client.QuerySpans(context.Background(), NewQuery().TraceID("not-a-uuid"), 1, 10); err == nil {
+		t.Error("QuerySpans should reject an invalid trace ID")
+	}
+}
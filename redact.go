@@ -0,0 +1,353 @@
+package opik
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Payload fields passed to Redactor.Redact.
+const (
+	RedactFieldInput    = "input"
+	RedactFieldOutput   = "output"
+	RedactFieldMetadata = "metadata"
+	RedactFieldTags     = "tags"
+	RedactFieldReason   = "reason"
+)
+
+// Redactor scrubs sensitive data from trace and span payloads before they
+// are serialized and sent to Opik. Redact receives the payload field name
+// (one of the RedactField constants) and its value, and returns the value to
+// send. Implementations must not modify value in place.
+type Redactor interface {
+	Redact(field string, value any) any
+}
+
+// RedactorFunc adapts a function to the Redactor interface.
+type RedactorFunc func(field string, value any) any
+
+// Redact calls f(field, value).
+func (f RedactorFunc) Redact(field string, value any) any {
+	return f(field, value)
+}
+
+// Detector finds sensitive substrings in string values.
+type Detector struct {
+	// Name identifies the kind of data, and appears in the default replacement.
+	Name string
+	// Pattern matches the sensitive data.
+	Pattern *regexp.Regexp
+	// Validate, if set, filters matches; only matches it returns true for are redacted.
+	Validate func(match string) bool
+}
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern      = regexp.MustCompile(`(?:\+\d{1,3}[\s.\-]?)?(?:\(\d{3}\)|\b\d{3})[\s.\-]?\d{3}[\s.\-]?\d{4}\b`)
+	creditCardPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	bearerPattern     = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	apiKeyPattern     = regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_\-]{16,}|\bAKIA[0-9A-Z]{16}\b|\bgh[pousr]_[A-Za-z0-9]{36,}\b|\bxox[abprs]-[A-Za-z0-9\-]{10,}`)
+)
+
+// EmailDetector detects email addresses.
+func EmailDetector() Detector {
+	return Detector{Name: "email", Pattern: emailPattern}
+}
+
+// PhoneNumberDetector detects North American and international phone numbers.
+func PhoneNumberDetector() Detector {
+	return Detector{Name: "phone", Pattern: phonePattern}
+}
+
+// CreditCardDetector detects payment card numbers that pass the Luhn check.
+func CreditCardDetector() Detector {
+	return Detector{Name: "credit_card", Pattern: creditCardPattern, Validate: luhnValid}
+}
+
+// BearerTokenDetector detects bearer tokens, e.g. in Authorization headers.
+func BearerTokenDetector() Detector {
+	return Detector{Name: "bearer_token", Pattern: bearerPattern}
+}
+
+// APIKeyDetector detects API keys with well-known prefixes, such as OpenAI
+// and Anthropic "sk-" keys, AWS access key IDs, and GitHub and Slack tokens.
+func APIKeyDetector() Detector {
+	return Detector{Name: "api_key", Pattern: apiKeyPattern}
+}
+
+// DefaultDetectors returns all built-in detectors. Credit cards are checked
+// before phone numbers so card numbers are not partially matched as phones.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		EmailDetector(),
+		CreditCardDetector(),
+		PhoneNumberDetector(),
+		BearerTokenDetector(),
+		APIKeyDetector(),
+	}
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum.
+func luhnValid(s string) bool {
+	sum := 0
+	n := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// PatternRedactor is a Redactor that replaces detector matches in every
+// string value and replaces whole values at configured field paths.
+type PatternRedactor struct {
+	detectors   []Detector
+	paths       [][]string
+	replacement func(name string) string
+}
+
+// RedactorOption configures a PatternRedactor.
+type RedactorOption func(*PatternRedactor)
+
+// WithDetectors adds detectors to the redactor.
+func WithDetectors(detectors ...Detector) RedactorOption {
+	return func(r *PatternRedactor) {
+		r.detectors = append(r.detectors, detectors...)
+	}
+}
+
+// WithRedactPattern adds a custom regular expression detector.
+func WithRedactPattern(name string, pattern *regexp.Regexp) RedactorOption {
+	return WithDetectors(Detector{Name: name, Pattern: pattern})
+}
+
+// WithRedactPaths replaces the values at the given field paths entirely.
+// A path starts with the payload field and uses dots for object keys and
+// brackets for array elements, e.g. "input.messages[*].content",
+// "metadata.user.email" or "output.choices[0].message". "*" matches any key,
+// and "[*]" any element.
+func WithRedactPaths(paths ...string) RedactorOption {
+	return func(r *PatternRedactor) {
+		for _, p := range paths {
+			if segments := parseRedactPath(p); len(segments) > 0 {
+				r.paths = append(r.paths, segments)
+			}
+		}
+	}
+}
+
+// WithRedactReplacement sets the function that produces the replacement
+// text for a redacted value. It receives the detector name, or "field" for
+// path rules. The default produces "[REDACTED:<name>]".
+func WithRedactReplacement(replacement func(name string) string) RedactorOption {
+	return func(r *PatternRedactor) {
+		r.replacement = replacement
+	}
+}
+
+// NewRedactor creates a PatternRedactor. Without options it uses the
+// built-in detectors; with options only the configured detectors and paths
+// apply, so pass WithDetectors(DefaultDetectors()...) to combine them.
+func NewRedactor(opts ...RedactorOption) *PatternRedactor {
+	r := &PatternRedactor{
+		replacement: func(name string) string {
+			return "[REDACTED:" + name + "]"
+		},
+	}
+	if len(opts) == 0 {
+		r.detectors = DefaultDetectors()
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Redact implements Redactor. Values other than strings and string slices
+// are converted to their JSON form first, so structs are redacted by their
+// JSON field names. A value that cannot be converted is replaced whole with
+// the replacement for "redaction_failed", so that it is never sent as is.
+func (r *PatternRedactor) Redact(field string, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if r.matchesField(field) {
+			return r.replacement("field")
+		}
+		return r.redactString(v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = r.redactString(s)
+		}
+		return out
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return r.replacement("redaction_failed")
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return r.replacement("redaction_failed")
+	}
+
+	for _, path := range r.paths {
+		if path[0] == field {
+			tree = r.redactPath(tree, path[1:])
+		}
+	}
+	return r.redactTree(tree)
+}
+
+// matchesField reports whether a path rule covers the whole field.
+func (r *PatternRedactor) matchesField(field string) bool {
+	for _, path := range r.paths {
+		if len(path) == 1 && path[0] == field {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replaces every detector match in s.
+func (r *PatternRedactor) redactString(s string) string {
+	for _, d := range r.detectors {
+		s = d.Pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if d.Validate != nil && !d.Validate(match) {
+				return match
+			}
+			return r.replacement(d.Name)
+		})
+	}
+	return s
+}
+
+// redactTree applies the detectors to every string in a decoded JSON value.
+func (r *PatternRedactor) redactTree(node any) any {
+	switch v := node.(type) {
+	case string:
+		return r.redactString(v)
+	case map[string]any:
+		for k, child := range v {
+			v[k] = r.redactTree(child)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.redactTree(child)
+		}
+	}
+	return node
+}
+
+// redactPath replaces the values at path within a decoded JSON value.
+func (r *PatternRedactor) redactPath(node any, path []string) any {
+	if len(path) == 0 {
+		return r.replacement("field")
+	}
+
+	segment, rest := path[0], path[1:]
+	switch v := node.(type) {
+	case map[string]any:
+		if segment == "*" {
+			for k, child := range v {
+				v[k] = r.redactPath(child, rest)
+			}
+		} else if child, ok := v[segment]; ok {
+			v[segment] = r.redactPath(child, rest)
+		}
+	case []any:
+		if segment == "[*]" {
+			for i, child := range v {
+				v[i] = r.redactPath(child, rest)
+			}
+		} else if strings.HasPrefix(segment, "[") {
+			i, err := strconv.Atoi(strings.Trim(segment, "[]"))
+			if err == nil && i >= 0 && i < len(v) {
+				v[i] = r.redactPath(v[i], rest)
+			}
+		}
+	}
+	return node
+}
+
+// parseRedactPath splits "input.messages[*].content" into
+// ["input", "messages", "[*]", "content"].
+func parseRedactPath(path string) []string {
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			i := strings.IndexByte(part, '[')
+			switch {
+			case i < 0:
+				segments = append(segments, part)
+				part = ""
+			case i > 0:
+				segments = append(segments, part[:i])
+				part = part[i:]
+			default:
+				end := strings.IndexByte(part, ']')
+				if end < 0 {
+					return nil
+				}
+				segments = append(segments, part[:end+1])
+				part = part[end+1:]
+			}
+		}
+	}
+	return segments
+}
+
+// redact applies the client's redactor to a payload field.
+func (c *Client) redact(field string, value any) any {
+	if c == nil || c.redactor == nil || value == nil {
+		return value
+	}
+	return c.redactor.Redact(field, value)
+}
+
+// redactMetadata applies the client's redactor to metadata. Metadata the
+// redactor turns into something other than an object is dropped.
+func (c *Client) redactMetadata(metadata map[string]any) map[string]any {
+	if len(metadata) == 0 || c == nil || c.redactor == nil {
+		return metadata
+	}
+	redacted, _ := c.redactor.Redact(RedactFieldMetadata, metadata).(map[string]any)
+	return redacted
+}
+
+// redactTags applies the client's redactor to tags.
+func (c *Client) redactTags(tags []string) []string {
+	if len(tags) == 0 || c == nil || c.redactor == nil {
+		return tags
+	}
+	redacted, _ := c.redactor.Redact(RedactFieldTags, tags).([]string)
+	return redacted
+}
+
+// redactReason applies the client's redactor to a feedback reason.
+func (c *Client) redactReason(reason string) string {
+	if reason == "" || c == nil || c.redactor == nil {
+		return reason
+	}
+	redacted, _ := c.redactor.Redact(RedactFieldReason, reason).(string)
+	return redacted
+}
+
+// Redactor returns the client's redactor, or nil if none is configured.
+func (c *Client) Redactor() Redactor {
+	return c.redactor
+}
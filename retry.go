@@ -0,0 +1,312 @@
+package opik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+// RetryPolicy configures how the client retries failed API requests.
+//
+// Requests are retried on network errors and on 408, 429, 500, 502, 503
+// and 504 responses. Requests that are not idempotent are only retried when
+// the server cannot have processed them: if the connection could not be
+// established, or on 429 and 503 responses. GET, HEAD, OPTIONS, PUT and
+// DELETE requests are idempotent, and so are the batch create, update and
+// delete endpoints, whose entities are identified by client-generated IDs.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the delay grows after each retry.
+	Multiplier float64
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomized so that clients do not retry in lockstep.
+	Jitter float64
+	// MaxRetryAfter caps the delay requested by a Retry-After header.
+	// Responses asking for a longer delay are not retried.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy returns the retry policy used by default.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		MaxRetryAfter:  10 * time.Second,
+	}
+}
+
+// backoff returns the delay before the given retry, counted from 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(max(p.Multiplier, 1), float64(retry-1))
+	if p.MaxBackoff > 0 {
+		d = min(d, float64(p.MaxBackoff))
+	}
+	jitter := min(max(p.Jitter, 0), 1)
+	return time.Duration(d * (1 - jitter*rand.Float64())) //nolint:gosec // G404: jitter needs no secure randomness
+}
+
+// retryDelay decides whether a failed attempt is retried and how long to
+// wait before doing so.
+func (p RetryPolicy) retryDelay(retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool) {
+	idempotent := isIdempotent(req)
+	if err != nil {
+		if req.Context().Err() != nil || !IsRetryable(err) {
+			return 0, false
+		}
+		return p.backoff(retry), idempotent || isDialError(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= p.MaxRetryAfter
+		}
+		return p.backoff(retry), true
+	case http.StatusRequestTimeout, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusGatewayTimeout:
+		return p.backoff(retry), idempotent
+	}
+	return 0, false
+}
+
+// isIdempotent reports whether repeating req has the same effect as sending
+// it once.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost, http.MethodPatch:
+		return strings.HasSuffix(req.URL.Path, "/batch") || strings.HasSuffix(req.URL.Path, "/delete")
+	}
+	return false
+}
+
+// isDialError reports whether err happened while connecting, before any
+// part of the request was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an
+// HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// contextWithoutRetries marks requests made with ctx to be sent once,
+// because the caller retries them itself.
+func contextWithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryContextKey, true)
+}
+
+func retriesDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetryContextKey).(bool)
+	return disabled
+}
+
+// send sends req, retrying according to the client's retry policy.
+func (c *authHTTPClient) send(req *http.Request) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		c.metrics.recordAttempt(req, nil, err)
+		return nil, err
+	}
+
+	attempts := c.retry.MaxAttempts
+	rewindable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if retriesDisabled(req.Context()) || !rewindable {
+		// A body that cannot be rewound cannot be sent again
+		attempts = 1
+	}
+
+	for retry := 1; ; retry++ {
+		resp, err := c.client.Do(req)
+		c.breaker.record(resp, err)
+		c.metrics.recordAttempt(req, resp, err)
+		if retry >= attempts {
+			return resp, err
+		}
+
+		delay, ok := c.retry.retryDelay(retry, req, resp, err)
+		if !ok || c.breaker.allow() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		c.metrics.httpRetries.Add(req.Context(), 1, metric.WithAttributes(attrMethod.String(req.Method)))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails requests with ErrCircuitOpen without sending them.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through to check whether
+	// the server has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Default circuit breaker settings.
+const (
+	DefaultCircuitFailureThreshold = 5
+	DefaultCircuitOpenTimeout      = 30 * time.Second
+)
+
+// CircuitBreaker stops sending requests to a server that keeps failing, so
+// that callers fail fast instead of waiting for timeouts and retries.
+//
+// The breaker opens after a number of consecutive failed attempts: network
+// errors and 5xx responses. While open, requests fail with ErrCircuitOpen.
+// After the open timeout, a single probe request is let through; the
+// breaker closes if it succeeds and opens again if it fails. Batched
+// telemetry that fails with ErrCircuitOpen is spooled if the batcher has a
+// spool and dropped otherwise.
+type CircuitBreaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	now      func() time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker that opens after threshold
+// consecutive failures and lets a probe through after openTimeout.
+// Non-positive values use the defaults.
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultCircuitFailureThreshold
+	}
+	if openTimeout <= 0 {
+		openTimeout = DefaultCircuitOpenTimeout
+	}
+	return &CircuitBreaker{threshold: threshold, openTimeout: openTimeout, now: time.Now}
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.openTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow returns ErrCircuitOpen if a request must not be sent.
+func (b *CircuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an attempt.
+func (b *CircuitBreaker) record(resp *http.Response, err error) {
+	if b == nil {
+		return
+	}
+	failed := resp == nil || resp.StatusCode >= 500
+	if err != nil && errors.Is(err, context.Canceled) {
+		// The caller gave up; this says nothing about the server
+		failed = false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
@@ -0,0 +1,310 @@
+package opik
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			got := policy.backoff(tt.retry)
+			if got > tt.want || got < tt.want/2 {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.retry, got, tt.want/2, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("3"); !ok || d != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %v, %v", d, ok)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date); !ok || d < 59*time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, %v", date, d, ok)
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if _, ok := parseRetryAfter(value); ok {
+			t.Errorf("parseRetryAfter(%q) should fail", value)
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{http.MethodGet, "/v1/private/projects", true},
+		{http.MethodPut, "/v1/private/traces/feedback-scores", true},
+		{http.MethodPost, "/v1/private/traces/batch", true},
+		{http.MethodPatch, "/v1/private/spans/batch", true},
+		{http.MethodPost, "/v1/private/traces/comments/delete", true},
+		{http.MethodPost, "/v1/private/projects", false},
+		{http.MethodPatch, "/v1/private/traces/comments/1", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "http://localhost"+tt.path, nil)
+		if got := isIdempotent(req); got != tt.want {
+			t.Errorf("isIdempotent(%s %s) = %v, want %v", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+// failingRoute responds with status to the first failures requests and
+// with 200 and body afterwards, or 201 if body is empty.
+func failingRoute(route *testutil.Route, failures int32, status int, header map[string]string, body string) *atomic.Int32 {
+	var calls atomic.Int32
+	route.WithHandler(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) <= failures {
+			for k, v := range header {
+				w.Header().Set(k, v)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		if body == "" {
+			w.Header().Set("Location", "/v1/private/projects/1")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	})
+	return &calls
+}
+
+func newTestRetryClient(t *testing.T, ms *testutil.MockServer, opts ...Option) *Client {
+	t.Helper()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client, err := NewClient(append([]Option{WithURL(ms.URL()), WithRetryPolicy(policy)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	return client
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	calls := failingRoute(ms.OnGet("/v1/private/projects"), 2, http.StatusBadGateway, nil, `{"content":[]}`)
+	client := newTestRetryClient(t, ms)
+
+	if _, err := client.ListProjects(context.Background(), 1, 10); err != nil {
+		t.Fatalf("ListProjects error: %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestClientRetriesNonIdempotentRequests(t *testing.T) {
+	t.Run("server error", func(t *testing.T) {
+		ms := testutil.NewMockServer()
+		defer ms.Close()
+
+		calls := failingRoute(ms.OnPost("/v1/private/projects"), 1, http.StatusInternalServerError, nil, "")
+		client := newTestRetryClient(t, ms)
+
+		if _, err := client.CreateProject(context.Background(), "p"); !IsServerError(err) {
+			t.Errorf("CreateProject error = %v, want a server error", err)
+		}
+		if calls.Load() != 1 {
+			t.Errorf("calls = %d, want 1", calls.Load())
+		}
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		ms := testutil.NewMockServer()
+		defer ms.Close()
+
+		calls := failingRoute(ms.OnPost("/v1/private/projects"), 1, http.StatusTooManyRequests,
+			map[string]string{"Retry-After": "0"}, "")
+		client := newTestRetryClient(t, ms)
+
+		if _, err := client.CreateProject(context.Background(), "p"); err != nil {
+			t.Errorf("CreateProject error: %v", err)
+		}
+		if calls.Load() != 2 {
+			t.Errorf("calls = %d, want 2", calls.Load())
+		}
+	})
+
+	t.Run("Retry-After too long", func(t *testing.T) {
+		ms := testutil.NewMockServer()
+		defer ms.Close()
+
+		calls := failingRoute(ms.OnPost("/v1/private/projects"), 1, http.StatusServiceUnavailable,
+			map[string]string{"Retry-After": "3600"}, "")
+		client := newTestRetryClient(t, ms)
+
+		_, err := client.CreateProject(context.Background(), "p")
+		if RetryAfter(err) != time.Hour {
+			t.Errorf("RetryAfter(%v) = %v, want 1h", err, RetryAfter(err))
+		}
+		if calls.Load() != 1 {
+			t.Errorf("calls = %d, want 1", calls.Load())
+		}
+	})
+}
+
+// certErrorTransport fails every request with a certificate error.
+type certErrorTransport struct {
+	calls atomic.Int32
+}
+
+func (t *certErrorTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return nil, &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}
+}
+
+func TestClientDoesNotRetryPermanentErrors(t *testing.T) {
+	transport := &certErrorTransport{}
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client, err := NewClient(
+		WithURL("https://localhost"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithRetryPolicy(policy),
+	)
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	if _, err := client.ListProjects(context.Background(), 1, 10); err == nil || IsRetryable(err) {
+		t.Errorf("ListProjects error = %v, want a permanent error", err)
+	}
+	if n := transport.calls.Load(); n != 1 {
+		t.Errorf("calls = %d, want 1", n)
+	}
+}
+
+func TestClientSendsOnceByDefault(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	calls := failingRoute(ms.OnGet("/v1/private/projects"), 1, http.StatusBadGateway, nil, `{"content":[]}`)
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	if _, err := client.ListProjects(context.Background(), 1, 10); !IsServerError(err) {
+		t.Errorf("ListProjects error = %v, want a server error", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	calls := failingRoute(ms.OnGet("/v1/private/projects"), 2, http.StatusServiceUnavailable, nil, `{"content":[]}`)
+
+	now := time.Now()
+	breaker := NewCircuitBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+	client := newTestRetryClient(t, ms, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}), WithCircuitBreaker(breaker))
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := client.ListProjects(ctx, 1, 10); !IsServerError(err) {
+			t.Fatalf("ListProjects error = %v, want a server error", err)
+		}
+	}
+	if breaker.State() != CircuitOpen {
+		t.Fatalf("State() = %v, want open", breaker.State())
+	}
+
+	// Requests fail fast while the breaker is open
+	if _, err := client.ListProjects(ctx, 1, 10); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("ListProjects error = %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", calls.Load())
+	}
+
+	// After the timeout a probe is let through and closes the breaker
+	now = now.Add(time.Minute)
+	if breaker.State() != CircuitHalfOpen {
+		t.Fatalf("State() = %v, want half-open", breaker.State())
+	}
+	if _, err := client.ListProjects(ctx, 1, 10); err != nil {
+		t.Fatalf("ListProjects error: %v", err)
+	}
+	if breaker.State() != CircuitClosed {
+		t.Errorf("State() = %v, want closed", breaker.State())
+	}
+}
+
+func TestBatcherFailsFastWhenCircuitOpen(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/batch").Respond(http.StatusServiceUnavailable, nil)
+
+	var dropped atomic.Int32
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	config.OnError = func(err *BatchError) {
+		if IsCircuitOpen(err) {
+			dropped.Add(1)
+		}
+	}
+
+	client, err := NewBatchingClientWithConfig(config,
+		WithURL(ms.URL()),
+		WithCircuitBreaker(NewCircuitBreaker(2, time.Hour)),
+	)
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	defer client.Close(time.Second)
+
+	if _, err := client.Trace(context.Background(), "unlucky"); err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+
+	// The batcher stops retrying once the breaker opens
+	if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 2 {
+		t.Errorf("attempts = %d, want 2", n)
+	}
+	if dropped.Load() != 1 {
+		t.Errorf("dropped = %d, want 1", dropped.Load())
+	}
+}
@@ -0,0 +1,469 @@
+package opik
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// SamplingDecision is the outcome of a sampling decision.
+type SamplingDecision int
+
+const (
+	// SamplingDrop discards the trace and all its spans.
+	SamplingDrop SamplingDecision = iota
+	// SamplingRecord exports the trace and its spans as they are created.
+	SamplingRecord
+	// SamplingDefer buffers the trace and its spans locally until the trace
+	// ends, then asks the TailSampler whether to export them. See
+	// WithTailSamplingLimits for the bounds on the buffer.
+	SamplingDefer
+)
+
+// SamplingParameters describes a trace about to be created.
+type SamplingParameters struct {
+	TraceID     string
+	Name        string
+	ProjectName string
+	Tags        []string
+	ThreadID    string
+}
+
+// Sampler decides whether a trace is exported.
+// The decision is made once in Client.Trace and applies to every span of
+// the trace.
+type Sampler interface {
+	ShouldSample(params SamplingParameters) SamplingDecision
+}
+
+// FinishedTrace summarizes a deferred trace when it ends or expires.
+type FinishedTrace struct {
+	TraceID     string
+	Name        string
+	ProjectName string
+	Duration    time.Duration
+	// Errored is true if an error was recorded on the trace or any of its spans.
+	Errored bool
+	// SpanCount is the number of spans buffered for the trace.
+	SpanCount int
+	// DroppedSpans is the number of spans that were not buffered because
+	// the trace reached the span limit. They are not exported.
+	DroppedSpans int
+	// Expired is true if the trace is decided because it outlived the
+	// deferred trace timeout, rather than because it ended. Duration is
+	// then the time the trace has been open.
+	Expired bool
+}
+
+// TailSampler is a Sampler that can defer its decision until a trace ends.
+// ShouldExport is called for traces that ShouldSample returned SamplingDefer for.
+type TailSampler interface {
+	Sampler
+	ShouldExport(trace FinishedTrace) bool
+}
+
+// SamplerFunc adapts a function to the Sampler interface.
+type SamplerFunc func(params SamplingParameters) SamplingDecision
+
+// ShouldSample calls f(params).
+func (f SamplerFunc) ShouldSample(params SamplingParameters) SamplingDecision {
+	return f(params)
+}
+
+// AlwaysSample returns a sampler that exports every trace.
+func AlwaysSample() Sampler {
+	return SamplerFunc(func(SamplingParameters) SamplingDecision {
+		return SamplingRecord
+	})
+}
+
+// NeverSample returns a sampler that drops every trace.
+func NeverSample() Sampler {
+	return SamplerFunc(func(SamplingParameters) SamplingDecision {
+		return SamplingDrop
+	})
+}
+
+// ratioSampler samples a fixed fraction of traces.
+type ratioSampler struct {
+	threshold uint64
+	ratio     float64
+}
+
+// NewRatioSampler returns a sampler that exports the given fraction of
+// traces, between 0 and 1. The decision is derived from the random bits of
+// the trace ID, so it is stable for a given trace.
+func NewRatioSampler(ratio float64) Sampler {
+	ratio = max(0, min(1, ratio))
+	return &ratioSampler{
+		ratio:     ratio,
+		threshold: uint64(ratio * math.MaxUint64),
+	}
+}
+
+func (s *ratioSampler) ShouldSample(params SamplingParameters) SamplingDecision {
+	if s.ratio >= 1 {
+		return SamplingRecord
+	}
+	if s.ratio <= 0 {
+		return SamplingDrop
+	}
+
+	id, err := uuid.Parse(params.TraceID)
+	if err != nil {
+		return SamplingRecord
+	}
+	// The last 8 bytes of a UUID v7 are random apart from the 2 variant bits
+	if binary.BigEndian.Uint64(id[8:])<<2 < s.threshold {
+		return SamplingRecord
+	}
+	return SamplingDrop
+}
+
+// rateLimitedSampler samples at most a fixed number of traces per second,
+// using a token bucket holding up to burst tokens.
+type rateLimitedSampler struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+// NewRateLimitedSampler returns a sampler that exports at most perSecond
+// traces per second, allowing bursts of up to one second's worth. Rates
+// below one trace per second, such as 0.1 for one trace every 10 seconds,
+// allow bursts of a single trace.
+func NewRateLimitedSampler(perSecond float64) Sampler {
+	burst := max(1, perSecond)
+	return &rateLimitedSampler{
+		perSecond: perSecond,
+		burst:     burst,
+		tokens:    burst,
+		now:       time.Now,
+	}
+}
+
+func (s *rateLimitedSampler) ShouldSample(SamplingParameters) SamplingDecision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !s.last.IsZero() {
+		s.tokens = min(s.burst, s.tokens+now.Sub(s.last).Seconds()*s.perSecond)
+	}
+	s.last = now
+
+	if s.tokens < 1 {
+		return SamplingDrop
+	}
+	s.tokens--
+	return SamplingRecord
+}
+
+// SamplingRule routes matching traces to a sampler.
+// Empty fields match any trace.
+type SamplingRule struct {
+	ProjectName string
+	TraceName   string
+	Tag         string
+	Sampler     Sampler
+}
+
+func (r SamplingRule) matches(params SamplingParameters) bool {
+	if r.ProjectName != "" && r.ProjectName != params.ProjectName {
+		return false
+	}
+	if r.TraceName != "" && r.TraceName != params.Name {
+		return false
+	}
+	if r.Tag != "" && !slices.Contains(params.Tags, r.Tag) {
+		return false
+	}
+	return true
+}
+
+// ruleSampler applies the sampler of the first matching rule.
+type ruleSampler struct {
+	rules    []SamplingRule
+	fallback Sampler
+}
+
+// NewRuleSampler returns a sampler that applies the sampler of the first rule
+// matching the trace, or fallback if none matches. A nil fallback exports
+// unmatched traces.
+func NewRuleSampler(fallback Sampler, rules ...SamplingRule) Sampler {
+	if fallback == nil {
+		fallback = AlwaysSample()
+	}
+	return &ruleSampler{rules: rules, fallback: fallback}
+}
+
+func (s *ruleSampler) ShouldSample(params SamplingParameters) SamplingDecision {
+	for _, rule := range s.rules {
+		if rule.matches(params) {
+			return rule.Sampler.ShouldSample(params)
+		}
+	}
+	return s.fallback.ShouldSample(params)
+}
+
+// errorLatencySampler defers every trace and exports those that errored or
+// were slow.
+type errorLatencySampler struct {
+	head             Sampler
+	latencyThreshold time.Duration
+}
+
+// NewTailSampler returns a tail sampler that buffers each trace until it
+// ends and exports it only if an error was recorded on it or one of its
+// spans, or if it lasted at least latencyThreshold. A zero threshold exports
+// errored traces only.
+//
+// If head is not nil, traces it records are exported immediately, and only
+// the rest are buffered. Combined with a ratio sampler this keeps a baseline
+// sample of all traffic plus every failing or slow trace.
+func NewTailSampler(latencyThreshold time.Duration, head Sampler) TailSampler {
+	return &errorLatencySampler{head: head, latencyThreshold: latencyThreshold}
+}
+
+func (s *errorLatencySampler) ShouldSample(params SamplingParameters) SamplingDecision {
+	if s.head != nil && s.head.ShouldSample(params) == SamplingRecord {
+		return SamplingRecord
+	}
+	return SamplingDefer
+}
+
+func (s *errorLatencySampler) ShouldExport(trace FinishedTrace) bool {
+	if trace.Errored {
+		return true
+	}
+	return s.latencyThreshold > 0 && trace.Duration >= s.latencyThreshold
+}
+
+// Default limits for traces deferred by a tail sampler.
+const (
+	DefaultMaxDeferredSpans     = 1000
+	DefaultDeferredTraceTimeout = 10 * time.Minute
+)
+
+// deferLimits bounds the memory held by deferred traces. Zero fields
+// disable a limit.
+type deferLimits struct {
+	maxSpans int
+	timeout  time.Duration
+}
+
+// samplingState holds the sampling decision shared by a trace and its spans.
+// A nil state exports everything.
+type samplingState struct {
+	mu       sync.Mutex
+	decision SamplingDecision
+	tail     TailSampler
+	trace    *Trace
+	spans    []*Span
+
+	// maxSpans caps spans; the spans of a deferred trace beyond it are
+	// dropped and counted in dropped
+	maxSpans int
+	dropped  int
+	// errored records errors seen on spans of a deferred trace, including
+	// dropped ones
+	errored bool
+	// deadline is when a deferred trace is decided even if it has not
+	// ended; zero means never
+	deadline time.Time
+}
+
+// newSamplingState makes the sampling decision for a new trace.
+func newSamplingState(t *Trace, sampler Sampler, limits deferLimits, params SamplingParameters) *samplingState {
+	if sampler == nil {
+		return nil
+	}
+
+	decision := sampler.ShouldSample(params)
+	tail, ok := sampler.(TailSampler)
+	if decision == SamplingDefer && !ok {
+		// Only tail samplers can make a deferred decision
+		decision = SamplingRecord
+	}
+	st := &samplingState{decision: decision, tail: tail, trace: t, maxSpans: limits.maxSpans}
+	if decision == SamplingDefer && limits.timeout > 0 {
+		st.deadline = t.startTime.Add(limits.timeout)
+	}
+	return st
+}
+
+// sampled reports whether the trace has not been dropped.
+func (st *samplingState) sampled() bool {
+	if st == nil {
+		return true
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.decision != SamplingDrop
+}
+
+// recording reports whether the trace is being sent as it happens, rather
+// than dropped or buffered.
+func (st *samplingState) recording() bool {
+	if st == nil {
+		return true
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.decision == SamplingRecord
+}
+
+// expired reports whether a deferred trace has outlived its deadline.
+// The caller must hold st.mu.
+func (st *samplingState) expired() bool {
+	return !st.deadline.IsZero() && time.Now().After(st.deadline)
+}
+
+// admitSpan decides what to do with a span operation. Spans of a deferred
+// trace are buffered instead of sent, up to maxSpans. If the trace has
+// expired, the tail sampler decides, and the trace and its buffered spans
+// are returned for export when it keeps them.
+func (st *samplingState) admitSpan(s *Span, create bool) (traceAdmission, []*Span) {
+	if st == nil {
+		return admitSend, nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	switch st.decision {
+	case SamplingRecord:
+		return admitSend, nil
+	case SamplingDrop:
+		return admitSkip, nil
+	}
+
+	if s.errorInfo != nil {
+		st.errored = true
+	}
+	if create {
+		if st.maxSpans > 0 && len(st.spans) >= st.maxSpans {
+			st.dropped++
+		} else {
+			st.spans = append(st.spans, s)
+		}
+	}
+	if !st.expired() {
+		return admitSkip, nil
+	}
+	return st.decide(st.trace)
+}
+
+// traceAdmission is what to do with a trace operation.
+type traceAdmission int
+
+const (
+	admitSkip   traceAdmission = iota // drop or keep buffering
+	admitSend                         // send as usual
+	admitExport                       // create the deferred trace and its spans
+)
+
+// admitTrace decides what to do with a trace operation. When a deferred trace
+// ends, the tail sampler decides, and the spans buffered for an exported
+// trace are returned so they can be sent.
+func (st *samplingState) admitTrace(t *Trace) (traceAdmission, []*Span) {
+	if st == nil {
+		return admitSend, nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	switch st.decision {
+	case SamplingRecord:
+		return admitSend, nil
+	case SamplingDrop:
+		return admitSkip, nil
+	}
+
+	if !t.ended && !st.expired() {
+		return admitSkip, nil
+	}
+	return st.decide(t)
+}
+
+// decide asks the tail sampler whether to export the deferred trace t, which
+// has ended or expired. The caller must hold st.mu.
+func (st *samplingState) decide(t *Trace) (traceAdmission, []*Span) {
+	finished := FinishedTrace{
+		TraceID:      t.id,
+		Name:         t.name,
+		ProjectName:  t.projectName,
+		Errored:      t.errorInfo != nil || st.errored,
+		SpanCount:    len(st.spans),
+		DroppedSpans: st.dropped,
+		Expired:      !t.ended,
+	}
+	if t.endTime != nil {
+		finished.Duration = t.endTime.Sub(t.startTime)
+	} else {
+		finished.Duration = time.Since(t.startTime)
+	}
+	for _, s := range st.spans {
+		if s.errorInfo != nil {
+			finished.Errored = true
+		}
+	}
+
+	spans := st.spans
+	st.spans = nil
+	if !st.tail.ShouldExport(finished) {
+		st.decision = SamplingDrop
+		return admitSkip, nil
+	}
+	st.decision = SamplingRecord
+	return admitExport, spans
+}
+
+// exportDeferred sends a deferred trace that the tail sampler kept, followed
+// by its buffered spans.
+func (c *Client) exportDeferred(ctx context.Context, t *Trace, spans []*Span) error {
+	w, err := t.write()
+	if err != nil {
+		return err
+	}
+	if c.batcher != nil {
+		c.batcher.Add(TraceBatchItem{Trace: t, create: true, write: w})
+	} else if err := c.createTraces(ctx, []api.TraceWrite{w}); err != nil {
+		return err
+	}
+
+	// The spans are created with one request, or queued on the batcher
+	writes := make([]api.SpanWrite, 0, len(spans))
+	for _, s := range spans {
+		w, err := s.write()
+		if err != nil {
+			return err
+		}
+		if c.batcher != nil {
+			c.batcher.Add(SpanBatchItem{Span: s, create: true, write: w})
+		} else {
+			writes = append(writes, w)
+		}
+	}
+	if len(writes) > 0 {
+		if err := c.createSpans(ctx, writes); err != nil {
+			return err
+		}
+	}
+
+	// The trace and spans exist even if an upload fails; the attachments
+	// are uploaded again with their next update
+	for _, s := range spans {
+		_ = s.uploadAttachments(ctx)
+	}
+	return t.uploadAttachments(ctx)
+}
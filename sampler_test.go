@@ -0,0 +1,359 @@
+package opik
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+)
+
+func TestRatioSampler(t *testing.T) {
+	params := func() SamplingParameters {
+		return SamplingParameters{TraceID: uuid.Must(uuid.NewV7()).String()}
+	}
+
+	if NewRatioSampler(0).ShouldSample(params()) != SamplingDrop {
+		t.Error("ratio 0 should drop")
+	}
+	if NewRatioSampler(1).ShouldSample(params()) != SamplingRecord {
+		t.Error("ratio 1 should record")
+	}
+
+	sampler := NewRatioSampler(0.5)
+	sampled := 0
+	for range 2000 {
+		if sampler.ShouldSample(params()) == SamplingRecord {
+			sampled++
+		}
+	}
+	if sampled < 800 || sampled > 1200 {
+		t.Errorf("sampled %d of 2000 traces, want about 1000", sampled)
+	}
+
+	p := params()
+	first := sampler.ShouldSample(p)
+	for range 10 {
+		if sampler.ShouldSample(p) != first {
+			t.Fatal("decision should be stable for a trace ID")
+		}
+	}
+}
+
+func TestRateLimitedSampler(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := NewRateLimitedSampler(2).(*rateLimitedSampler)
+	sampler.now = func() time.Time { return now }
+
+	var decisions []SamplingDecision
+	for range 3 {
+		decisions = append(decisions, sampler.ShouldSample(SamplingParameters{}))
+	}
+	want := []SamplingDecision{SamplingRecord, SamplingRecord, SamplingDrop}
+	for i := range want {
+		if decisions[i] != want[i] {
+			t.Errorf("decision %d = %v, want %v", i, decisions[i], want[i])
+		}
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if sampler.ShouldSample(SamplingParameters{}) != SamplingRecord {
+		t.Error("a token should be refilled after half a second")
+	}
+	if sampler.ShouldSample(SamplingParameters{}) != SamplingDrop {
+		t.Error("only one token should be refilled after half a second")
+	}
+}
+
+func TestRateLimitedSamplerBelowOnePerSecond(t *testing.T) {
+	now := time.Unix(0, 0)
+	sampler := NewRateLimitedSampler(0.5).(*rateLimitedSampler)
+	sampler.now = func() time.Time { return now }
+
+	// One trace every two seconds, starting with the first
+	var sampled int
+	for range 30 {
+		if sampler.ShouldSample(SamplingParameters{}) == SamplingRecord {
+			sampled++
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+	if sampled != 2 {
+		t.Errorf("sampled %d of 30 traces over 3s, want 2", sampled)
+	}
+}
+
+func TestRuleSampler(t *testing.T) {
+	sampler := NewRuleSampler(NeverSample(),
+		SamplingRule{Tag: "debug", Sampler: AlwaysSample()},
+		SamplingRule{ProjectName: "prod", TraceName: "health", Sampler: NeverSample()},
+		SamplingRule{ProjectName: "prod", Sampler: AlwaysSample()},
+	)
+
+	tests := []struct {
+		name   string
+		params SamplingParameters
+		want   SamplingDecision
+	}{
+		{"tag", SamplingParameters{ProjectName: "prod", Name: "health", Tags: []string{"debug"}}, SamplingRecord},
+		{"project and name", SamplingParameters{ProjectName: "prod", Name: "health"}, SamplingDrop},
+		{"project", SamplingParameters{ProjectName: "prod", Name: "chat"}, SamplingRecord},
+		{"fallback", SamplingParameters{ProjectName: "dev", Name: "chat"}, SamplingDrop},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampler.ShouldSample(tt.params); got != tt.want {
+				t.Errorf("ShouldSample() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if NewRuleSampler(nil).ShouldSample(SamplingParameters{}) != SamplingRecord {
+		t.Error("nil fallback should record")
+	}
+}
+
+func TestClientDropsUnsampledTraces(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms, WithSampler(NeverSample()))
+	ctx := context.Background()
+
+	trace, err := client.Trace(ctx, "dropped")
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if trace.Sampled() {
+		t.Error("Sampled() = true, want false")
+	}
+
+	span, err := trace.Span(ctx, "child")
+	if err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+	child, err := span.Span(ctx, "grandchild")
+	if err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+	_ = child.End(ctx)
+	_ = span.End(ctx)
+	_ = trace.End(ctx)
+
+	if n := ms.RequestCount(); n != 0 {
+		t.Errorf("sent %d requests for a dropped trace, want 0", n)
+	}
+}
+
+func TestTailSampler(t *testing.T) {
+	t.Run("fast trace is dropped", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		client := newTestClient(t, ms, WithSampler(NewTailSampler(time.Hour, nil)))
+		ctx := context.Background()
+
+		trace, _ := client.Trace(ctx, "fast")
+		span, _ := trace.Span(ctx, "child")
+		_ = span.End(ctx)
+		_ = trace.End(ctx)
+
+		if n := ms.RequestCount(); n != 0 {
+			t.Errorf("sent %d requests, want 0", n)
+		}
+		if trace.Sampled() {
+			t.Error("Sampled() = true after the tail sampler dropped the trace")
+		}
+	})
+
+	t.Run("errored trace is exported", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		client := newTestClient(t, ms, WithSampler(NewTailSampler(time.Hour, nil)))
+		ctx := context.Background()
+
+		trace, _ := client.Trace(ctx, "failing")
+		ok, _ := trace.Span(ctx, "ok")
+		_ = ok.End(ctx)
+		failed, _ := trace.Span(ctx, "failed")
+		_ = failed.End(ctx, WithSpanError(errors.New("tool failed")))
+
+		if n := ms.RequestCount(); n != 0 {
+			t.Fatalf("sent %d requests before the trace ended, want 0", n)
+		}
+
+		_ = trace.End(ctx)
+
+		if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 1 {
+			t.Errorf("trace creates = %d, want 1", n)
+		}
+		if n := ms.RouteCallCount("POST", "/v1/private/spans/batch"); n != 1 {
+			t.Errorf("span create requests = %d, want 1", n)
+		}
+		if n := len(tracetest.SpanWrites(ms)); n != 2 {
+			t.Errorf("spans created = %d, want 2", n)
+		}
+		if n := ms.RouteCallCount("PATCH", "/v1/private/traces/batch"); n != 0 {
+			t.Errorf("trace updates = %d, want 0", n)
+		}
+	})
+
+	t.Run("slow trace is exported", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		client := newTestClient(t, ms, WithSampler(NewTailSampler(time.Nanosecond, nil)))
+		ctx := context.Background()
+
+		trace, _ := client.Trace(ctx, "slow")
+		time.Sleep(time.Millisecond)
+		_ = trace.End(ctx)
+
+		if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 1 {
+			t.Errorf("trace creates = %d, want 1", n)
+		}
+	})
+
+	t.Run("head sampled trace is exported immediately", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		client := newTestClient(t, ms, WithSampler(NewTailSampler(time.Hour, AlwaysSample())))
+
+		if _, err := client.Trace(context.Background(), "baseline"); err != nil {
+			t.Fatalf("Trace error: %v", err)
+		}
+		if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 1 {
+			t.Errorf("trace creates = %d, want 1", n)
+		}
+	})
+}
+
+// recordingTailSampler defers every trace and records the finished traces
+// it is asked about.
+type recordingTailSampler struct {
+	export   bool
+	finished []FinishedTrace
+}
+
+func (s *recordingTailSampler) ShouldSample(SamplingParameters) SamplingDecision {
+	return SamplingDefer
+}
+
+func (s *recordingTailSampler) ShouldExport(trace FinishedTrace) bool {
+	s.finished = append(s.finished, trace)
+	return s.export
+}
+
+func TestTailSamplingLimits(t *testing.T) {
+	t.Run("spans beyond the limit are dropped", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		sampler := &recordingTailSampler{export: true}
+		client := newTestClient(t, ms, WithSampler(sampler), WithTailSamplingLimits(2, 0))
+		ctx := context.Background()
+
+		trace, _ := client.Trace(ctx, "busy")
+		for i := 0; i < 3; i++ {
+			span, _ := trace.Span(ctx, "child")
+			_ = span.End(ctx)
+		}
+		failed, _ := trace.Span(ctx, "failed")
+		_ = failed.End(ctx, WithSpanError(errors.New("tool failed")))
+		_ = trace.End(ctx)
+
+		if len(sampler.finished) != 1 {
+			t.Fatalf("ShouldExport calls = %d, want 1", len(sampler.finished))
+		}
+		got := sampler.finished[0]
+		if got.SpanCount != 2 || got.DroppedSpans != 2 || !got.Errored || got.Expired {
+			t.Errorf("FinishedTrace = %+v, want 2 spans, 2 dropped, errored", got)
+		}
+
+		if n := ms.RouteCallCount("POST", "/v1/private/spans/batch"); n != 1 {
+			t.Errorf("span create requests = %d, want 1", n)
+		}
+		if n := len(tracetest.SpanWrites(ms)); n != 2 {
+			t.Errorf("spans created = %d, want 2", n)
+		}
+	})
+
+	t.Run("expired trace is decided before it ends", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		sampler := &recordingTailSampler{export: true}
+		client := newTestClient(t, ms, WithSampler(sampler), WithTailSamplingLimits(0, time.Millisecond))
+		ctx := context.Background()
+
+		trace, _ := client.Trace(ctx, "long-running")
+		first, _ := trace.Span(ctx, "first")
+		_ = first.End(ctx)
+		if n := ms.RequestCount(); n != 0 {
+			t.Fatalf("sent %d requests before the trace expired, want 0", n)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		second, _ := trace.Span(ctx, "second")
+
+		if len(sampler.finished) != 1 {
+			t.Fatalf("ShouldExport calls = %d, want 1", len(sampler.finished))
+		}
+		if got := sampler.finished[0]; !got.Expired || got.SpanCount != 2 || got.Duration < time.Millisecond {
+			t.Errorf("FinishedTrace = %+v, want expired with 2 spans", got)
+		}
+		if n := ms.RouteCallCount("POST", "/v1/private/traces/batch"); n != 1 {
+			t.Errorf("trace creates = %d, want 1", n)
+		}
+		if n := ms.RouteCallCount("POST", "/v1/private/spans/batch"); n != 1 {
+			t.Errorf("span create requests = %d, want 1", n)
+		}
+		if n := len(tracetest.SpanWrites(ms)); n != 2 {
+			t.Errorf("spans created = %d, want 2", n)
+		}
+
+		// The trace is recorded from now on
+		_ = second.End(ctx)
+		_ = trace.End(ctx)
+		if n := ms.RouteCallCount("PATCH", "/v1/private/spans/batch"); n != 1 {
+			t.Errorf("span updates = %d, want 1", n)
+		}
+		if n := ms.RouteCallCount("PATCH", "/v1/private/traces/batch"); n != 1 {
+			t.Errorf("trace updates = %d, want 1", n)
+		}
+		if len(sampler.finished) != 1 {
+			t.Errorf("ShouldExport calls = %d, want 1", len(sampler.finished))
+		}
+	})
+
+	t.Run("expired trace is dropped", func(t *testing.T) {
+		ms := tracetest.NewServer()
+		defer ms.Close()
+
+		sampler := &recordingTailSampler{export: false}
+		client := newTestClient(t, ms, WithSampler(sampler), WithTailSamplingLimits(0, time.Millisecond))
+		ctx := context.Background()
+
+		trace, _ := client.Trace(ctx, "long-running")
+		time.Sleep(5 * time.Millisecond)
+		_ = trace.Update(ctx, WithTraceMetadata(map[string]any{"step": 1}))
+
+		if trace.Sampled() {
+			t.Error("Sampled() = true after the expired trace was dropped")
+		}
+		span, _ := trace.Span(ctx, "late")
+		_ = span.End(ctx)
+		_ = trace.End(ctx)
+		if n := ms.RequestCount(); n != 0 {
+			t.Errorf("sent %d requests, want 0", n)
+		}
+		if len(sampler.finished) != 1 || !sampler.finished[0].Expired {
+			t.Errorf("finished = %+v, want one expired trace", sampler.finished)
+		}
+	})
+}
@@ -0,0 +1,278 @@
+package opik
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attributes added to log records by LogHandler.
+const (
+	LogKeyTraceID = "opik.trace_id"
+	LogKeySpanID  = "opik.span_id"
+)
+
+// Metadata keys holding the log records attached to a trace or span, and
+// the number of records dropped to stay within the limit.
+const (
+	logEventsKey        = "events"
+	logEventsDroppedKey = "events_dropped"
+)
+
+// DefaultLogEventLimit is the default maximum number of log records attached
+// to a trace or span.
+const DefaultLogEventLimit = 100
+
+// LogHandler is an slog.Handler that correlates log records with Opik
+// traces. It adds the IDs of the trace and span in the record's context as
+// opik.trace_id and opik.span_id attributes, and passes the record on to the
+// wrapped handler. Log with the context variants of the slog functions, such
+// as slog.InfoContext, so that the handler sees the span.
+//
+// With WithLogEvents, records at or above a level are also attached to the
+// current span, or the current trace if there is no span, and sent under the
+// "events" metadata key with the next Update or End. Records logged after
+// the span or trace has ended are not attached.
+type LogHandler struct {
+	next   slog.Handler
+	events slog.Leveler // nil when records are not attached
+	limit  int
+
+	// goas are the groups and attributes added with WithGroup and
+	// WithAttrs, in order, for attached records
+	goas []groupOrAttrs
+}
+
+// groupOrAttrs is a group name or a list of attributes.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// LogHandlerOption configures a LogHandler.
+type LogHandlerOption func(*LogHandler)
+
+// WithLogEvents attaches records at or above level to the current span or
+// trace.
+func WithLogEvents(level slog.Leveler) LogHandlerOption {
+	return func(h *LogHandler) {
+		h.events = level
+	}
+}
+
+// WithLogEventLimit sets the maximum number of records attached to a span
+// or trace. Beyond it the oldest records are dropped, and their number is
+// sent under the "events_dropped" metadata key. It defaults to
+// DefaultLogEventLimit.
+func WithLogEventLimit(n int) LogHandlerOption {
+	return func(h *LogHandler) {
+		if n > 0 {
+			h.limit = n
+		}
+	}
+}
+
+// NewLogHandler wraps next with a handler that correlates log records with
+// Opik traces.
+func NewLogHandler(next slog.Handler, opts ...LogHandlerOption) *LogHandler {
+	h := &LogHandler{next: next, limit: DefaultLogEventLimit}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Enabled reports whether the wrapped handler handles records at level, or
+// whether they are attached to spans.
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || h.attaches(level)
+}
+
+func (h *LogHandler) attaches(level slog.Level) bool {
+	return h.events != nil && level >= h.events.Level()
+}
+
+// Handle adds the trace and span IDs to r, attaches it to the current span
+// if enabled, and passes it to the wrapped handler.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.attaches(r.Level) {
+		event := h.event(r)
+		if span := SpanFromContext(ctx); span != nil {
+			span.addEvent(event, r, h.limit)
+		} else if trace := TraceFromContext(ctx); trace != nil {
+			trace.addEvent(event, r, h.limit)
+		}
+	}
+
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	if traceID := CurrentTraceID(ctx); traceID != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String(LogKeyTraceID, traceID))
+		if spanID := CurrentSpanID(ctx); spanID != "" {
+			r.AddAttrs(slog.String(LogKeySpanID, spanID))
+		}
+	}
+	return h.next.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler whose records include attrs.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	h2.goas = append(h.goas[:len(h.goas):len(h.goas)], groupOrAttrs{attrs: attrs})
+	return &h2
+}
+
+// WithGroup returns a handler that qualifies later attributes with name.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	h2.goas = append(h.goas[:len(h.goas):len(h.goas)], groupOrAttrs{group: name})
+	return &h2
+}
+
+// event converts r to the metadata event attached to a span.
+func (h *LogHandler) event(r slog.Record) map[string]any {
+	attrs := make(map[string]any)
+	current := attrs
+	for _, goa := range h.goas {
+		if goa.group != "" {
+			group := make(map[string]any)
+			current[goa.group] = group
+			current = group
+			continue
+		}
+		addLogAttrs(current, goa.attrs)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addLogAttrs(current, []slog.Attr{a})
+		return true
+	})
+
+	event := map[string]any{
+		"time":    r.Time,
+		"level":   r.Level.String(),
+		"message": r.Message,
+	}
+	if len(attrs) > 0 {
+		event["attributes"] = attrs
+	}
+	return event
+}
+
+// addLogAttrs adds attrs to m, with groups as nested maps.
+func addLogAttrs(m map[string]any, attrs []slog.Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() != slog.KindGroup {
+			m[a.Key] = a.Value.Any()
+			continue
+		}
+		group := m
+		if a.Key != "" {
+			group = make(map[string]any)
+			m[a.Key] = group
+		}
+		addLogAttrs(group, a.Value.Group())
+	}
+}
+
+// logEvents holds the log records attached to a trace or span. They can be
+// added from any goroutine.
+type logEvents struct {
+	mu      sync.Mutex
+	events  []map[string]any
+	dropped int
+	ended   bool
+}
+
+// add attaches event, dropping the oldest events beyond limit. It reports
+// false, without attaching event, once the trace or span has ended.
+func (l *logEvents) add(event map[string]any, limit int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ended {
+		return false
+	}
+	if n := len(l.events) + 1 - limit; n > 0 {
+		n = min(n, len(l.events))
+		l.events = append(l.events[:0], l.events[n:]...)
+		l.dropped += n
+	}
+	l.events = append(l.events, event)
+	return true
+}
+
+// end stops attaching events, when the trace or span ends.
+func (l *logEvents) end() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ended = true
+}
+
+// metadata returns metadata with the attached events added, without
+// modifying it.
+func (l *logEvents) metadata(metadata map[string]any) map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.events) == 0 {
+		return metadata
+	}
+	metadata = maps.Clone(metadata)
+	if metadata == nil {
+		metadata = make(map[string]any, 1)
+	}
+	metadata[logEventsKey] = append([]map[string]any(nil), l.events...)
+	if l.dropped > 0 {
+		metadata[logEventsDroppedKey] = l.dropped
+	}
+	return metadata
+}
+
+// addEvent attaches a log record to the span, and to the OpenTelemetry span
+// mirroring it, unless the span has ended.
+func (s *Span) addEvent(event map[string]any, r slog.Record, limit int) {
+	if s.logs.add(event, limit) {
+		addOTelLogEvent(s.otelSpan, r)
+	}
+}
+
+// addEvent attaches a log record to the trace, and to the OpenTelemetry span
+// mirroring it, unless the trace has ended.
+func (t *Trace) addEvent(event map[string]any, r slog.Record, limit int) {
+	if t.logs.add(event, limit) {
+		addOTelLogEvent(t.otelSpan, r)
+	}
+}
+
+// addOTelLogEvent adds a log record to span as an event, if span is not nil.
+func addOTelLogEvent(span trace.Span, r slog.Record) {
+	if span == nil {
+		return
+	}
+	attrs := []attribute.KeyValue{attribute.String("log.severity", r.Level.String())}
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, attribute.String(a.Key, a.Value.Resolve().String()))
+		return true
+	})
+	opts := []trace.EventOption{trace.WithAttributes(attrs...)}
+	if !r.Time.IsZero() {
+		opts = append(opts, trace.WithTimestamp(r.Time))
+	}
+	span.AddEvent(r.Message, opts...)
+}
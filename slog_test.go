@@ -0,0 +1,114 @@
+package opik
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+)
+
+func TestLogHandlerAddsIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewLogHandler(slog.NewJSONHandler(&buf, nil)))
+
+	ctx := ContextWithTrace(context.Background(), &Trace{id: "trace-123"})
+	ctx = ContextWithSpan(ctx, &Span{id: "span-456", traceID: "trace-123"})
+	logger.InfoContext(ctx, "hello", "user", "alice")
+	logger.InfoContext(context.Background(), "untraced")
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2", len(lines))
+	}
+	var traced, untraced map[string]any
+	if err := json.Unmarshal(lines[0], &traced); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(lines[1], &untraced); err != nil {
+		t.Fatal(err)
+	}
+	if traced[LogKeyTraceID] != "trace-123" || traced[LogKeySpanID] != "span-456" || traced["user"] != "alice" {
+		t.Errorf("traced record = %v", traced)
+	}
+	if _, ok := untraced[LogKeyTraceID]; ok {
+		t.Errorf("untraced record = %v", untraced)
+	}
+}
+
+func TestLogHandlerAttachesEvents(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+
+	var buf bytes.Buffer
+	next := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelError})
+	logger := slog.New(NewLogHandler(next, WithLogEvents(slog.LevelWarn))).
+		With("component", "retriever").WithGroup("request")
+
+	span, err := client.createSpan(context.Background(), testTraceID, "", client.ProjectName(), nil, "retrieve")
+	if err != nil {
+		t.Fatalf("createSpan error: %v", err)
+	}
+	ctx := ContextWithSpan(context.Background(), span)
+	logger.InfoContext(ctx, "cache hit")
+	logger.WarnContext(ctx, "slow query", "ms", 1200)
+
+	if err := span.End(ctx); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrapped handler logged %q below its level", buf.String())
+	}
+
+	metadata, _ := lastItem(t, tracetest.SpanUpdates(ms))["metadata"].(map[string]any)
+	events, _ := metadata["events"].([]any)
+	if len(events) != 1 {
+		t.Fatalf("events = %v, want 1", metadata["events"])
+	}
+	event := events[0].(map[string]any)
+	if event["message"] != "slow query" || event["level"] != "WARN" || event["time"] == nil {
+		t.Errorf("event = %v", event)
+	}
+	attrs, _ := json.Marshal(event["attributes"])
+	if string(attrs) != `{"component":"retriever","request":{"ms":1200}}` {
+		t.Errorf("event attributes = %s", attrs)
+	}
+}
+
+func TestLogHandlerCapsEvents(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+	logger := slog.New(NewLogHandler(slog.DiscardHandler, WithLogEvents(slog.LevelInfo), WithLogEventLimit(2)))
+
+	span, err := client.createSpan(context.Background(), testTraceID, "", client.ProjectName(), nil, "retrieve")
+	if err != nil {
+		t.Fatalf("createSpan error: %v", err)
+	}
+	ctx := ContextWithSpan(context.Background(), span)
+	for _, msg := range []string{"first", "second", "third", "fourth"} {
+		logger.InfoContext(ctx, msg)
+	}
+	if err := span.End(ctx); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	// Records logged after End are ignored
+	logger.InfoContext(ctx, "late")
+
+	metadata, _ := lastItem(t, tracetest.SpanUpdates(ms))["metadata"].(map[string]any)
+	events, _ := metadata["events"].([]any)
+	if len(events) != 2 || events[0].(map[string]any)["message"] != "third" || events[1].(map[string]any)["message"] != "fourth" {
+		t.Errorf("events = %v, want the last two", metadata["events"])
+	}
+	if metadata["events_dropped"] != float64(2) {
+		t.Errorf("events_dropped = %v, want 2", metadata["events_dropped"])
+	}
+	if got := span.logs.metadata(nil)["events"].([]map[string]any); len(got) != 2 {
+		t.Errorf("attached %d events after End, want 2", len(got))
+	}
+}
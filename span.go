@@ -2,11 +2,11 @@ package opik
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	oteltrace "go.opentelemetry.io/otel/trace"
 
 	"github.com/agentplexus/go-opik/internal/api"
 )
@@ -18,6 +18,7 @@ type Span struct {
 	traceID      string
 	parentSpanID string
 	name         string
+	projectName  string
 	spanType     string
 	startTime    time.Time
 	endTime      *time.Time
@@ -28,6 +29,13 @@ type Span struct {
 	model        string
 	provider     string
 	usage        map[string]int
+	totalCost    *float64
+	errorInfo    *ErrorInfo
+	comments     []Comment
+	attachments  []*Attachment
+	sampling     *samplingState
+	otelSpan     oteltrace.Span // mirrors the span when the client has a tracer
+	logs         logEvents
 	ended        bool
 }
 
@@ -82,135 +90,250 @@ func (s *Span) End(ctx context.Context, opts ...SpanOption) error {
 	endTime := time.Now()
 	s.endTime = &endTime
 	s.ended = true
+	s.logs.end()
 
-	// Merge output and metadata
+	s.apply(options)
+	s.endOTelSpan()
+
+	return s.client.sendSpan(ctx, s, false)
+}
+
+// Update updates the span with new data.
+func (s *Span) Update(ctx context.Context, opts ...SpanOption) error {
+	options := &spanOptions{
+		metadata: make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	s.apply(options)
+
+	return s.client.sendSpan(ctx, s, false)
+}
+
+// apply merges the name, output, metadata, tags, model and provider from
+// options into the span.
+func (s *Span) apply(options *spanOptions) {
+	if options.name != "" {
+		s.name = options.name
+	}
 	if options.output != nil {
-		s.output = options.output
+		s.output = s.extractAttachments("output", options.output)
+	}
+	if s.metadata == nil {
+		s.metadata = make(map[string]any)
 	}
 	for k, v := range options.metadata {
 		s.metadata[k] = v
 	}
+	if len(options.tags) > 0 {
+		s.tags = options.tags
+	}
 	if options.model != "" {
 		s.model = options.model
 	}
 	if options.provider != "" {
 		s.provider = options.provider
 	}
+	if options.usage != nil {
+		s.usage = options.usage
+	}
+	if options.cost != nil {
+		s.totalCost = options.cost
+	}
+	if options.errorInfo != nil {
+		s.errorInfo = options.errorInfo
+	}
+	s.attachments = append(s.attachments, options.attachments...)
+}
 
-	// Prepare update request
+// write builds the create payload for the span's current state.
+func (s *Span) write() (api.SpanWrite, error) {
 	spanUUID, err := uuid.Parse(s.id)
 	if err != nil {
-		return err
+		return api.SpanWrite{}, err
 	}
-
 	traceUUID, err := uuid.Parse(s.traceID)
 	if err != nil {
-		return err
+		return api.SpanWrite{}, err
 	}
 
-	// IMPORTANT: JsonListString fields must be set to valid JSON (including "null")
-	// An empty JsonListString produces malformed JSON in the generated encoder.
-	nullJSON := api.JsonListString([]byte("null"))
-
-	outputJSON := nullJSON
-	if s.output != nil {
-		data, _ := json.Marshal(s.output)
-		outputJSON = api.JsonListString(data)
+	w := api.SpanWrite{
+		ID:          api.NewOptUUID(spanUUID),
+		ProjectName: api.NewOptString(s.projectName),
+		TraceID:     api.NewOptUUID(traceUUID),
+		Name:        api.NewOptString(s.name),
+		Type:        api.NewOptSpanWriteType(api.SpanWriteType(s.spanType)),
+		StartTime:   s.startTime,
+		Input:       api.JsonListStringWrite(jsonOrNull(s.client.redact(RedactFieldInput, s.input))),
+		Output:      api.JsonListStringWrite(jsonOrNull(s.client.redact(RedactFieldOutput, s.output))),
+		Metadata:    api.JsonListStringWrite(metadataJSON(s.client.redactMetadata(s.logs.metadata(s.metadata)))),
+		Tags:        s.client.redactTags(s.tags),
+		Model:       api.NewOptString(s.model),
+		Provider:    api.NewOptString(s.provider),
+	}
+	if s.parentSpanID != "" {
+		parentUUID, err := uuid.Parse(s.parentSpanID)
+		if err != nil {
+			return api.SpanWrite{}, err
+		}
+		w.ParentSpanID = api.NewOptUUID(parentUUID)
 	}
-
-	metadataJSON := nullJSON
-	if len(s.metadata) > 0 {
-		data, _ := json.Marshal(s.metadata)
-		metadataJSON = api.JsonListString(data)
+	if s.endTime != nil {
+		w.EndTime = api.NewOptDateTime(*s.endTime)
+	}
+	if s.errorInfo != nil {
+		w.ErrorInfo = api.NewOptErrorInfoWrite(s.errorInfo.write())
 	}
+	if len(s.usage) > 0 {
+		usage := make(api.SpanWriteUsage, len(s.usage))
+		for k, v := range s.usage {
+			usage[k] = int32(v) //nolint:gosec // G115: token counts fit in int32
+		}
+		w.Usage = api.NewOptSpanWriteUsage(usage)
+	}
+	if s.totalCost != nil {
+		w.TotalEstimatedCost = api.NewOptFloat64(*s.totalCost)
+	} else if pricing := s.pricing(); pricing != nil && len(s.usage) > 0 {
+		if cost, ok := pricing.EstimateCost(s.provider, s.model, s.usage); ok {
+			w.TotalEstimatedCost = api.NewOptFloat64(cost)
+			if version := pricing.Version(); version != "" {
+				w.TotalEstimatedCostVersion = api.NewOptString(version)
+			}
+		}
+	}
+	return w, nil
+}
 
-	// Create update request - SpanBatchUpdate uses Ids + single Update
-	req := api.SpanBatchUpdate{
-		Ids: []uuid.UUID{spanUUID},
-		Update: api.SpanUpdate{
-			TraceID:  traceUUID,
-			EndTime:  api.NewOptDateTime(endTime),
-			Input:    nullJSON, // Required field, must be valid JSON
-			Output:   outputJSON,
-			Metadata: metadataJSON,
-			Model:    api.NewOptString(s.model),
-			Provider: api.NewOptString(s.provider),
-		},
+// pricing returns the client's pricing registry, or nil if none is configured.
+func (s *Span) pricing() *PricingRegistry {
+	if s.client == nil {
+		return nil
 	}
+	return s.client.pricing
+}
 
-	_, err = s.client.apiClient.BatchUpdateSpans(ctx, api.NewOptSpanBatchUpdate(req))
-	return err
+// spanUpdateFromWrite converts a create payload into the equivalent update payload.
+func spanUpdateFromWrite(w api.SpanWrite) api.SpanUpdate {
+	u := api.SpanUpdate{
+		ProjectName:  w.ProjectName,
+		TraceID:      w.TraceID.Value,
+		ParentSpanID: w.ParentSpanID,
+		Name:         w.Name,
+		EndTime:      w.EndTime,
+		Input:        api.JsonListString(w.Input),
+		Output:       api.JsonListString(w.Output),
+		Metadata:     api.JsonListString(w.Metadata),
+		Model:        w.Model,
+		Provider:     w.Provider,
+		Tags:         w.Tags,
+	}
+	if w.Type.Set {
+		u.Type = api.NewOptSpanUpdateType(api.SpanUpdateType(w.Type.Value))
+	}
+	if w.Usage.Set {
+		u.Usage = api.NewOptSpanUpdateUsage(api.SpanUpdateUsage(w.Usage.Value))
+	}
+	u.TotalEstimatedCost = w.TotalEstimatedCost
+	if w.ErrorInfo.Set {
+		u.ErrorInfo = api.NewOptErrorInfo(api.ErrorInfo(w.ErrorInfo.Value))
+	}
+	return u
 }
 
-// Update updates the span with new data.
-func (s *Span) Update(ctx context.Context, opts ...SpanOption) error {
-	options := &spanOptions{
-		metadata: make(map[string]any),
-	}
-	for _, opt := range opts {
-		opt(options)
+// sendSpan sends the span's current state to the API, either as a create
+// or as an update. When the client has a batcher, the operation is queued and
+// sent asynchronously.
+func (c *Client) sendSpan(ctx context.Context, s *Span, create bool) error {
+	switch admission, spans := s.sampling.admitSpan(s, create); admission {
+	case admitSkip:
+		return nil
+	case admitExport:
+		return c.exportDeferred(ctx, s.sampling.trace, spans)
 	}
 
-	spanUUID, err := uuid.Parse(s.id)
+	w, err := s.write()
 	if err != nil {
 		return err
 	}
 
-	traceUUID, err := uuid.Parse(s.traceID)
+	switch {
+	case c.batcher != nil:
+		c.batcher.Add(SpanBatchItem{Span: s, create: create, write: w})
+	case create:
+		err = c.createSpans(ctx, []api.SpanWrite{w})
+	default:
+		err = c.updateSpans(ctx, []uuid.UUID{w.ID.Value}, spanUpdateFromWrite(w))
+	}
 	if err != nil {
 		return err
 	}
 
-	// Merge metadata
-	for k, v := range options.metadata {
-		s.metadata[k] = v
-	}
-	if options.output != nil {
-		s.output = options.output
-	}
-	if options.model != "" {
-		s.model = options.model
-	}
-	if options.provider != "" {
-		s.provider = options.provider
+	err = s.uploadAttachments(ctx)
+	if create {
+		// The span exists even if an upload failed; the attachments are
+		// uploaded again with its next update
+		return nil
 	}
+	return err
+}
 
-	// IMPORTANT: JsonListString fields must be set to valid JSON (including "null")
-	// An empty JsonListString produces malformed JSON in the generated encoder.
-	nullJSON := api.JsonListString([]byte("null"))
+// uploadAttachments uploads the attachments added since the span was last
+// sent. Attachments that could not be uploaded are kept for the next send.
+func (s *Span) uploadAttachments(ctx context.Context) error {
+	pending := s.attachments
+	s.attachments = nil
+	failed, err := s.client.uploadAttachments(ctx, AttachmentEntitySpan, s.id, s.projectName, pending)
+	s.attachments = append(failed, s.attachments...)
+	return err
+}
 
-	outputJSON := nullJSON
-	if s.output != nil {
-		data, _ := json.Marshal(s.output)
-		outputJSON = api.JsonListString(data)
-	}
+// extractAttachments moves inline media in value into pending attachments.
+func (s *Span) extractAttachments(field string, value any) any {
+	value, extracted := s.client.extractAttachments(field, value)
+	s.attachments = append(s.attachments, extracted...)
+	return value
+}
 
-	metadataJSON := nullJSON
-	if len(s.metadata) > 0 {
-		data, _ := json.Marshal(s.metadata)
-		metadataJSON = api.JsonListString(data)
+// AddAttachment uploads an attachment to this span. With a BatchingClient,
+// the upload is queued and failures are reported through
+// BatcherConfig.OnError. If the trace is buffered by a tail sampler, the
+// upload waits until the trace is exported.
+func (s *Span) AddAttachment(ctx context.Context, attachment *Attachment) error {
+	if !s.sampling.recording() {
+		s.attachments = append(s.attachments, attachment)
+		return nil
 	}
+	_, err := s.client.uploadAttachments(ctx, AttachmentEntitySpan, s.id, s.projectName, []*Attachment{attachment})
+	return err
+}
 
-	req := api.SpanBatchUpdate{
-		Ids: []uuid.UUID{spanUUID},
-		Update: api.SpanUpdate{
-			TraceID:  traceUUID,
-			Input:    nullJSON, // Required field, must be valid JSON
-			Output:   outputJSON,
-			Metadata: metadataJSON,
-			Model:    api.NewOptString(s.model),
-			Provider: api.NewOptString(s.provider),
-			Tags:     options.tags,
-		},
-	}
+// createSpans creates spans with a single batch request.
+func (c *Client) createSpans(ctx context.Context, spans []api.SpanWrite) error {
+	return c.apiClient.CreateSpans(ctx, api.NewOptSpanBatchWrite(api.SpanBatchWrite{
+		Spans: spans,
+	}))
+}
 
-	_, err = s.client.apiClient.BatchUpdateSpans(ctx, api.NewOptSpanBatchUpdate(req))
-	return err
+// updateSpans applies an update to spans via the batch update endpoint.
+func (c *Client) updateSpans(ctx context.Context, ids []uuid.UUID, update api.SpanUpdate) error {
+	res, err := c.apiClient.BatchUpdateSpans(ctx, api.NewOptSpanBatchUpdate(api.SpanBatchUpdate{
+		Ids:    ids,
+		Update: update,
+	}))
+	if err != nil {
+		return err
+	}
+	if msg, ok := res.(*api.ErrorMessage); ok {
+		return newAPIError(400, msg)
+	}
+	return nil
 }
 
 // Span creates a child span within this span.
 func (s *Span) Span(ctx context.Context, name string, opts ...SpanOption) (*Span, error) {
-	return s.client.createSpan(ctx, s.traceID, s.id, name, opts...)
+	return s.client.createSpan(withOTelSpan(ctx, s.otelSpan), s.traceID, s.id, s.projectName, s.sampling, name, opts...)
 }
 
 // AddFeedbackScore adds a feedback score to this span.
@@ -219,12 +342,17 @@ func (s *Span) AddFeedbackScore(ctx context.Context, name string, value float64,
 	if err != nil {
 		return err
 	}
+	category, err := s.client.checkFeedbackScore(name, value)
+	if err != nil {
+		return err
+	}
 
 	req := api.FeedbackScore{
-		Name:   name,
-		Value:  value,
-		Reason: api.NewOptString(reason),
-		Source: api.FeedbackScoreSourceSdk,
+		Name:         name,
+		CategoryName: optString(category),
+		Value:        value,
+		Reason:       api.NewOptString(s.client.redactReason(reason)),
+		Source:       api.FeedbackScoreSourceSdk,
 	}
 
 	return s.client.apiClient.AddSpanFeedbackScore(ctx, api.NewOptFeedbackScore(req), api.AddSpanFeedbackScoreParams{
@@ -232,105 +360,126 @@ func (s *Span) AddFeedbackScore(ctx context.Context, name string, value float64,
 	})
 }
 
+// DeleteFeedbackScores deletes the named feedback scores from this span.
+func (s *Span) DeleteFeedbackScores(ctx context.Context, names ...string) error {
+	return s.client.DeleteSpanFeedbackScores(ctx, s.id, names...)
+}
+
 // SetUsage sets LLM usage metrics for this span.
+// The usage is sent with the next Update or End.
 func (s *Span) SetUsage(usage map[string]int) {
 	s.usage = usage
 }
 
-// createSpan is a helper to create spans (used by both Client and Trace).
-func (c *Client) createSpan(ctx context.Context, traceID, parentSpanID, name string, opts ...SpanOption) (*Span, error) {
-	if c.config.TracingDisabled {
-		return nil, ErrTracingDisabled
-	}
+// Usage returns the LLM usage metrics for this span.
+func (s *Span) Usage() map[string]int {
+	return s.usage
+}
 
-	options := defaultSpanOptions()
-	for _, opt := range opts {
-		opt(options)
-	}
+// SetTotalCost sets the estimated cost of this span in USD.
+// The cost is sent with the next Update or End.
+func (s *Span) SetTotalCost(cost float64) {
+	s.totalCost = &cost
+}
 
-	// Generate span ID (must be UUID v7 for Opik API)
-	spanUUID, err := uuid.NewV7()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate span UUID: %w", err)
+// RecordError records err on this span, marking it as failed.
+// The error is sent with the next Update or End.
+func (s *Span) RecordError(err error) {
+	if info := NewErrorInfo(err); info != nil {
+		s.errorInfo = info
 	}
-	spanID := spanUUID.String()
-	traceUUID, err := uuid.Parse(traceID)
+}
+
+// ErrorInfo returns the error recorded on this span, or nil if none.
+func (s *Span) ErrorInfo() *ErrorInfo {
+	return s.errorInfo
+}
+
+// Comments returns the comments added to this span.
+func (s *Span) Comments() []Comment {
+	return s.comments
+}
+
+// AddComment adds a comment to this span.
+func (s *Span) AddComment(ctx context.Context, text string) (*Comment, error) {
+	comment, err := s.client.AddSpanComment(ctx, s.id, text)
 	if err != nil {
 		return nil, err
 	}
+	s.comments = append(s.comments, *comment)
+	return comment, nil
+}
 
-	// Prepare input/output/metadata as JSON
-	// Note: JsonListStringWrite is raw JSON bytes - use null for empty values
-	nullJSON := api.JsonListStringWrite([]byte("null"))
-	inputJSON := nullJSON
-	outputJSON := nullJSON
-	metadataJSON := nullJSON
-
-	if options.input != nil {
-		data, _ := json.Marshal(options.input)
-		inputJSON = api.JsonListStringWrite(data)
-	}
-	if options.output != nil {
-		data, _ := json.Marshal(options.output)
-		outputJSON = api.JsonListStringWrite(data)
+// UpdateComment replaces the text of a comment on this span.
+func (s *Span) UpdateComment(ctx context.Context, commentID, text string) error {
+	if err := s.client.UpdateSpanComment(ctx, commentID, text); err != nil {
+		return err
 	}
-	if len(options.metadata) > 0 {
-		data, _ := json.Marshal(options.metadata)
-		metadataJSON = api.JsonListStringWrite(data)
+	for i := range s.comments {
+		if s.comments[i].ID == commentID {
+			s.comments[i].Text = text
+		}
 	}
+	return nil
+}
 
-	startTime := time.Now()
-
-	// Determine span type
-	spanType := api.SpanWriteType(options.spanType)
+// DeleteComments deletes comments from this span.
+func (s *Span) DeleteComments(ctx context.Context, commentIDs ...string) error {
+	if err := s.client.DeleteSpanComments(ctx, commentIDs...); err != nil {
+		return err
+	}
+	s.comments = removeComments(s.comments, commentIDs)
+	return nil
+}
 
-	// Create span request
-	spanWrite := api.SpanWrite{
-		ID:          api.NewOptUUID(spanUUID),
-		ProjectName: api.NewOptString(c.ProjectName()),
-		TraceID:     api.NewOptUUID(traceUUID),
-		Name:        api.NewOptString(name),
-		Type:        api.NewOptSpanWriteType(spanType),
-		StartTime:   startTime,
-		Input:       inputJSON,
-		Output:      outputJSON,
-		Metadata:    metadataJSON,
-		Tags:        options.tags,
-		Model:       api.NewOptString(options.model),
-		Provider:    api.NewOptString(options.provider),
-	}
-
-	if parentSpanID != "" {
-		parentUUID, err := uuid.Parse(parentSpanID)
-		if err != nil {
-			return nil, err
-		}
-		spanWrite.ParentSpanID = api.NewOptUUID(parentUUID)
+// createSpan is a helper to create spans (used by both Client and Trace).
+// Spans share the project and sampling state of their trace.
+func (c *Client) createSpan(ctx context.Context, traceID, parentSpanID, projectName string, sampling *samplingState, name string, opts ...SpanOption) (*Span, error) {
+	if c.config.TracingDisabled {
+		return nil, ErrTracingDisabled
 	}
 
-	req := api.SpanBatchWrite{
-		Spans: []api.SpanWrite{spanWrite},
+	options := defaultSpanOptions()
+	for _, opt := range opts {
+		opt(options)
 	}
 
-	// Send to API
-	err = c.apiClient.CreateSpans(ctx, api.NewOptSpanBatchWrite(req))
+	// Generate span ID (must be UUID v7 for Opik API)
+	spanUUID, err := uuid.NewV7()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to generate span UUID: %w", err)
 	}
 
-	return &Span{
+	startTime := time.Now()
+	otelSpan, metadata := c.startOTelSpan(ctx, name, startTime, otelSpanKind(options.spanType), options.metadata)
+
+	span := &Span{
 		client:       c,
-		id:           spanID,
+		id:           spanUUID.String(),
 		traceID:      traceID,
 		parentSpanID: parentSpanID,
 		name:         name,
+		projectName:  projectName,
 		spanType:     options.spanType,
 		startTime:    startTime,
-		input:        options.input,
-		output:       options.output,
-		metadata:     options.metadata,
+		metadata:     metadata,
 		tags:         options.tags,
 		model:        options.model,
 		provider:     options.provider,
-	}, nil
+		usage:        options.usage,
+		totalCost:    options.cost,
+		errorInfo:    options.errorInfo,
+		attachments:  options.attachments,
+		sampling:     sampling,
+		otelSpan:     otelSpan,
+	}
+	span.input = span.extractAttachments("input", options.input)
+	span.output = span.extractAttachments("output", options.output)
+
+	if err := c.sendSpan(ctx, span, true); err != nil {
+		abortOTelSpan(otelSpan, err)
+		return nil, err
+	}
+
+	return span, nil
 }
@@ -395,3 +395,71 @@ func TestSpanHierarchy(t *testing.T) {
 		t.Error("all spans should have same trace ID")
 	}
 }
+
+func TestSpanWriteUsageAndCost(t *testing.T) {
+	pricing := NewPricingRegistry()
+	pricing.SetVersion("v1")
+	pricing.Register("openai", "gpt-4o", ModelPricing{InputPerMillion: 1, OutputPerMillion: 2})
+
+	newSpan := func() *Span {
+		return &Span{
+			client:   &Client{pricing: pricing},
+			id:       "01900000-0000-7000-8000-000000000002",
+			traceID:  "01900000-0000-7000-8000-000000000001",
+			spanType: SpanTypeLLM,
+			model:    "gpt-4o",
+			provider: "openai",
+		}
+	}
+
+	t.Run("estimated from pricing", func(t *testing.T) {
+		span := newSpan()
+		span.SetUsage(map[string]int{UsagePromptTokens: 1000, UsageCompletionTokens: 1000})
+
+		w, err := span.write()
+		if err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		if !w.Usage.Set || w.Usage.Value[UsagePromptTokens] != 1000 {
+			t.Errorf("Usage = %v, want prompt_tokens=1000", w.Usage.Value)
+		}
+		if !w.TotalEstimatedCost.Set || !floatNear(w.TotalEstimatedCost.Value, 0.003) {
+			t.Errorf("TotalEstimatedCost = %v, want 0.003", w.TotalEstimatedCost.Value)
+		}
+		if w.TotalEstimatedCostVersion.Value != "v1" {
+			t.Errorf("TotalEstimatedCostVersion = %q, want v1", w.TotalEstimatedCostVersion.Value)
+		}
+
+		u := spanUpdateFromWrite(w)
+		if !u.Usage.Set || !u.TotalEstimatedCost.Set {
+			t.Error("update should carry usage and cost")
+		}
+	})
+
+	t.Run("explicit cost wins", func(t *testing.T) {
+		span := newSpan()
+		span.SetUsage(map[string]int{UsagePromptTokens: 1000})
+		span.SetTotalCost(1.5)
+
+		w, err := span.write()
+		if err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		if w.TotalEstimatedCost.Value != 1.5 {
+			t.Errorf("TotalEstimatedCost = %v, want 1.5", w.TotalEstimatedCost.Value)
+		}
+		if w.TotalEstimatedCostVersion.Set {
+			t.Error("explicit cost should not report a pricing version")
+		}
+	})
+
+	t.Run("no usage", func(t *testing.T) {
+		w, err := newSpan().write()
+		if err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+		if w.Usage.Set || w.TotalEstimatedCost.Set {
+			t.Error("span without usage should not report usage or cost")
+		}
+	})
+}
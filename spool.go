@@ -0,0 +1,451 @@
+package opik
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpoolSync controls when writes to the spool are flushed to stable storage.
+type SpoolSync int
+
+const (
+	// SpoolSyncAlways syncs after every write, so spooled items survive a
+	// crash of the process or the machine.
+	SpoolSyncAlways SpoolSync = iota
+	// SpoolSyncOnRotate syncs when a segment is full and when the spool is
+	// closed. Items written since the last sync can be lost if the machine
+	// crashes, but not if only the process does.
+	SpoolSyncOnRotate
+	// SpoolSyncNever leaves syncing to the operating system.
+	SpoolSyncNever
+)
+
+// Default spool limits.
+const (
+	DefaultSpoolMaxBytes       = 256 << 20
+	DefaultSpoolSegmentBytes   = 8 << 20
+	DefaultSpoolReplayInterval = 30 * time.Second
+)
+
+// SpoolConfig configures the on-disk spool of a Batcher.
+//
+// Trace, span and feedback operations that cannot be delivered because the
+// server is unreachable, rate limiting or failing are written to the spool
+// instead of being dropped. While the spool holds items, new operations are
+// appended to it as well, so that they reach the server in the order they
+// were made. The spool is replayed when the batcher starts and then every
+// ReplayInterval until it is empty.
+//
+// A spool directory must not be shared by several batchers at a time.
+type SpoolConfig struct {
+	// Dir is the directory holding the spool segments. It is created if
+	// it does not exist.
+	Dir string
+	// MaxBytes caps the total size of the spool. Items that do not fit are
+	// reported through BatcherConfig.OnError with ErrSpoolFull. Segments
+	// being replayed do not count toward it, so items that still cannot be
+	// delivered can be spooled again; during a replay the spool can take up
+	// to twice MaxBytes on disk. Defaults to DefaultSpoolMaxBytes.
+	MaxBytes int64
+	// SegmentBytes is the size at which the spool starts a new segment
+	// file. Defaults to DefaultSpoolSegmentBytes.
+	SegmentBytes int64
+	// Sync controls when writes are flushed to stable storage.
+	Sync SpoolSync
+	// ReplayInterval is how often delivery of spooled items is retried.
+	// Defaults to DefaultSpoolReplayInterval.
+	ReplayInterval time.Duration
+}
+
+const spoolSegmentExt = ".spool"
+
+// spoolCheckpointFile lists the items of the segments being replayed that
+// have been delivered, so that a replay interrupted by a crash does not send
+// them again.
+const spoolCheckpointFile = "replay.checkpoint"
+
+// spoolSegment is a spool file. Segments are replayed in sequence order.
+type spoolSegment struct {
+	seq     uint64
+	size    int64
+	records int
+}
+
+// spool is an append-only, segmented log of batch items.
+type spool struct {
+	config SpoolConfig
+
+	mu       sync.Mutex
+	segments []*spoolSegment // oldest first; the last one may be open
+	file     *os.File        // open segment, nil until the next write
+	nextSeq  uint64
+	size     int64
+	records  int
+	// sealed is the size of the segments being replayed, which does not
+	// count toward MaxBytes
+	sealed int64
+	// delivered holds the digests of replayed items already delivered
+	delivered map[string]bool
+}
+
+// openSpool opens the spool in config.Dir, creating the directory if
+// needed. Existing segments are kept for replay.
+func openSpool(config SpoolConfig) (*spool, error) {
+	if config.Dir == "" {
+		return nil, fmt.Errorf("%w: spool directory is required", ErrInvalidInput)
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultSpoolMaxBytes
+	}
+	if config.SegmentBytes <= 0 {
+		config.SegmentBytes = DefaultSpoolSegmentBytes
+	}
+	if config.ReplayInterval <= 0 {
+		config.ReplayInterval = DefaultSpoolReplayInterval
+	}
+	if err := os.MkdirAll(config.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("opik: create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("opik: read spool directory: %w", err)
+	}
+
+	s := &spool{config: config, nextSeq: 1, delivered: make(map[string]bool)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolSegmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(config.Dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("opik: read spool segment: %w", err)
+		}
+		seg := &spoolSegment{seq: seq, size: int64(len(data)), records: bytes.Count(data, []byte{'\n'})}
+		s.segments = append(s.segments, seg)
+		s.size += seg.size
+		s.records += seg.records
+		s.nextSeq = max(s.nextSeq, seq+1)
+	}
+	slices.SortFunc(s.segments, func(a, b *spoolSegment) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	checkpoint, err := os.ReadFile(filepath.Join(config.Dir, spoolCheckpointFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("opik: read spool checkpoint: %w", err)
+	}
+	// A digest torn by a crash is ignored, like a torn record
+	for line := range bytes.Lines(checkpoint) {
+		if line[len(line)-1] == '\n' {
+			s.delivered[string(line[:len(line)-1])] = true
+		}
+	}
+	return s, nil
+}
+
+func (s *spool) path(seq uint64) string {
+	return filepath.Join(s.config.Dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExt))
+}
+
+// len returns the number of spooled items.
+func (s *spool) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records
+}
+
+// append writes items to the open segment, starting a new segment when the
+// open one is full. Either all items are written or none.
+func (s *spool) append(items []BatchItem) error {
+	var buf bytes.Buffer
+	for _, item := range items {
+		line, err := encodeSpoolRecord(item)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size-s.sealed+int64(buf.Len()) > s.config.MaxBytes {
+		return ErrSpoolFull
+	}
+
+	if s.file != nil && s.segments[len(s.segments)-1].size >= s.config.SegmentBytes {
+		if err := s.closeFile(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		seq := s.nextSeq
+		f, err := os.OpenFile(s.path(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("opik: create spool segment: %w", err)
+		}
+		s.file = f
+		s.nextSeq++
+		s.segments = append(s.segments, &spoolSegment{seq: seq})
+	}
+
+	seg := s.segments[len(s.segments)-1]
+	n, err := s.file.Write(buf.Bytes())
+	seg.size += int64(n)
+	s.size += int64(n)
+	if err != nil {
+		// Start a new segment rather than append to a partial record
+		_ = s.closeFile()
+		return fmt.Errorf("opik: write spool segment: %w", err)
+	}
+	if s.config.Sync == SpoolSyncAlways {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("opik: sync spool segment: %w", err)
+		}
+	}
+	seg.records += len(items)
+	s.records += len(items)
+	return nil
+}
+
+// closeFile closes the open segment. The caller must hold s.mu.
+func (s *spool) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	f := s.file
+	s.file = nil
+	var err error
+	if s.config.Sync != SpoolSyncNever {
+		err = f.Sync()
+	}
+	return errors.Join(err, f.Close())
+}
+
+// seal closes the open segment and returns all segments for replay. Items
+// appended afterwards go to a new segment. The sealed segments stop counting
+// toward MaxBytes until they are removed or unsealed.
+func (s *spool) seal() ([]*spoolSegment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.closeFile(); err != nil {
+		return nil, err
+	}
+	s.sealed = s.size
+	return slices.Clone(s.segments), nil
+}
+
+// unseal makes sealed segments that are kept count toward MaxBytes again.
+func (s *spool) unseal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealed = 0
+}
+
+// read decodes the items of sealed segments in order. Records that cannot
+// be decoded, such as a partial write at the end of a segment after a
+// crash, are skipped.
+func (s *spool) read(segments []*spoolSegment) ([]BatchItem, error) {
+	var items []BatchItem
+	for _, seg := range segments {
+		f, err := os.Open(s.path(seg.seq))
+		if err != nil {
+			return nil, fmt.Errorf("opik: open spool segment: %w", err)
+		}
+		r := bufio.NewReader(f)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 && line[len(line)-1] == '\n' {
+				if item, decodeErr := decodeSpoolRecord(line); decodeErr == nil {
+					items = append(items, item)
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("opik: read spool segment: %w", err)
+			}
+		}
+		f.Close()
+	}
+	return items, nil
+}
+
+// checkpoint records that replayed items have been delivered, so that
+// isDelivered reports them if their segments are replayed again after a
+// crash, until remove deletes the segments.
+func (s *spool) checkpoint(items []BatchItem) error {
+	var buf bytes.Buffer
+	digests := make([]string, 0, len(items))
+	for _, item := range items {
+		digest, err := spoolDigest(item)
+		if err != nil {
+			return err
+		}
+		digests = append(digests, digest)
+		buf.WriteString(digest)
+		buf.WriteByte('\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.config.Dir, spoolCheckpointFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("opik: open spool checkpoint: %w", err)
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil && s.config.Sync == SpoolSyncAlways {
+		err = f.Sync()
+	}
+	if err = errors.Join(err, f.Close()); err != nil {
+		return fmt.Errorf("opik: write spool checkpoint: %w", err)
+	}
+	for _, digest := range digests {
+		s.delivered[digest] = true
+	}
+	return nil
+}
+
+// isDelivered reports whether a replayed item was checkpointed as delivered.
+func (s *spool) isDelivered(item BatchItem) bool {
+	digest, err := spoolDigest(item)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delivered[digest]
+}
+
+// remove deletes sealed segments once their items have been replayed, and
+// the checkpoint with them. Segments that cannot be deleted count toward
+// MaxBytes again, and keep the checkpoint.
+func (s *spool) remove(segments []*spoolSegment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.sealed = 0 }()
+
+	var errs []error
+	for _, seg := range segments {
+		if err := os.Remove(s.path(seg.seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		s.segments = slices.DeleteFunc(s.segments, func(other *spoolSegment) bool {
+			return other == seg
+		})
+		s.size -= seg.size
+		s.records -= seg.records
+	}
+	if len(errs) == 0 {
+		if err := os.Remove(filepath.Join(s.config.Dir, spoolCheckpointFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		} else {
+			clear(s.delivered)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// close closes the open segment. Spooled items stay on disk.
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeFile()
+}
+
+// spoolRecord is the on-disk form of a batch item.
+type spoolRecord struct {
+	Kind   string          `json:"kind"`
+	Create bool            `json:"create,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func encodeSpoolRecord(item BatchItem) ([]byte, error) {
+	var (
+		record spoolRecord
+		err    error
+	)
+	switch v := item.(type) {
+	case TraceBatchItem:
+		record.Create = v.create
+		record.Data, err = v.write.MarshalJSON()
+	case SpanBatchItem:
+		record.Create = v.create
+		record.Data, err = v.write.MarshalJSON()
+	case FeedbackBatchItem:
+		record.Data, err = json.Marshal(v)
+	default:
+		return nil, fmt.Errorf("%w: cannot spool %s items", ErrInvalidInput, item.Type())
+	}
+	if err != nil {
+		return nil, err
+	}
+	record.Kind = item.Type()
+	return json.Marshal(record)
+}
+
+// spoolDigest identifies an item by the hash of its spool record, so that
+// only the exact operation that was delivered is skipped on replay.
+func spoolDigest(item BatchItem) (string, error) {
+	record, err := encodeSpoolRecord(item)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(record)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// decodeSpoolRecord decodes a spooled item. Trace and span items carry only
+// the snapshot that was queued; their Trace and Span fields are nil.
+func decodeSpoolRecord(line []byte) (BatchItem, error) {
+	var record spoolRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return nil, err
+	}
+	switch record.Kind {
+	case "trace":
+		item := TraceBatchItem{create: record.Create}
+		if err := item.write.UnmarshalJSON(record.Data); err != nil {
+			return nil, err
+		}
+		return item, nil
+	case "span":
+		item := SpanBatchItem{create: record.Create}
+		if err := item.write.UnmarshalJSON(record.Data); err != nil {
+			return nil, err
+		}
+		return item, nil
+	case "feedback":
+		var item FeedbackBatchItem
+		if err := json.Unmarshal(record.Data, &item); err != nil {
+			return nil, err
+		}
+		return item, nil
+	}
+	return nil, fmt.Errorf("unknown spool record kind %q", record.Kind)
+}
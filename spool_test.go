@@ -0,0 +1,384 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+func testTraceItem(create bool, name string) TraceBatchItem {
+	return TraceBatchItem{create: create, write: api.TraceWrite{
+		ID:        api.NewOptUUID(uuid.Must(uuid.NewV7())),
+		Name:      api.NewOptString(name),
+		StartTime: time.Now(),
+		Input:     api.JsonListStringWrite("null"),
+		Output:    api.JsonListStringWrite("null"),
+		Metadata:  api.JsonListStringWrite("null"),
+	}}
+}
+
+func TestSpoolSegments(t *testing.T) {
+	dir := t.TempDir()
+	config := SpoolConfig{Dir: dir, SegmentBytes: 1}
+
+	s, err := openSpool(config)
+	if err != nil {
+		t.Fatalf("openSpool error: %v", err)
+	}
+	trace := testTraceItem(true, "first")
+	feedback := FeedbackBatchItem{EntityType: "trace", EntityID: testTraceID, Name: "accuracy", Value: 0.5}
+	if err := s.append([]BatchItem{trace}); err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	if err := s.append([]BatchItem{feedback}); err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.spool"))
+	if len(files) != 2 {
+		t.Fatalf("segments = %v, want 2", files)
+	}
+
+	// A record torn by a crash is skipped
+	f, err := os.OpenFile(files[1], os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"kind":"trace","da`)
+	f.Close()
+
+	s, err = openSpool(config)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	if s.len() != 2 {
+		t.Errorf("len() = %d, want 2", s.len())
+	}
+	segments, err := s.seal()
+	if err != nil {
+		t.Fatalf("seal error: %v", err)
+	}
+	items, err := s.read(segments)
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("read %d items, want 2", len(items))
+	}
+	got, ok := items[0].(TraceBatchItem)
+	if !ok || !got.create || got.write.ID != trace.write.ID || got.write.Name.Value != "first" {
+		t.Errorf("items[0] = %+v", items[0])
+	}
+	if items[1] != feedback {
+		t.Errorf("items[1] = %+v, want %+v", items[1], feedback)
+	}
+
+	if err := s.remove(segments); err != nil {
+		t.Fatalf("remove error: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.spool")); len(files) != 0 || s.len() != 0 {
+		t.Errorf("segments after remove = %v, len() = %d", files, s.len())
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	s, err := openSpool(SpoolConfig{Dir: t.TempDir(), MaxBytes: 200})
+	if err != nil {
+		t.Fatalf("openSpool error: %v", err)
+	}
+	defer s.close()
+
+	item := FeedbackBatchItem{EntityType: "trace", EntityID: testTraceID, Name: "accuracy"}
+	if err := s.append([]BatchItem{item}); err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	if err := s.append([]BatchItem{item}); !errors.Is(err, ErrSpoolFull) {
+		t.Errorf("append error = %v, want ErrSpoolFull", err)
+	}
+	if s.len() != 1 {
+		t.Errorf("len() = %d, want 1", s.len())
+	}
+}
+
+func TestBatcherSpoolsDuringOutage(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	var up atomic.Bool
+	status := func(w http.ResponseWriter, _ *http.Request) {
+		if up.Load() {
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	ms.OnPost("/v1/private/traces/batch").WithHandler(status)
+	ms.OnPatch("/v1/private/traces/batch").WithHandler(status)
+
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	config.Workers = 1
+	config.Spool = &SpoolConfig{Dir: t.TempDir(), ReplayInterval: time.Hour}
+	config.OnError = func(err *BatchError) {
+		t.Errorf("unexpected batch error: %v", err)
+	}
+
+	newClient := func() *BatchingClient {
+		client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()), WithAPIKey("test-key"))
+		if err != nil {
+			t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+		}
+		return client
+	}
+
+	ctx := context.Background()
+	client := newClient()
+	trace, err := client.Trace(ctx, "outage")
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	attempts := ms.RequestCount()
+	if attempts != config.MaxRetries+1 || client.Spooled() != 1 {
+		t.Fatalf("attempts = %d, Spooled() = %d", attempts, client.Spooled())
+	}
+
+	// While the spool holds items, later operations are spooled too
+	if err := trace.End(ctx, WithTraceOutput("done")); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if ms.RequestCount() != attempts || client.Spooled() != 2 {
+		t.Fatalf("requests = %d, Spooled() = %d", ms.RequestCount(), client.Spooled())
+	}
+	if err := client.Close(time.Second); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	// The spool is replayed by the next client once the server is back
+	up.Store(true)
+	ms.Reset()
+	client = newClient()
+	defer client.Close(time.Second)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for client.Spooled() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if client.Spooled() != 0 {
+		t.Fatalf("Spooled() = %d after replay", client.Spooled())
+	}
+
+	// The create and the end are merged into a single write
+	if n := ms.RouteCallCount("PATCH", "/v1/private/traces/batch"); n != 0 {
+		t.Errorf("trace update calls = %d, want 0", n)
+	}
+	reqs := ms.RequestsForPath("/v1/private/traces/batch")
+	if len(reqs) != 1 {
+		t.Fatalf("trace batch requests = %d, want 1", len(reqs))
+	}
+	var body struct {
+		Traces []map[string]any `json:"traces"`
+	}
+	if err := json.Unmarshal(reqs[0].Body, &body); err != nil {
+		t.Fatalf("unmarshal trace batch: %v", err)
+	}
+	if len(body.Traces) != 1 || body.Traces[0]["id"] != trace.ID() || body.Traces[0]["output"] != "done" {
+		t.Errorf("traces = %+v", body.Traces)
+	}
+}
+
+func TestBatcherDoesNotSpoolClientErrors(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/batch").Respond(http.StatusBadRequest, nil)
+
+	var failed atomic.Int32
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	config.Spool = &SpoolConfig{Dir: t.TempDir(), ReplayInterval: time.Hour}
+	config.OnError = func(err *BatchError) {
+		failed.Add(int32(len(err.Items))) //nolint:gosec // G115: test item count
+	}
+
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	defer client.Close(time.Second)
+
+	if _, err := client.Trace(context.Background(), "rejected"); err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if err := client.Flush(time.Second); err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if client.Spooled() != 0 || failed.Load() != 1 {
+		t.Errorf("Spooled() = %d, failed = %d", client.Spooled(), failed.Load())
+	}
+}
+
+func TestBatcherRespoolsWhileServerIsDown(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/batch").Respond(http.StatusServiceUnavailable, nil)
+
+	// Fill the spool to more than half of MaxBytes
+	spoolConfig := SpoolConfig{Dir: t.TempDir(), ReplayInterval: time.Hour}
+	s, err := openSpool(spoolConfig)
+	if err != nil {
+		t.Fatalf("openSpool error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := s.append([]BatchItem{testTraceItem(true, "spooled")}); err != nil {
+			t.Fatalf("append error: %v", err)
+		}
+	}
+	spoolConfig.MaxBytes = s.size + s.size/2
+	if err := s.close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.RetryDelay = time.Millisecond
+	config.Spool = &spoolConfig
+	config.OnError = func(err *BatchError) {
+		t.Errorf("unexpected batch error: %v", err)
+	}
+
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for ms.RequestCount() < config.MaxRetries+1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// Close waits for the replay to finish
+	if err := client.Close(time.Second); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if ms.RequestCount() != config.MaxRetries+1 {
+		t.Errorf("requests = %d, want %d", ms.RequestCount(), config.MaxRetries+1)
+	}
+
+	s, err = openSpool(spoolConfig)
+	if err != nil {
+		t.Fatalf("reopen error: %v", err)
+	}
+	defer s.close()
+	if s.len() != 5 || s.size > spoolConfig.MaxBytes {
+		t.Errorf("len() = %d, size = %d after replay", s.len(), s.size)
+	}
+}
+
+func TestBatcherReplaySkipsItemsDeliveredBeforeCrash(t *testing.T) {
+	trace := testTraceItem(true, "spooled")
+	span := SpanBatchItem{create: true, write: api.SpanWrite{
+		ID:        api.NewOptUUID(uuid.Must(uuid.NewV7())),
+		TraceID:   trace.write.ID,
+		Name:      api.NewOptString("spooled"),
+		StartTime: time.Now(),
+		Input:     api.JsonListStringWrite("null"),
+		Output:    api.JsonListStringWrite("null"),
+		Metadata:  api.JsonListStringWrite("null"),
+	}}
+
+	spoolConfig := SpoolConfig{Dir: t.TempDir(), ReplayInterval: time.Hour}
+	s, err := openSpool(spoolConfig)
+	if err != nil {
+		t.Fatalf("openSpool error: %v", err)
+	}
+	if err := s.append([]BatchItem{trace, span}); err != nil {
+		t.Fatalf("append error: %v", err)
+	}
+	if err := s.close(); err != nil {
+		t.Fatalf("close error: %v", err)
+	}
+
+	// The first replay delivers the trace and hangs sending the span
+	ms := tracetest.NewServer()
+	defer ms.Close()
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ms.OnPost(tracetest.SpansBatchPath).WithHandler(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	config := DefaultBatcherConfig()
+	config.FlushInterval = time.Hour
+	config.Spool = &spoolConfig
+	client, err := NewBatchingClientWithConfig(config, WithURL(ms.URL()), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	defer client.Close(time.Second)
+	defer close(release)
+
+	select {
+	case <-arrived:
+	case <-time.After(2 * time.Second):
+		t.Fatal("replay did not send the span")
+	}
+
+	// Crash: the spool directory is left as it is while the span is in flight
+	crashDir := t.TempDir()
+	if err := os.CopyFS(crashDir, os.DirFS(spoolConfig.Dir)); err != nil {
+		t.Fatalf("copy spool: %v", err)
+	}
+
+	restarted := tracetest.NewServer()
+	defer restarted.Close()
+	crashConfig := SpoolConfig{Dir: crashDir, ReplayInterval: time.Hour}
+	config.Spool = &crashConfig
+	config.OnError = func(err *BatchError) {
+		t.Errorf("unexpected batch error: %v", err)
+	}
+	client2, err := NewBatchingClientWithConfig(config, WithURL(restarted.URL()), WithAPIKey("test-key"))
+	if err != nil {
+		t.Fatalf("NewBatchingClientWithConfig error: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for client2.Spooled() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := client2.Close(time.Second); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	if writes := tracetest.TraceWrites(restarted); len(writes) != 0 {
+		t.Errorf("trace writes after restart = %d, want 0", len(writes))
+	}
+	if writes := tracetest.SpanWrites(restarted); len(writes) != 1 {
+		t.Errorf("span writes after restart = %d, want 1", len(writes))
+	}
+	if _, err := os.Stat(filepath.Join(crashDir, spoolCheckpointFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("checkpoint after replay: %v, want it removed", err)
+	}
+}
@@ -0,0 +1,442 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// ThreadStatus is the status of a conversation thread.
+type ThreadStatus string
+
+const (
+	// ThreadStatusActive indicates the thread is open and may receive new traces.
+	ThreadStatusActive ThreadStatus = "active"
+	// ThreadStatusInactive indicates the thread has been closed.
+	ThreadStatusInactive ThreadStatus = "inactive"
+)
+
+// Thread represents a conversation thread in Opik.
+// A thread groups all traces of a project that share the same thread ID,
+// as set with WithTraceThreadID.
+type Thread struct {
+	client           *Client
+	id               string
+	modelID          string
+	projectName      string
+	status           ThreadStatus
+	startTime        time.Time
+	endTime          time.Time
+	numberOfMessages int64
+	firstMessage     any
+	lastMessage      any
+	tags             []string
+	usage            map[string]int
+	totalCost        float64
+	feedbackScores   []FeedbackScore
+	comments         []Comment
+	createdAt        time.Time
+	lastUpdatedAt    time.Time
+}
+
+// ID returns the thread ID.
+func (t *Thread) ID() string {
+	return t.id
+}
+
+// ModelID returns the server-assigned thread model ID.
+func (t *Thread) ModelID() string {
+	return t.modelID
+}
+
+// ProjectName returns the project name.
+func (t *Thread) ProjectName() string {
+	return t.projectName
+}
+
+// Status returns the thread status.
+func (t *Thread) Status() ThreadStatus {
+	return t.status
+}
+
+// StartTime returns the start time of the first trace in the thread.
+func (t *Thread) StartTime() time.Time {
+	return t.startTime
+}
+
+// EndTime returns the end time of the last trace in the thread.
+func (t *Thread) EndTime() time.Time {
+	return t.endTime
+}
+
+// NumberOfMessages returns the number of traces in the thread.
+func (t *Thread) NumberOfMessages() int64 {
+	return t.numberOfMessages
+}
+
+// FirstMessage returns the input of the first trace in the thread.
+func (t *Thread) FirstMessage() any {
+	return t.firstMessage
+}
+
+// LastMessage returns the output of the last trace in the thread.
+func (t *Thread) LastMessage() any {
+	return t.lastMessage
+}
+
+// Tags returns the thread tags.
+func (t *Thread) Tags() []string {
+	return t.tags
+}
+
+// Usage returns the aggregated token usage of the thread.
+func (t *Thread) Usage() map[string]int {
+	return t.usage
+}
+
+// TotalCost returns the total estimated cost of the thread in USD.
+func (t *Thread) TotalCost() float64 {
+	return t.totalCost
+}
+
+// FeedbackScores returns the feedback scores recorded on the thread.
+func (t *Thread) FeedbackScores() []FeedbackScore {
+	return t.feedbackScores
+}
+
+// Comments returns the comments on the thread.
+func (t *Thread) Comments() []Comment {
+	return t.comments
+}
+
+// CreatedAt returns when the thread was created.
+func (t *Thread) CreatedAt() time.Time {
+	return t.createdAt
+}
+
+// LastUpdatedAt returns when the thread was last updated.
+func (t *Thread) LastUpdatedAt() time.Time {
+	return t.lastUpdatedAt
+}
+
+// ThreadOption is a functional option for thread lookups.
+type ThreadOption func(*threadOptions)
+
+type threadOptions struct {
+	projectName string
+}
+
+// WithThreadProjectName sets the project to look up threads in.
+// Defaults to the client's project.
+func WithThreadProjectName(name string) ThreadOption {
+	return func(o *threadOptions) {
+		o.projectName = name
+	}
+}
+
+// threadProjectName resolves the project name for thread options.
+func (c *Client) threadProjectName(opts []ThreadOption) string {
+	options := &threadOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.projectName == "" {
+		return c.projectName
+	}
+	return options.projectName
+}
+
+// GetThread retrieves a thread by its thread ID.
+func (c *Client) GetThread(ctx context.Context, threadID string, opts ...ThreadOption) (*Thread, error) {
+	projectName := c.threadProjectName(opts)
+
+	resp, err := c.apiClient.GetTraceThread(ctx, api.NewOptTraceThreadIdentifier(api.TraceThreadIdentifier{
+		ProjectName: api.NewOptString(projectName),
+		ThreadID:    threadID,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := resp.(type) {
+	case *api.TraceThread:
+		return c.threadFromAPI(r, projectName), nil
+	case *api.ErrorMessage:
+		return nil, newAPIError(404, r)
+	default:
+		return nil, ErrThreadNotFound
+	}
+}
+
+// ListThreads lists the threads of the project.
+func (c *Client) ListThreads(ctx context.Context, page, size int, opts ...ThreadOption) ([]*Thread, error) {
+	projectName := c.threadProjectName(opts)
+
+	resp, err := c.apiClient.GetTraceThreads(ctx, api.GetTraceThreadsParams{
+		ProjectName: api.NewOptString(projectName),
+		Page:        api.NewOptInt32(int32(page)), //nolint:gosec // G115: page values are bounded by API limits
+		Size:        api.NewOptInt32(int32(size)), //nolint:gosec // G115: size values are bounded by API limits
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	threads := make([]*Thread, 0, len(resp.Content))
+	for i := range resp.Content {
+		threads = append(threads, c.threadFromAPI(&resp.Content[i], projectName))
+	}
+
+	return threads, nil
+}
+
+// DeleteThreads deletes threads, and all their traces, by thread ID.
+func (c *Client) DeleteThreads(ctx context.Context, threadIDs []string, opts ...ThreadOption) error {
+	return c.apiClient.DeleteTraceThreads(ctx, api.NewOptDeleteTraceThreads(api.DeleteTraceThreads{
+		ProjectName: api.NewOptString(c.threadProjectName(opts)),
+		ThreadIds:   threadIDs,
+	}))
+}
+
+// threadFromAPI converts an API thread.
+func (c *Client) threadFromAPI(t *api.TraceThread, projectName string) *Thread {
+	thread := &Thread{
+		client:         c,
+		projectName:    projectName,
+		tags:           t.Tags,
+		firstMessage:   decodeJSONValue(t.FirstMessage),
+		lastMessage:    decodeJSONValue(t.LastMessage),
+		feedbackScores: feedbackScoresFromAPI(t.FeedbackScores),
+		comments:       commentsFromAPI(t.Comments),
+	}
+	if t.ID.Set {
+		thread.id = t.ID.Value
+	}
+	if t.ThreadModelID.Set {
+		thread.modelID = t.ThreadModelID.Value.String()
+	}
+	if t.Status.Set {
+		thread.status = ThreadStatus(t.Status.Value)
+	}
+	if t.StartTime.Set {
+		thread.startTime = t.StartTime.Value
+	}
+	if t.EndTime.Set {
+		thread.endTime = t.EndTime.Value
+	}
+	if t.NumberOfMessages.Set {
+		thread.numberOfMessages = t.NumberOfMessages.Value
+	}
+	if t.TotalEstimatedCost.Set {
+		thread.totalCost = t.TotalEstimatedCost.Value
+	}
+	if t.Usage.Set {
+		thread.usage = make(map[string]int, len(t.Usage.Value))
+		for k, v := range t.Usage.Value {
+			thread.usage[k] = int(v)
+		}
+	}
+	if t.CreatedAt.Set {
+		thread.createdAt = t.CreatedAt.Value
+	}
+	if t.LastUpdatedAt.Set {
+		thread.lastUpdatedAt = t.LastUpdatedAt.Value
+	}
+	return thread
+}
+
+// decodeJSONValue decodes raw JSON returned by the API, returning nil when
+// the value is empty or malformed.
+func decodeJSONValue(raw []byte) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// Open reopens the thread so new traces can be added to it.
+func (t *Thread) Open(ctx context.Context) error {
+	err := t.client.apiClient.OpenTraceThread(ctx, api.NewOptTraceThreadIdentifier(api.TraceThreadIdentifier{
+		ProjectName: api.NewOptString(t.projectName),
+		ThreadID:    t.id,
+	}))
+	if err != nil {
+		return err
+	}
+	t.status = ThreadStatusActive
+	return nil
+}
+
+// Close marks the thread as inactive.
+func (t *Thread) Close(ctx context.Context) error {
+	res, err := t.client.apiClient.CloseTraceThread(ctx, api.NewOptTraceThreadBatchIdentifier(api.TraceThreadBatchIdentifier{
+		ProjectName: api.NewOptString(t.projectName),
+		ThreadID:    api.NewOptString(t.id),
+	}))
+	if err != nil {
+		return err
+	}
+	if msg, ok := res.(*api.ErrorMessage); ok {
+		return newAPIError(404, msg)
+	}
+	t.status = ThreadStatusInactive
+	return nil
+}
+
+// SetTags replaces the thread tags.
+func (t *Thread) SetTags(ctx context.Context, tags ...string) error {
+	modelUUID, err := t.modelUUID()
+	if err != nil {
+		return err
+	}
+
+	res, err := t.client.apiClient.UpdateThread(ctx, api.NewOptTraceThreadUpdate(api.TraceThreadUpdate{
+		Tags: tags,
+	}), api.UpdateThreadParams{ThreadModelId: modelUUID})
+	if err != nil {
+		return err
+	}
+	if _, ok := res.(*api.UpdateThreadNotFound); ok {
+		return ErrThreadNotFound
+	}
+	t.tags = tags
+	return nil
+}
+
+// Delete deletes the thread and all its traces.
+func (t *Thread) Delete(ctx context.Context) error {
+	return t.client.DeleteThreads(ctx, []string{t.id}, WithThreadProjectName(t.projectName))
+}
+
+// AddFeedbackScore adds a feedback score to this thread.
+func (t *Thread) AddFeedbackScore(ctx context.Context, name string, value float64, reason string) error {
+	category, err := t.client.checkFeedbackScore(name, value)
+	if err != nil {
+		return err
+	}
+
+	return t.client.apiClient.ScoreBatchOfThreads(ctx, api.NewOptFeedbackScoreBatchThread(api.FeedbackScoreBatchThread{
+		Scores: []api.FeedbackScoreBatchItemThread{{
+			ProjectName:  api.NewOptString(t.projectName),
+			Name:         name,
+			CategoryName: optString(category),
+			Value:        value,
+			Reason:       api.NewOptString(t.client.redactReason(reason)),
+			Source:       api.FeedbackScoreBatchItemThreadSourceSdk,
+			ThreadID:     t.id,
+		}},
+	}))
+}
+
+// DeleteFeedbackScores deletes the named feedback scores from this thread.
+func (t *Thread) DeleteFeedbackScores(ctx context.Context, names ...string) error {
+	return t.client.DeleteThreadFeedbackScores(ctx, t.id, names, WithThreadProjectName(t.projectName))
+}
+
+// AddComment adds a comment to this thread.
+func (t *Thread) AddComment(ctx context.Context, text string) (*Comment, error) {
+	modelUUID, err := t.modelUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := newComment(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := t.client.apiClient.AddThreadComment(ctx, api.NewOptComment(req), api.AddThreadCommentParams{ID: modelUUID}); err != nil {
+		return nil, err
+	}
+
+	comment := addedComment(req)
+	t.comments = append(t.comments, *comment)
+	return comment, nil
+}
+
+// GetComment retrieves a comment on this thread.
+func (t *Thread) GetComment(ctx context.Context, commentID string) (*Comment, error) {
+	modelUUID, err := t.modelUUID()
+	if err != nil {
+		return nil, err
+	}
+	commentUUID, err := uuid.Parse(commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.client.apiClient.GetThreadComment(ctx, api.GetThreadCommentParams{
+		ThreadId:  modelUUID,
+		CommentId: commentUUID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch r := resp.(type) {
+	case *api.Comment:
+		comment := commentFromAPI(*r)
+		return &comment, nil
+	case *api.ErrorMessage:
+		return nil, newAPIError(404, r)
+	default:
+		return nil, ErrCommentNotFound
+	}
+}
+
+// UpdateComment replaces the text of a comment on this thread.
+func (t *Thread) UpdateComment(ctx context.Context, commentID, text string) error {
+	commentUUID, err := uuid.Parse(commentID)
+	if err != nil {
+		return err
+	}
+
+	res, err := t.client.apiClient.UpdateThreadComment(ctx, api.NewOptComment(api.Comment{
+		Text: text,
+	}), api.UpdateThreadCommentParams{CommentId: commentUUID})
+	if err != nil {
+		return err
+	}
+	if _, ok := res.(*api.UpdateThreadCommentNotFound); ok {
+		return ErrCommentNotFound
+	}
+
+	for i := range t.comments {
+		if t.comments[i].ID == commentID {
+			t.comments[i].Text = text
+		}
+	}
+	return nil
+}
+
+// DeleteComments deletes comments from this thread.
+func (t *Thread) DeleteComments(ctx context.Context, commentIDs ...string) error {
+	req, err := newBatchDelete(commentIDs)
+	if err != nil {
+		return err
+	}
+
+	if err := t.client.apiClient.DeleteThreadComments(ctx, api.NewOptBatchDelete(req)); err != nil {
+		return err
+	}
+	t.comments = removeComments(t.comments, commentIDs)
+	return nil
+}
+
+// modelUUID returns the thread model ID required by the thread update and
+// comment endpoints. It is assigned by the server, so the thread must have
+// been retrieved with GetThread or ListThreads.
+func (t *Thread) modelUUID() (uuid.UUID, error) {
+	if t.modelID == "" {
+		return uuid.UUID{}, fmt.Errorf("%w: thread %q has no model ID", ErrInvalidInput, t.id)
+	}
+	return uuid.Parse(t.modelID)
+}
@@ -0,0 +1,229 @@
+package opik
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+func TestTraceSendsThreadID(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms, WithProjectName("chat-project"))
+	ctx := context.Background()
+
+	trace, err := client.Trace(ctx, "turn-1", WithTraceThreadID("conversation-42"))
+	if err != nil {
+		t.Fatalf("Trace error: %v", err)
+	}
+	if trace.ThreadID() != "conversation-42" {
+		t.Errorf("ThreadID() = %q, want %q", trace.ThreadID(), "conversation-42")
+	}
+	if err := trace.End(ctx); err != nil {
+		t.Fatalf("End error: %v", err)
+	}
+
+	creates, updates := tracetest.TraceWrites(ms), tracetest.TraceUpdates(ms)
+	if len(creates) != 1 || creates[0]["thread_id"] != "conversation-42" {
+		t.Errorf("creates = %v, want one with thread_id conversation-42", creates)
+	}
+	if len(updates) != 1 || updates[0]["thread_id"] != "conversation-42" {
+		t.Errorf("updates = %v, want one with thread_id conversation-42", updates)
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnPost("/v1/private/traces/threads/retrieve").RespondJSON(http.StatusOK, map[string]any{
+		"id":                 "conversation-42",
+		"thread_model_id":    "0191b0a8-7a4e-7c3a-9f1e-3b2a1c0d9e8f",
+		"status":             "active",
+		"number_of_messages": 3,
+		"first_message":      map[string]any{"text": "hello"},
+		"usage":              map[string]any{"total_tokens": 120},
+		"tags":               []string{"support"},
+		"feedback_scores": []map[string]any{
+			{"name": "helpfulness", "value": 0.8, "source": "sdk"},
+		},
+		"comments": []map[string]any{
+			{"id": "0191b0a8-7a4e-7c3a-9f1e-3b2a1c0d9e90", "text": "escalated"},
+		},
+	})
+
+	client := newTestClient(t, ms, WithProjectName("chat-project"))
+
+	thread, err := client.GetThread(context.Background(), "conversation-42")
+	if err != nil {
+		t.Fatalf("GetThread error: %v", err)
+	}
+
+	if thread.ID() != "conversation-42" {
+		t.Errorf("ID() = %q, want conversation-42", thread.ID())
+	}
+	if thread.ProjectName() != "chat-project" {
+		t.Errorf("ProjectName() = %q, want chat-project", thread.ProjectName())
+	}
+	if thread.Status() != ThreadStatusActive {
+		t.Errorf("Status() = %q, want %q", thread.Status(), ThreadStatusActive)
+	}
+	if thread.NumberOfMessages() != 3 {
+		t.Errorf("NumberOfMessages() = %d, want 3", thread.NumberOfMessages())
+	}
+	if msg, ok := thread.FirstMessage().(map[string]any); !ok || msg["text"] != "hello" {
+		t.Errorf("FirstMessage() = %v, want map with text=hello", thread.FirstMessage())
+	}
+	if thread.Usage()["total_tokens"] != 120 {
+		t.Errorf("Usage() = %v, want total_tokens=120", thread.Usage())
+	}
+	if len(thread.FeedbackScores()) != 1 || thread.FeedbackScores()[0].Name != "helpfulness" {
+		t.Errorf("FeedbackScores() = %+v", thread.FeedbackScores())
+	}
+	if len(thread.Comments()) != 1 || thread.Comments()[0].Text != "escalated" {
+		t.Errorf("Comments() = %+v", thread.Comments())
+	}
+
+	var req struct {
+		ProjectName string `json:"project_name"`
+		ThreadID    string `json:"thread_id"`
+	}
+	if err := json.Unmarshal(ms.LastRequest().Body, &req); err != nil {
+		t.Fatalf("unmarshal request: %v", err)
+	}
+	if req.ProjectName != "chat-project" || req.ThreadID != "conversation-42" {
+		t.Errorf("request = %+v, want chat-project/conversation-42", req)
+	}
+}
+
+func TestListThreads(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	ms.OnGet("/v1/private/traces/threads").RespondJSON(http.StatusOK, map[string]any{
+		"page":  1,
+		"size":  2,
+		"total": 2,
+		"content": []map[string]any{
+			{"id": "a", "status": "active"},
+			{"id": "b", "status": "inactive"},
+		},
+	})
+
+	client := newTestClient(t, ms, WithProjectName("chat-project"))
+
+	threads, err := client.ListThreads(context.Background(), 1, 10, WithThreadProjectName("other"))
+	if err != nil {
+		t.Fatalf("ListThreads error: %v", err)
+	}
+	if len(threads) != 2 {
+		t.Fatalf("got %d threads, want 2", len(threads))
+	}
+	if threads[1].ID() != "b" || threads[1].Status() != ThreadStatusInactive {
+		t.Errorf("threads[1] = %q/%q, want b/inactive", threads[1].ID(), threads[1].Status())
+	}
+	if threads[0].ProjectName() != "other" {
+		t.Errorf("ProjectName() = %q, want other", threads[0].ProjectName())
+	}
+}
+
+func TestThreadOperations(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	const modelID = "0191b0a8-7a4e-7c3a-9f1e-3b2a1c0d9e8f"
+
+	ms.OnPut("/v1/private/traces/threads/close").Respond(http.StatusNoContent, nil)
+	ms.OnPut("/v1/private/traces/threads/open").Respond(http.StatusNoContent, nil)
+	ms.OnPatch("/v1/private/traces/threads/"+modelID).Respond(http.StatusNoContent, nil)
+	ms.OnPut("/v1/private/traces/threads/feedback-scores").Respond(http.StatusNoContent, nil)
+	ms.OnPost("/v1/private/traces/threads/feedback-scores/delete").Respond(http.StatusNoContent, nil)
+	ms.OnPost("/v1/private/traces/threads/"+modelID+"/comments").
+		WithHeaders(map[string]string{"Location": "/v1/private/traces/threads/comments/1"}).
+		Respond(http.StatusCreated, nil)
+	ms.OnPost("/v1/private/traces/threads/comments/delete").Respond(http.StatusNoContent, nil)
+
+	client := newTestClient(t, ms, WithProjectName("chat-project"))
+	ctx := context.Background()
+
+	thread := &Thread{
+		client:      client,
+		id:          "conversation-42",
+		modelID:     modelID,
+		projectName: "chat-project",
+		status:      ThreadStatusActive,
+	}
+
+	if err := thread.Close(ctx); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if thread.Status() != ThreadStatusInactive {
+		t.Errorf("Status() after Close = %q, want inactive", thread.Status())
+	}
+
+	if err := thread.Open(ctx); err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if thread.Status() != ThreadStatusActive {
+		t.Errorf("Status() after Open = %q, want active", thread.Status())
+	}
+
+	if err := thread.SetTags(ctx, "vip"); err != nil {
+		t.Fatalf("SetTags error: %v", err)
+	}
+	if len(thread.Tags()) != 1 || thread.Tags()[0] != "vip" {
+		t.Errorf("Tags() = %v, want [vip]", thread.Tags())
+	}
+
+	if err := thread.AddFeedbackScore(ctx, "resolved", 1, "issue fixed"); err != nil {
+		t.Fatalf("AddFeedbackScore error: %v", err)
+	}
+	var scores struct {
+		Scores []struct {
+			ThreadID    string `json:"thread_id"`
+			ProjectName string `json:"project_name"`
+			Name        string `json:"name"`
+		} `json:"scores"`
+	}
+	if err := json.Unmarshal(ms.LastRequest().Body, &scores); err != nil {
+		t.Fatalf("unmarshal scores: %v", err)
+	}
+	if len(scores.Scores) != 1 || scores.Scores[0].ThreadID != "conversation-42" || scores.Scores[0].ProjectName != "chat-project" {
+		t.Errorf("scores = %+v", scores.Scores)
+	}
+
+	if err := thread.DeleteFeedbackScores(ctx, "resolved"); err != nil {
+		t.Fatalf("DeleteFeedbackScores error: %v", err)
+	}
+
+	comment, err := thread.AddComment(ctx, "follow up tomorrow")
+	if err != nil {
+		t.Fatalf("AddComment error: %v", err)
+	}
+	if len(thread.Comments()) != 1 || thread.Comments()[0].ID != comment.ID {
+		t.Errorf("Comments() = %+v, want the added comment", thread.Comments())
+	}
+
+	if err := thread.DeleteComments(ctx, comment.ID); err != nil {
+		t.Fatalf("DeleteComments error: %v", err)
+	}
+	if len(thread.Comments()) != 0 {
+		t.Errorf("Comments() after delete = %+v, want empty", thread.Comments())
+	}
+}
+
+func TestThreadWithoutModelID(t *testing.T) {
+	thread := &Thread{id: "conversation-42"}
+
+	if err := thread.SetTags(context.Background(), "x"); err == nil {
+		t.Error("SetTags should fail without a model ID")
+	}
+	if _, err := thread.AddComment(context.Background(), "x"); err == nil {
+		t.Error("AddComment should fail without a model ID")
+	}
+}
@@ -2,10 +2,10 @@ package opik
 
 import (
 	"context"
-	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	oteltrace "go.opentelemetry.io/otel/trace"
 
 	"github.com/agentplexus/go-opik/internal/api"
 )
@@ -16,13 +16,25 @@ type Trace struct {
 	id          string
 	name        string
 	projectName string
+	threadID    string
 	startTime   time.Time
 	endTime     *time.Time
 	input       any
 	output      any
 	metadata    map[string]any
 	tags        []string
+	errorInfo   *ErrorInfo
+	attachments []*Attachment
+	sampling    *samplingState
+	otelSpan    oteltrace.Span // mirrors the trace when the client has a tracer
+	logs        logEvents
 	ended       bool
+
+	// Stored state, only set for traces retrieved with GetTrace
+	usage          map[string]int
+	totalCost      float64
+	feedbackScores []FeedbackScore
+	comments       []Comment
 }
 
 // ID returns the trace ID.
@@ -40,6 +52,12 @@ func (t *Trace) ProjectName() string {
 	return t.projectName
 }
 
+// ThreadID returns the ID of the conversation thread the trace belongs to,
+// or an empty string if it is not part of a thread.
+func (t *Trace) ThreadID() string {
+	return t.threadID
+}
+
 // StartTime returns the start time.
 func (t *Trace) StartTime() time.Time {
 	return t.startTime
@@ -50,6 +68,50 @@ func (t *Trace) EndTime() *time.Time {
 	return t.endTime
 }
 
+// Input returns the trace input.
+func (t *Trace) Input() any {
+	return t.input
+}
+
+// Output returns the trace output.
+func (t *Trace) Output() any {
+	return t.output
+}
+
+// Metadata returns the trace metadata.
+func (t *Trace) Metadata() map[string]any {
+	return t.metadata
+}
+
+// Tags returns the trace tags.
+func (t *Trace) Tags() []string {
+	return t.tags
+}
+
+// Usage returns the token usage aggregated over the trace's spans.
+// It is only known for traces retrieved with GetTrace.
+func (t *Trace) Usage() map[string]int {
+	return t.usage
+}
+
+// TotalCost returns the total estimated cost of the trace in USD.
+// It is only known for traces retrieved with GetTrace.
+func (t *Trace) TotalCost() float64 {
+	return t.totalCost
+}
+
+// FeedbackScores returns the feedback scores recorded on the trace.
+// They are only known for traces retrieved with GetTrace.
+func (t *Trace) FeedbackScores() []FeedbackScore {
+	return t.feedbackScores
+}
+
+// Comments returns the comments on the trace.
+// They are only known for traces retrieved with GetTrace.
+func (t *Trace) Comments() []Comment {
+	return t.comments
+}
+
 // End ends the trace with optional output.
 func (t *Trace) End(ctx context.Context, opts ...TraceOption) error {
 	if t.ended {
@@ -66,107 +128,211 @@ func (t *Trace) End(ctx context.Context, opts ...TraceOption) error {
 	endTime := time.Now()
 	t.endTime = &endTime
 	t.ended = true
+	t.logs.end()
+
+	t.apply(options)
+	t.endOTelSpan()
+
+	return t.client.sendTrace(ctx, t, false)
+}
+
+// Update updates the trace with new data.
+func (t *Trace) Update(ctx context.Context, opts ...TraceOption) error {
+	options := &traceOptions{
+		metadata: make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	t.apply(options)
 
-	// Merge output and metadata
+	return t.client.sendTrace(ctx, t, false)
+}
+
+// apply merges the name, output, metadata and tags from options into the
+// trace.
+func (t *Trace) apply(options *traceOptions) {
+	if options.name != "" {
+		t.name = options.name
+	}
 	if options.output != nil {
-		t.output = options.output
+		t.output = t.extractAttachments("output", options.output)
+	}
+	if t.metadata == nil {
+		t.metadata = make(map[string]any)
 	}
 	for k, v := range options.metadata {
 		t.metadata[k] = v
 	}
+	if len(options.tags) > 0 {
+		t.tags = options.tags
+	}
+	if options.errorInfo != nil {
+		t.errorInfo = options.errorInfo
+	}
+	t.attachments = append(t.attachments, options.attachments...)
+}
 
-	// Prepare update request
+// write builds the create payload for the trace's current state.
+func (t *Trace) write() (api.TraceWrite, error) {
 	traceUUID, err := uuid.Parse(t.id)
 	if err != nil {
-		return err
+		return api.TraceWrite{}, err
 	}
 
-	// IMPORTANT: JsonListString fields must be set to valid JSON (including "null")
-	// An empty JsonListString produces malformed JSON in the generated encoder.
-	nullJSON := api.JsonListString([]byte("null"))
-
-	outputJSON := nullJSON
-	if t.output != nil {
-		data, _ := json.Marshal(t.output)
-		outputJSON = api.JsonListString(data)
+	w := api.TraceWrite{
+		ID:          api.NewOptUUID(traceUUID),
+		ProjectName: api.NewOptString(t.projectName),
+		Name:        api.NewOptString(t.name),
+		StartTime:   t.startTime,
+		Input:       api.JsonListStringWrite(jsonOrNull(t.client.redact(RedactFieldInput, t.input))),
+		Output:      api.JsonListStringWrite(jsonOrNull(t.client.redact(RedactFieldOutput, t.output))),
+		Metadata:    api.JsonListStringWrite(metadataJSON(t.client.redactMetadata(t.logs.metadata(t.metadata)))),
+		Tags:        t.client.redactTags(t.tags),
 	}
-
-	metadataJSON := nullJSON
-	if len(t.metadata) > 0 {
-		data, _ := json.Marshal(t.metadata)
-		metadataJSON = api.JsonListString(data)
+	if t.endTime != nil {
+		w.EndTime = api.NewOptDateTime(*t.endTime)
 	}
-
-	// Create update request - TraceBatchUpdate uses Ids + single Update
-	req := api.TraceBatchUpdate{
-		Ids: []uuid.UUID{traceUUID},
-		Update: api.TraceUpdate{
-			EndTime:  api.NewOptDateTime(endTime),
-			Input:    nullJSON, // Required field, must be valid JSON
-			Output:   outputJSON,
-			Metadata: metadataJSON,
-		},
+	if t.threadID != "" {
+		w.ThreadID = api.NewOptString(t.threadID)
 	}
-
-	_, err = t.client.apiClient.BatchUpdateTraces(ctx, api.NewOptTraceBatchUpdate(req))
-	return err
+	if t.errorInfo != nil {
+		w.ErrorInfo = api.NewOptErrorInfoWrite(t.errorInfo.write())
+	}
+	return w, nil
 }
 
-// Update updates the trace with new data.
-func (t *Trace) Update(ctx context.Context, opts ...TraceOption) error {
-	options := &traceOptions{
-		metadata: make(map[string]any),
+// traceUpdateFromWrite converts a create payload into the equivalent update payload.
+func traceUpdateFromWrite(w api.TraceWrite) api.TraceUpdate {
+	u := api.TraceUpdate{
+		ProjectName: w.ProjectName,
+		Name:        w.Name,
+		EndTime:     w.EndTime,
+		Input:       api.JsonListString(w.Input),
+		Output:      api.JsonListString(w.Output),
+		Metadata:    api.JsonListString(w.Metadata),
+		Tags:        w.Tags,
+		ThreadID:    w.ThreadID,
 	}
-	for _, opt := range opts {
-		opt(options)
+	if w.ErrorInfo.Set {
+		u.ErrorInfo = api.NewOptErrorInfo(api.ErrorInfo(w.ErrorInfo.Value))
 	}
+	return u
+}
 
-	traceUUID, err := uuid.Parse(t.id)
+// sendTrace sends the trace's current state to the API, either as a create
+// or as an update. When the client has a batcher, the operation is queued and
+// sent asynchronously.
+func (c *Client) sendTrace(ctx context.Context, t *Trace, create bool) error {
+	switch admission, spans := t.sampling.admitTrace(t); admission {
+	case admitSkip:
+		return nil
+	case admitExport:
+		return c.exportDeferred(ctx, t, spans)
+	}
+
+	w, err := t.write()
 	if err != nil {
 		return err
 	}
 
-	// Merge metadata
-	for k, v := range options.metadata {
-		t.metadata[k] = v
+	switch {
+	case c.batcher != nil:
+		c.batcher.Add(TraceBatchItem{Trace: t, create: create, write: w})
+	case create:
+		err = c.createTraces(ctx, []api.TraceWrite{w})
+	default:
+		err = c.updateTraces(ctx, []uuid.UUID{w.ID.Value}, traceUpdateFromWrite(w))
 	}
-	if options.output != nil {
-		t.output = options.output
+	if err != nil {
+		return err
 	}
 
-	// IMPORTANT: JsonListString fields must be set to valid JSON (including "null")
-	// An empty JsonListString produces malformed JSON in the generated encoder.
-	nullJSON := api.JsonListString([]byte("null"))
-
-	outputJSON := nullJSON
-	if t.output != nil {
-		data, _ := json.Marshal(t.output)
-		outputJSON = api.JsonListString(data)
+	err = t.uploadAttachments(ctx)
+	if create {
+		// The trace exists even if an upload failed; the attachments are
+		// uploaded again with its next update
+		return nil
 	}
+	return err
+}
+
+// uploadAttachments uploads the attachments added since the trace was last
+// sent. Attachments that could not be uploaded are kept for the next send.
+func (t *Trace) uploadAttachments(ctx context.Context) error {
+	pending := t.attachments
+	t.attachments = nil
+	failed, err := t.client.uploadAttachments(ctx, AttachmentEntityTrace, t.id, t.projectName, pending)
+	t.attachments = append(failed, t.attachments...)
+	return err
+}
 
-	metadataJSON := nullJSON
-	if len(t.metadata) > 0 {
-		data, _ := json.Marshal(t.metadata)
-		metadataJSON = api.JsonListString(data)
+// extractAttachments moves inline media in value into pending attachments.
+func (t *Trace) extractAttachments(field string, value any) any {
+	value, extracted := t.client.extractAttachments(field, value)
+	t.attachments = append(t.attachments, extracted...)
+	return value
+}
+
+// AddAttachment uploads an attachment to this trace. With a BatchingClient,
+// the upload is queued and failures are reported through
+// BatcherConfig.OnError. If the trace is buffered by a tail sampler, the
+// upload waits until the trace is exported.
+func (t *Trace) AddAttachment(ctx context.Context, attachment *Attachment) error {
+	if !t.sampling.recording() {
+		t.attachments = append(t.attachments, attachment)
+		return nil
 	}
+	_, err := t.client.uploadAttachments(ctx, AttachmentEntityTrace, t.id, t.projectName, []*Attachment{attachment})
+	return err
+}
+
+// createTraces creates traces with a single batch request.
+func (c *Client) createTraces(ctx context.Context, traces []api.TraceWrite) error {
+	return c.apiClient.CreateTraces(ctx, api.NewOptTraceBatchWrite(api.TraceBatchWrite{
+		Traces: traces,
+	}))
+}
 
-	req := api.TraceBatchUpdate{
-		Ids: []uuid.UUID{traceUUID},
-		Update: api.TraceUpdate{
-			Input:    nullJSON, // Required field, must be valid JSON
-			Output:   outputJSON,
-			Metadata: metadataJSON,
-			Tags:     options.tags,
-		},
+// updateTraces applies an update to traces via the batch update endpoint.
+func (c *Client) updateTraces(ctx context.Context, ids []uuid.UUID, update api.TraceUpdate) error {
+	res, err := c.apiClient.BatchUpdateTraces(ctx, api.NewOptTraceBatchUpdate(api.TraceBatchUpdate{
+		Ids:    ids,
+		Update: update,
+	}))
+	if err != nil {
+		return err
 	}
+	if msg, ok := res.(*api.ErrorMessage); ok {
+		return newAPIError(400, msg)
+	}
+	return nil
+}
 
-	_, err = t.client.apiClient.BatchUpdateTraces(ctx, api.NewOptTraceBatchUpdate(req))
-	return err
+// Sampled reports whether the trace is exported. It is false if the client's
+// sampler dropped the trace; a trace deferred by a tail sampler counts as
+// sampled until the sampler decides otherwise.
+func (t *Trace) Sampled() bool {
+	return t.sampling.sampled()
 }
 
 // Span creates a new span within this trace.
 func (t *Trace) Span(ctx context.Context, name string, opts ...SpanOption) (*Span, error) {
-	return t.client.createSpan(ctx, t.id, "", name, opts...)
+	return t.client.createSpan(withOTelSpan(ctx, t.otelSpan), t.id, "", t.projectName, t.sampling, name, opts...)
+}
+
+// RecordError records err on this trace, marking it as failed.
+// The error is sent with the next Update or End.
+func (t *Trace) RecordError(err error) {
+	if info := NewErrorInfo(err); info != nil {
+		t.errorInfo = info
+	}
+}
+
+// ErrorInfo returns the error recorded on this trace, or nil if none.
+func (t *Trace) ErrorInfo() *ErrorInfo {
+	return t.errorInfo
 }
 
 // AddFeedbackScore adds a feedback score to this trace.
@@ -175,15 +341,57 @@ func (t *Trace) AddFeedbackScore(ctx context.Context, name string, value float64
 	if err != nil {
 		return err
 	}
+	category, err := t.client.checkFeedbackScore(name, value)
+	if err != nil {
+		return err
+	}
 
 	req := api.FeedbackScore{
-		Name:   name,
-		Value:  value,
-		Reason: api.NewOptString(reason),
-		Source: api.FeedbackScoreSourceSdk,
+		Name:         name,
+		CategoryName: optString(category),
+		Value:        value,
+		Reason:       api.NewOptString(t.client.redactReason(reason)),
+		Source:       api.FeedbackScoreSourceSdk,
 	}
 
 	return t.client.apiClient.AddTraceFeedbackScore(ctx, api.NewOptFeedbackScore(req), api.AddTraceFeedbackScoreParams{
 		ID: traceUUID,
 	})
 }
+
+// DeleteFeedbackScores deletes the named feedback scores from this trace.
+func (t *Trace) DeleteFeedbackScores(ctx context.Context, names ...string) error {
+	return t.client.DeleteTraceFeedbackScores(ctx, t.id, names...)
+}
+
+// AddComment adds a comment to this trace.
+func (t *Trace) AddComment(ctx context.Context, text string) (*Comment, error) {
+	comment, err := t.client.AddTraceComment(ctx, t.id, text)
+	if err != nil {
+		return nil, err
+	}
+	t.comments = append(t.comments, *comment)
+	return comment, nil
+}
+
+// UpdateComment replaces the text of a comment on this trace.
+func (t *Trace) UpdateComment(ctx context.Context, commentID, text string) error {
+	if err := t.client.UpdateTraceComment(ctx, commentID, text); err != nil {
+		return err
+	}
+	for i := range t.comments {
+		if t.comments[i].ID == commentID {
+			t.comments[i].Text = text
+		}
+	}
+	return nil
+}
+
+// DeleteComments deletes comments from this trace.
+func (t *Trace) DeleteComments(ctx context.Context, commentIDs ...string) error {
+	if err := t.client.DeleteTraceComments(ctx, commentIDs...); err != nil {
+		return err
+	}
+	t.comments = removeComments(t.comments, commentIDs)
+	return nil
+}
@@ -0,0 +1,124 @@
+package opik
+
+import (
+	"context"
+)
+
+// Track calls fn with in inside a new span named name, recording in as the
+// span input and the result as its output. Errors returned by fn and panics
+// are recorded on the span; panics are re-raised after the span ends.
+//
+// The span is a child of the span or trace in ctx. If ctx has neither but
+// carries a client (see ContextWithClient), a root trace is started for the
+// call. Otherwise fn is called without tracing. Tracing failures never
+// prevent fn from running.
+func Track[In, Out any](ctx context.Context, name string, fn func(context.Context, In) (Out, error), in In, opts ...SpanOption) (Out, error) {
+	return track(ctx, name, trackValue("input", in), func(ctx context.Context) (Out, error) {
+		return fn(ctx, in)
+	}, opts)
+}
+
+// Track0 is like Track for functions that take no arguments besides the context.
+func Track0[Out any](ctx context.Context, name string, fn func(context.Context) (Out, error), opts ...SpanOption) (Out, error) {
+	return track(ctx, name, nil, fn, opts)
+}
+
+// Track2 is like Track for functions that take two arguments.
+// The arguments are recorded as "arg0" and "arg1" in the span input.
+func Track2[In1, In2, Out any](ctx context.Context, name string, fn func(context.Context, In1, In2) (Out, error), in1 In1, in2 In2, opts ...SpanOption) (Out, error) {
+	input := map[string]any{"arg0": in1, "arg1": in2}
+	return track(ctx, name, input, func(ctx context.Context) (Out, error) {
+		return fn(ctx, in1, in2)
+	}, opts)
+}
+
+// TrackFunc wraps fn so that every call is tracked as with Track.
+// It is the equivalent of decorating a function once at its definition.
+func TrackFunc[In, Out any](name string, fn func(context.Context, In) (Out, error), opts ...SpanOption) func(context.Context, In) (Out, error) {
+	return func(ctx context.Context, in In) (Out, error) {
+		return Track(ctx, name, fn, in, opts...)
+	}
+}
+
+// track runs fn inside a tracked span and ends it with fn's result.
+func track[Out any](ctx context.Context, name string, input any, fn func(context.Context) (Out, error), opts []SpanOption) (out Out, err error) {
+	ctx, end := startTracking(ctx, name, input, opts)
+	if end == nil {
+		return fn(ctx)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			end(nil, NewPanicError(r))
+			panic(r)
+		}
+		end(trackValue("output", out), err)
+	}()
+
+	return fn(ctx)
+}
+
+// startTracking starts the span, and a root trace if needed, for a tracked
+// call. It returns a nil end function if the call cannot be traced.
+func startTracking(ctx context.Context, name string, input any, opts []SpanOption) (context.Context, func(output any, err error)) {
+	var trace *Trace
+	if SpanFromContext(ctx) == nil && TraceFromContext(ctx) == nil {
+		client := ClientFromContext(ctx)
+		if client == nil {
+			return ctx, nil
+		}
+
+		var err error
+		ctx, trace, err = StartTrace(ctx, client, name, WithTraceInput(input))
+		if err != nil {
+			return ctx, nil
+		}
+	}
+
+	spanOpts := make([]SpanOption, 0, len(opts)+1)
+	if input != nil {
+		spanOpts = append(spanOpts, WithSpanInput(input))
+	}
+	spanOpts = append(spanOpts, opts...)
+
+	spanCtx, span, spanErr := StartSpan(ctx, name, spanOpts...)
+	if spanErr != nil {
+		span = nil
+		spanCtx = ctx
+	}
+	if span == nil && trace == nil {
+		return ctx, nil
+	}
+
+	end := func(output any, err error) {
+		if span != nil {
+			endOpts := []SpanOption{WithSpanOutput(output)}
+			if err != nil {
+				endOpts = append(endOpts, WithSpanError(err))
+			}
+			_ = span.End(spanCtx, endOpts...)
+		}
+		if trace != nil {
+			endOpts := []TraceOption{WithTraceOutput(output)}
+			if err != nil {
+				endOpts = append(endOpts, WithTraceError(err))
+			}
+			_ = trace.End(ctx, endOpts...)
+		}
+	}
+
+	return spanCtx, end
+}
+
+// trackValue returns maps as-is and wraps any other value under key, since
+// Opik displays inputs and outputs as objects.
+func trackValue(key string, v any) any {
+	switch v.(type) {
+	case nil:
+		return nil
+	case map[string]any:
+		return v
+	default:
+		return map[string]any{key: v}
+	}
+}
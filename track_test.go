@@ -0,0 +1,159 @@
+package opik
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/agentplexus/go-opik/internal/tracetest"
+)
+
+func double(_ context.Context, n int) (int, error) {
+	return n * 2, nil
+}
+
+func TestTrackInTrace(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+	ctx, trace, err := StartTrace(context.Background(), client, "parent")
+	if err != nil {
+		t.Fatalf("StartTrace error: %v", err)
+	}
+
+	var inner *Span
+	got, err := Track(ctx, "double", func(ctx context.Context, n int) (int, error) {
+		inner = SpanFromContext(ctx)
+		return double(ctx, n)
+	}, 21, WithSpanType(SpanTypeTool))
+	if err != nil {
+		t.Fatalf("Track error: %v", err)
+	}
+	if got != 42 {
+		t.Errorf("Track result = %d, want 42", got)
+	}
+
+	if inner == nil {
+		t.Fatal("fn should receive a context carrying the span")
+	}
+	if inner.TraceID() != trace.ID() || inner.Type() != SpanTypeTool {
+		t.Errorf("span trace/type = %s/%s, want %s/tool", inner.TraceID(), inner.Type(), trace.ID())
+	}
+	if inner.EndTime() == nil {
+		t.Error("span should be ended")
+	}
+
+	update := lastItem(t, tracetest.SpanUpdates(ms))
+	if input, _ := update["input"].(map[string]any); input["input"] != float64(21) {
+		t.Errorf("span input = %v, want input=21", update["input"])
+	}
+	if output, _ := update["output"].(map[string]any); output["output"] != float64(42) {
+		t.Errorf("span output = %v, want output=42", update["output"])
+	}
+	if trace.EndTime() != nil {
+		t.Error("parent trace should be left open")
+	}
+}
+
+func TestTrackRootTrace(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+	ctx := ContextWithClient(context.Background(), client)
+
+	var trace *Trace
+	_, err := Track0(ctx, "root", func(ctx context.Context) (string, error) {
+		trace = TraceFromContext(ctx)
+		return "ok", nil
+	})
+	if err != nil {
+		t.Fatalf("Track0 error: %v", err)
+	}
+	if trace == nil {
+		t.Fatal("a root trace should be started when ctx has a client")
+	}
+	if trace.EndTime() == nil {
+		t.Error("root trace should be ended")
+	}
+	if len(tracetest.TraceUpdates(ms)) != 1 {
+		t.Error("root trace end should be sent")
+	}
+}
+
+func TestTrackWithoutClient(t *testing.T) {
+	got, err := Track2(context.Background(), "add", func(ctx context.Context, a, b int) (int, error) {
+		if SpanFromContext(ctx) != nil {
+			t.Error("no span should be started without a client")
+		}
+		return a + b, nil
+	}, 1, 2)
+	if err != nil || got != 3 {
+		t.Errorf("Track2 = %d, %v; want 3, nil", got, err)
+	}
+}
+
+func TestTrackRecordsError(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+	ctx, _, _ := StartTrace(context.Background(), client, "parent")
+
+	wantErr := errors.New("lookup failed")
+	lookup := TrackFunc("lookup", func(ctx context.Context, key string) (string, error) {
+		return "", wantErr
+	})
+
+	if _, err := lookup(ctx, "k"); !errors.Is(err, wantErr) {
+		t.Fatalf("error = %v, want %v", err, wantErr)
+	}
+
+	update := lastItem(t, tracetest.SpanUpdates(ms))
+	info, _ := update["error_info"].(map[string]any)
+	if info["message"] != "lookup failed" {
+		t.Errorf("error_info = %v, want message %q", update["error_info"], "lookup failed")
+	}
+}
+
+func TestTrackRecordsPanic(t *testing.T) {
+	ms := tracetest.NewServer()
+	defer ms.Close()
+
+	client := newTestClient(t, ms)
+	ctx, _, _ := StartTrace(context.Background(), client, "parent")
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v, want the original panic value", r)
+			}
+		}()
+		_, _ = Track0(ctx, "explode", func(ctx context.Context) (int, error) {
+			panic("boom")
+		})
+	}()
+
+	update := lastItem(t, tracetest.SpanUpdates(ms))
+	info, _ := update["error_info"].(map[string]any)
+	if info["exception_type"] != "panic" {
+		t.Errorf("error_info = %v, want exception_type panic", update["error_info"])
+	}
+	if update["end_time"] == nil {
+		t.Error("span should be ended after a panic")
+	}
+}
+
+func TestTrackValue(t *testing.T) {
+	if trackValue("input", nil) != nil {
+		t.Error("nil should stay nil")
+	}
+	m := map[string]any{"a": 1}
+	if got, _ := trackValue("input", m).(map[string]any); got["a"] != 1 {
+		t.Error("maps should be passed through")
+	}
+	if got, _ := trackValue("output", "x").(map[string]any); got["output"] != "x" {
+		t.Error("other values should be wrapped under the key")
+	}
+}
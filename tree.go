@@ -0,0 +1,111 @@
+package opik
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/agentplexus/go-opik/internal/api"
+)
+
+// traceTreePageSize is the page size used to fetch the spans of a trace.
+const traceTreePageSize = 500
+
+// TraceTree is a trace together with all of its spans, arranged by parent.
+type TraceTree struct {
+	Trace *TraceInfo
+	// Roots holds the spans without a parent span, in start time order.
+	// Spans whose parent is missing from the trace are also roots.
+	Roots []*SpanNode
+}
+
+// SpanNode is a span in a TraceTree.
+type SpanNode struct {
+	Span *SpanInfo
+	// Children holds the child spans, in start time order.
+	Children []*SpanNode
+}
+
+// Walk calls fn for every span in the tree, depth first, parents before
+// their children. depth is 0 for root spans.
+func (t *TraceTree) Walk(fn func(node *SpanNode, depth int)) {
+	var walk func(nodes []*SpanNode, depth int)
+	walk = func(nodes []*SpanNode, depth int) {
+		for _, n := range nodes {
+			fn(n, depth)
+			walk(n.Children, depth+1)
+		}
+	}
+	walk(t.Roots, 0)
+}
+
+// Find returns the node of the span with the given ID, or nil.
+func (t *TraceTree) Find(spanID string) *SpanNode {
+	var found *SpanNode
+	t.Walk(func(node *SpanNode, _ int) {
+		if found == nil && node.Span.ID == spanID {
+			found = node
+		}
+	})
+	return found
+}
+
+// GetTraceTree retrieves a trace and all of its spans, assembled into a
+// parent/child tree.
+func (c *Client) GetTraceTree(ctx context.Context, traceID string) (*TraceTree, error) {
+	traceUUID, err := uuid.Parse(traceID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.apiClient.GetTraceById(ctx, api.GetTraceByIdParams{ID: traceUUID})
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, ErrTraceNotFound
+	}
+	trace := traceInfoFromAPI(resp)
+
+	q := NewQuery().TraceID(traceID)
+	if resp.ProjectID.Set {
+		q.ProjectID(resp.ProjectID.Value.String())
+	}
+
+	var spans []*SpanInfo
+	for span, err := range c.AllSpans(ctx, q, WithPageSize(traceTreePageSize)) {
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, span)
+	}
+
+	return &TraceTree{
+		Trace: trace,
+		Roots: buildSpanTree(spans),
+	}, nil
+}
+
+// buildSpanTree arranges spans by parent and returns the roots.
+func buildSpanTree(spans []*SpanInfo) []*SpanNode {
+	slices.SortStableFunc(spans, func(a, b *SpanInfo) int {
+		return a.StartTime.Compare(b.StartTime)
+	})
+
+	nodes := make(map[string]*SpanNode, len(spans))
+	for _, s := range spans {
+		nodes[s.ID] = &SpanNode{Span: s}
+	}
+
+	var roots []*SpanNode
+	for _, s := range spans {
+		node := nodes[s.ID]
+		if parent, ok := nodes[s.ParentSpanID]; ok && s.ParentSpanID != s.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
+}
@@ -0,0 +1,285 @@
+package opik
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentplexus/go-opik/testutil"
+)
+
+const (
+	testTraceID   = "01890a5d-ac96-774b-bcce-b302099a8057"
+	testProjectID = "01890a5d-ac96-774b-bcce-b302099a8000"
+)
+
+func testSpanJSON(id, parentID, name string, start time.Time) map[string]any {
+	s := map[string]any{
+		"id":         id,
+		"trace_id":   testTraceID,
+		"name":       name,
+		"type":       "llm",
+		"start_time": start.Format(time.RFC3339Nano),
+		"input":      map[string]any{"prompt": name},
+		"usage":      map[string]any{"total_tokens": 12},
+		"model":      "gpt-4o",
+	}
+	if parentID != "" {
+		s["parent_span_id"] = parentID
+	}
+	return s
+}
+
+func newTestTreeServer(t *testing.T) (*testutil.MockServer, *Client) {
+	t.Helper()
+
+	ms := testutil.NewMockServer()
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	ms.OnGet("/v1/private/traces/"+testTraceID).RespondJSON(http.StatusOK, map[string]any{
+		"id":                   testTraceID,
+		"project_id":           testProjectID,
+		"name":                 "chat",
+		"start_time":           start.Format(time.RFC3339Nano),
+		"end_time":             start.Add(2 * time.Second).Format(time.RFC3339Nano),
+		"duration":             2000.5,
+		"input":                map[string]any{"question": "hi"},
+		"output":               map[string]any{"answer": "hello"},
+		"metadata":             map[string]any{"env": "test"},
+		"tags":                 []string{"a", "b"},
+		"thread_id":            "conversation-1",
+		"error_info":           map[string]any{"exception_type": "*errors.errorString", "message": "boom", "traceback": "tb"},
+		"usage":                map[string]any{"total_tokens": 42},
+		"total_estimated_cost": 0.25,
+		"span_count":           3,
+		"feedback_scores":      []map[string]any{{"name": "accuracy", "value": 0.9, "source": "sdk"}},
+		"comments":             []map[string]any{{"id": "01890a5d-ac96-774b-bcce-b302099a8001", "text": "looks good"}},
+	})
+	ms.OnGet("/v1/private/projects/"+testProjectID).RespondJSON(http.StatusOK, map[string]any{
+		"id":   testProjectID,
+		"name": "tree-project",
+	})
+	ms.OnGet("/v1/private/spans").RespondJSON(http.StatusOK, map[string]any{
+		"page":  1,
+		"size":  3,
+		"total": 3,
+		"content": []map[string]any{
+			testSpanJSON("01890a5d-ac96-774b-bcce-b302099a8103", "01890a5d-ac96-774b-bcce-b302099a8101", "child-2", start.Add(3*time.Millisecond)),
+			testSpanJSON("01890a5d-ac96-774b-bcce-b302099a8101", "", "root", start),
+			testSpanJSON("01890a5d-ac96-774b-bcce-b302099a8102", "01890a5d-ac96-774b-bcce-b302099a8101", "child-1", start.Add(time.Millisecond)),
+		},
+	})
+
+	client, err := NewClient(WithURL(ms.URL()), WithProjectName("tree-project"))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+	return ms, client
+}
+
+func TestGetTraceHydrated(t *testing.T) {
+	ms, client := newTestTreeServer(t)
+	defer ms.Close()
+
+	trace, err := client.GetTrace(context.Background(), testTraceID)
+	if err != nil {
+		t.Fatalf("GetTrace error: %v", err)
+	}
+
+	if trace.Name() != "chat" || trace.ThreadID() != "conversation-1" || trace.ProjectName() != "tree-project" {
+		t.Errorf("Name = %q, ThreadID = %q, ProjectName = %q", trace.Name(), trace.ThreadID(), trace.ProjectName())
+	}
+	if trace.EndTime() == nil {
+		t.Error("EndTime should be set")
+	}
+	if trace.Input().(map[string]any)["question"] != "hi" || trace.Output().(map[string]any)["answer"] != "hello" {
+		t.Errorf("Input = %v, Output = %v", trace.Input(), trace.Output())
+	}
+	if trace.Metadata()["env"] != "test" || len(trace.Tags()) != 2 {
+		t.Errorf("Metadata = %v, Tags = %v", trace.Metadata(), trace.Tags())
+	}
+	if trace.ErrorInfo() == nil || trace.ErrorInfo().Message != "boom" {
+		t.Errorf("ErrorInfo = %+v", trace.ErrorInfo())
+	}
+	if trace.Usage()["total_tokens"] != 42 || trace.TotalCost() != 0.25 {
+		t.Errorf("Usage = %v, TotalCost = %v", trace.Usage(), trace.TotalCost())
+	}
+	if len(trace.FeedbackScores()) != 1 || trace.FeedbackScores()[0].Name != "accuracy" || trace.FeedbackScores()[0].Source != "sdk" {
+		t.Errorf("FeedbackScores = %+v", trace.FeedbackScores())
+	}
+	if len(trace.Comments()) != 1 || trace.Comments()[0].Text != "looks good" {
+		t.Errorf("Comments = %+v", trace.Comments())
+	}
+}
+
+func TestGetTraceKeepsProject(t *testing.T) {
+	ms, client := newTestTreeServer(t)
+	defer ms.Close()
+	ms.OnPatch("/v1/private/traces/batch").Respond(http.StatusNoContent, nil)
+	ms.OnPost("/v1/private/spans/batch").Respond(http.StatusNoContent, nil)
+	client.SetProjectName("default-project")
+
+	ctx := context.Background()
+	trace, err := client.GetTrace(ctx, testTraceID)
+	if err != nil {
+		t.Fatalf("GetTrace error: %v", err)
+	}
+	if trace.ProjectName() != "tree-project" {
+		t.Errorf("ProjectName = %q, want tree-project", trace.ProjectName())
+	}
+	if err := trace.Update(ctx, WithTraceMetadata(map[string]any{"reviewed": true})); err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if _, err := trace.Span(ctx, "follow-up"); err != nil {
+		t.Fatalf("Span error: %v", err)
+	}
+
+	reqs := append(ms.RequestsForPath("/v1/private/traces/batch"), ms.RequestsForPath("/v1/private/spans/batch")...)
+	if len(reqs) != 2 {
+		t.Fatalf("requests = %d, want 2", len(reqs))
+	}
+	for _, req := range reqs {
+		if !strings.Contains(string(req.Body), `"project_name":"tree-project"`) {
+			t.Errorf("%s %s body = %s, want project tree-project", req.Method, req.Path, req.Body)
+		}
+	}
+
+	// The project is looked up once
+	if _, err := client.GetTrace(ctx, testTraceID); err != nil {
+		t.Fatalf("GetTrace error: %v", err)
+	}
+	if n := len(ms.RequestsForPath("/v1/private/projects/" + testProjectID)); n != 1 {
+		t.Errorf("project lookups = %d, want 1", n)
+	}
+}
+
+func TestGetSpanResolvesProject(t *testing.T) {
+	ms, client := newTestTreeServer(t)
+	defer ms.Close()
+
+	spanID := "01890a5d-ac96-774b-bcce-b302099a8101"
+	span := testSpanJSON(spanID, "", "root", time.Now())
+	span["project_id"] = testProjectID
+	ms.OnGet("/v1/private/spans/"+spanID).RespondJSON(http.StatusOK, span)
+	client.SetProjectName("default-project")
+
+	got, err := client.GetSpan(context.Background(), spanID)
+	if err != nil {
+		t.Fatalf("GetSpan error: %v", err)
+	}
+	if got.ProjectID != testProjectID || got.ProjectName != "tree-project" {
+		t.Errorf("ProjectID = %q, ProjectName = %q", got.ProjectID, got.ProjectName)
+	}
+}
+
+func TestListSpansHydrated(t *testing.T) {
+	ms, client := newTestTreeServer(t)
+	defer ms.Close()
+
+	spans, err := client.ListSpans(context.Background(), testTraceID, 1, 10)
+	if err != nil {
+		t.Fatalf("ListSpans error: %v", err)
+	}
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	s := spans[0]
+	if s.Input.(map[string]any)["prompt"] != "child-2" || s.Usage["total_tokens"] != 12 || s.Model != "gpt-4o" {
+		t.Errorf("unexpected span %+v", s)
+	}
+}
+
+func TestGetSpan(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	spanID := "01890a5d-ac96-774b-bcce-b302099a8101"
+	span := testSpanJSON(spanID, "", "root", time.Now())
+	span["project_name"] = "tree-project"
+	span["error_info"] = map[string]any{"exception_type": "panic", "traceback": "tb"}
+	span["total_estimated_cost"] = 0.01
+	ms.OnGet("/v1/private/spans/"+spanID).RespondJSON(http.StatusOK, span)
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	got, err := client.GetSpan(context.Background(), spanID)
+	if err != nil {
+		t.Fatalf("GetSpan error: %v", err)
+	}
+	if got.ID != spanID || got.TraceID != testTraceID || got.ProjectName != "tree-project" || got.Type != "llm" {
+		t.Errorf("unexpected span %+v", got)
+	}
+	if got.ErrorInfo == nil || got.ErrorInfo.ExceptionType != "panic" || got.TotalCost != 0.01 {
+		t.Errorf("ErrorInfo = %+v, TotalCost = %v", got.ErrorInfo, got.TotalCost)
+	}
+}
+
+func TestGetSpanNotFound(t *testing.T) {
+	ms := testutil.NewMockServer()
+	defer ms.Close()
+
+	spanID := "01890a5d-ac96-774b-bcce-b302099a8101"
+	// The API spec declares a span body for 404 responses
+	ms.OnGet("/v1/private/spans/"+spanID).RespondJSON(http.StatusNotFound, map[string]any{
+		"start_time": time.Now().Format(time.RFC3339Nano),
+	})
+
+	client, err := NewClient(WithURL(ms.URL()))
+	if err != nil {
+		t.Fatalf("NewClient error: %v", err)
+	}
+
+	if _, err := client.GetSpan(context.Background(), spanID); !IsNotFound(err) {
+		t.Errorf("GetSpan error = %v, want not found", err)
+	}
+}
+
+func TestGetTraceTree(t *testing.T) {
+	ms, client := newTestTreeServer(t)
+	defer ms.Close()
+
+	tree, err := client.GetTraceTree(context.Background(), testTraceID)
+	if err != nil {
+		t.Fatalf("GetTraceTree error: %v", err)
+	}
+
+	if tree.Trace.ID != testTraceID || tree.Trace.SpanCount != 3 || tree.Trace.Duration != 2000500*time.Microsecond {
+		t.Errorf("unexpected trace %+v", tree.Trace)
+	}
+
+	var lines []string
+	tree.Walk(func(node *SpanNode, depth int) {
+		lines = append(lines, strings.Repeat("  ", depth)+node.Span.Name)
+	})
+	if got := strings.Join(lines, "\n"); got != "root\n  child-1\n  child-2" {
+		t.Errorf("tree =\n%s", got)
+	}
+
+	if node := tree.Find("01890a5d-ac96-774b-bcce-b302099a8102"); node == nil || node.Span.Name != "child-1" {
+		t.Errorf("Find returned %v", node)
+	}
+
+	req := ms.RequestsForPath("/v1/private/spans")
+	if len(req) != 1 {
+		t.Fatalf("got %d span requests, want 1", len(req))
+	}
+}
+
+func TestBuildSpanTreeOrphans(t *testing.T) {
+	start := time.Now()
+	spans := []*SpanInfo{
+		{ID: "b", ParentSpanID: "missing", Name: "orphan", StartTime: start.Add(time.Second)},
+		{ID: "a", Name: "root", StartTime: start},
+	}
+
+	roots := buildSpanTree(spans)
+
+	if len(roots) != 2 || roots[0].Span.Name != "root" || roots[1].Span.Name != "orphan" {
+		t.Errorf("roots = %v, want root then orphan", roots)
+	}
+}